@@ -0,0 +1,99 @@
+// Package buildfile decodes build files into generic documents. The format
+// of a build file is chosen by its extension, and decoders for more
+// formats can be registered.
+package buildfile
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Decoder parses the content of a build file into a document. The order of
+// the keys of mappings is kept when the format defines one.
+type Decoder interface {
+	Decode(buf []byte) (yaml.MapSlice, error)
+}
+
+// DecoderFunc adapts a function to the Decoder interface
+type DecoderFunc func(buf []byte) (yaml.MapSlice, error)
+
+// Decode calls f(buf)
+func (f DecoderFunc) Decode(buf []byte) (yaml.MapSlice, error) {
+	return f(buf)
+}
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{}
+	// preference is the order in which the extensions are looked up
+	preference = map[string]int{}
+)
+
+// Register makes the decoder available for build files with the extension,
+// such as .json. Extensions registered first are preferred when a project
+// has build files in more than one format.
+func Register(ext string, decoder Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	ext = strings.ToLower(ext)
+	if _, ok := decoders[ext]; !ok {
+		preference[ext] = len(preference)
+	}
+	decoders[ext] = decoder
+}
+
+// Extensions returns the registered extensions in order of preference
+func Extensions() []string {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	exts := []string{}
+	for ext := range decoders {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(ii, jj int) bool {
+		return preference[exts[ii]] < preference[exts[jj]]
+	})
+	return exts
+}
+
+// IsYAML returns true if the build file is decoded as YAML. Files with an
+// extension that has no decoder are read as YAML.
+func IsYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yml" || ext == ".yaml" || !isRegistered(ext)
+}
+
+func isRegistered(ext string) bool {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	_, ok := decoders[ext]
+	return ok
+}
+
+// Decode parses the content of the build file using the decoder for its
+// extension
+func Decode(path string, buf []byte) (yaml.MapSlice, error) {
+	decodersMu.RLock()
+	decoder, ok := decoders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		decoder = decoders[".yml"]
+	}
+	decodersMu.RUnlock()
+	doc, err := decoder.Decode(buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %v", path)
+	}
+	return doc, nil
+}
+
+func init() {
+	// YAML is registered before the decoders of the other files so that
+	// it is the preferred format
+	Register(".yml", DecoderFunc(decodeYAML))
+	Register(".yaml", DecoderFunc(decodeYAML))
+}
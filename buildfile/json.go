@@ -0,0 +1,79 @@
+package buildfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// decodeJSONValue reads the next value from the decoder, keeping the order
+// of the keys of objects. Numbers are decoded as int when they are whole
+// and as float64 otherwise, like the YAML decoder does.
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token := token.(type) {
+	case json.Delim:
+		switch token {
+		case '{':
+			doc := yaml.MapSlice{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				doc = append(doc, yaml.MapItem{Key: key, Value: value})
+			}
+			_, err := dec.Token()
+			return doc, err
+		case '[':
+			list := []interface{}{}
+			for dec.More() {
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		return nil, errors.Errorf("unexpected %v", token)
+	case json.Number:
+		if n, err := token.Int64(); err == nil {
+			return int(n), nil
+		}
+		return token.Float64()
+	}
+	return token, nil
+}
+
+func decodeJSON(buf []byte) (yaml.MapSlice, error) {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected content after the build file object")
+	}
+	doc, ok := value.(yaml.MapSlice)
+	if !ok {
+		return nil, errors.New("expecting the build file to be an object")
+	}
+	return doc, nil
+}
+
+func init() {
+	Register(".json", DecoderFunc(decodeJSON))
+}
@@ -0,0 +1,55 @@
+package buildfile
+
+import (
+	"sort"
+
+	toml "github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v2"
+)
+
+// tomlValue converts the values of a TOML tree into the types produced by
+// the YAML decoder. TOML tables do not keep the order of their keys, so
+// the keys are sorted.
+func tomlValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := []string{}
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		doc := yaml.MapSlice{}
+		for _, key := range keys {
+			doc = append(doc, yaml.MapItem{Key: key, Value: tomlValue(value[key])})
+		}
+		return doc
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for ii, item := range value {
+			list[ii] = tomlValue(item)
+		}
+		return list
+	case []map[string]interface{}:
+		list := make([]interface{}, len(value))
+		for ii, item := range value {
+			list[ii] = tomlValue(item)
+		}
+		return list
+	case int64:
+		return int(value)
+	}
+	return value
+}
+
+func decodeTOML(buf []byte) (yaml.MapSlice, error) {
+	tree, err := toml.LoadBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	doc, _ := tomlValue(tree.ToMap()).(yaml.MapSlice)
+	return doc, nil
+}
+
+func init() {
+	Register(".toml", DecoderFunc(decodeTOML))
+}
@@ -0,0 +1,13 @@
+package buildfile
+
+import (
+	"gopkg.in/yaml.v2"
+)
+
+func decodeYAML(buf []byte) (yaml.MapSlice, error) {
+	doc := yaml.MapSlice{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
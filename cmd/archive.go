@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// extractTarGz extracts the gzip compressed tar stream into the directory
+func extractTarGz(r io.Reader, dir string) error {
+	_, err := extractTarGzPath(r, dir, "")
+	return err
+}
+
+// extractTarGzPath extracts the entries of the gzip compressed tar stream
+// that are at or below the slash separated prefix into the directory. The
+// entries are extracted relative to the parent of the prefix, so the
+// prefix itself is created within the directory. It returns the number of
+// entries extracted.
+func extractTarGzPath(r io.Reader, dir, prefix string) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to read the compressed archive")
+	}
+	defer gz.Close()
+	return extractTarPath(gz, dir, prefix)
+}
+
+// extractTarPath is extractTarGzPath for an uncompressed tar stream
+func extractTarPath(r io.Reader, dir, prefix string) (int, error) {
+	prefix = strings.Trim(path.Clean("/"+prefix), "/")
+	parent := ""
+	if prefix != "" {
+		parent = path.Dir(prefix)
+	}
+	return extractTarMatching(r, dir, func(name string) (string, bool) {
+		if prefix == "" {
+			return name, true
+		}
+		if name != prefix && !strings.HasPrefix(name, prefix+"/") {
+			return "", false
+		}
+		if parent != "." {
+			name = strings.TrimPrefix(name, parent+"/")
+		}
+		return name, true
+	})
+}
+
+// throughLink returns true if the parent of the slash separated name goes
+// through one of the links, which are relative to the output directory
+func throughLink(links map[string]bool, name string) bool {
+	for parent := path.Dir(name); parent != "." && parent != "/"; parent = path.Dir(parent) {
+		if links[parent] {
+			return true
+		}
+	}
+	return false
+}
+
+// linkTargetThroughLink returns true if the target of the link goes
+// through one of the links created before, or above the output directory,
+// on its way to its last element. A link created by the archive could
+// otherwise lead another one out of the directory, although each of them
+// points within it lexically.
+func linkTargetThroughLink(links map[string]bool, name, linkname string) bool {
+	current := path.Dir(name)
+	parts := strings.Split(linkname, "/")
+	for _, part := range parts[:len(parts)-1] {
+		current = path.Join(current, part)
+		if current == ".." || strings.HasPrefix(current, "../") || links[current] || throughLink(links, current) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractTarMatching extracts the entries of the tar stream that are
+// selected by match into the directory. match is given the slash separated
+// name of the entry and returns where it is extracted to, relative to the
+// directory. Entries are not extracted through the links created by the
+// archive, which could lead them out of the directory.
+func extractTarMatching(r io.Reader, dir string, match func(name string) (string, bool)) (int, error) {
+	dir = filepath.Clean(dir)
+	count := 0
+	// links holds the slash separated names of the links extracted so far
+	links := map[string]bool{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, errors.Wrap(err, "unable to read the archive")
+		}
+		name, ok := match(strings.Trim(path.Clean("/"+hdr.Name), "/"))
+		if !ok {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if target != dir && !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			return count, errors.Errorf("the archive entry %v is outside the output directory", hdr.Name)
+		}
+		if throughLink(links, name) || (links[name] && hdr.Typeflag != tar.TypeSymlink) {
+			return count, errors.Errorf("the archive entry %v is extracted through a link of the archive", hdr.Name)
+		}
+		count++
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return count, err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return count, err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return count, err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return count, err
+			}
+			if err := f.Close(); err != nil {
+				return count, err
+			}
+		case tar.TypeSymlink:
+			// only links that stay within the output directory are created
+			linked := filepath.Join(filepath.Dir(target), filepath.FromSlash(hdr.Linkname))
+			if filepath.IsAbs(hdr.Linkname) || (linked != dir && !strings.HasPrefix(linked, dir+string(filepath.Separator))) ||
+				linkTargetThroughLink(links, name, filepath.ToSlash(hdr.Linkname)) {
+				return count, errors.Errorf("the archive link %v points outside the output directory", hdr.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return count, err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return count, err
+			}
+			links[name] = true
+		}
+	}
+}
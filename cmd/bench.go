@@ -49,7 +49,9 @@ var benchCmd = &cobra.Command{
 			}
 			defer client.Disconnect()
 
-			runClient(client)
+			job := newJobRecord()
+			defer job.lock()()
+			runClient(rootContext, client, job)
 			return nil
 		}
 
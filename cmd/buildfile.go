@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/buildfile"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// buildSpecification is the structure of the rai_build.yml file as
+// documented in the user guide
+type buildSpecification struct {
+	RAI struct {
+		Version string `yaml:"version"`
+		Image   string `yaml:"image,omitempty"`
+	} `yaml:"rai"`
+	Resources struct {
+		CPU struct {
+			Architecture string `yaml:"architecture,omitempty"`
+			Count        int    `yaml:"count,omitempty"`
+		} `yaml:"cpu,omitempty"`
+		GPU struct {
+			Architecture string `yaml:"architecture,omitempty"`
+			Count        int    `yaml:"count,omitempty"`
+		} `yaml:"gpu,omitempty"`
+		Memory  string `yaml:"memory,omitempty"`
+		Network bool   `yaml:"network"`
+	} `yaml:"resources,omitempty"`
+	Commands struct {
+		BuildImage *struct {
+			ImageName  string `yaml:"image_name"`
+			Dockerfile string `yaml:"dockerfile"`
+			NoCache    bool   `yaml:"no_cache,omitempty"`
+			Push       *struct {
+				Push        bool `yaml:"push"`
+				Credentials *struct {
+					Username string `yaml:"username"`
+					Password string `yaml:"password"`
+				} `yaml:"credentials,omitempty"`
+			} `yaml:"push,omitempty"`
+		} `yaml:"build_image,omitempty"`
+		// Build are the commands, or steps with a run command and a when
+		// condition, that build the project
+		Build []string `yaml:"build,omitempty"`
+		// OnFailure are run when a build command fails and Always are run
+		// after the build commands in any case
+		OnFailure []string `yaml:"on_failure,omitempty"`
+		Always    []string `yaml:"always,omitempty"`
+		// Timeout bounds the run time of every build command
+		Timeout string `yaml:"timeout,omitempty"`
+	} `yaml:"commands"`
+	// Timeout bounds the run time of the whole job
+	Timeout string `yaml:"timeout,omitempty"`
+	// Includes are src:dest mappings of files outside of the directory
+	// that are uploaded with it
+	Includes []string `yaml:"includes,omitempty"`
+	// Executables are patterns of the files that are made executable
+	// before the build commands run
+	Executables []string `yaml:"executables,omitempty"`
+	// Secrets are the names of the environment variables that are set
+	// from the local environment or secrets file in every build command
+	Secrets []string `yaml:"secrets,omitempty"`
+	// Extends is the build file this one is merged over
+	Extends string `yaml:"extends,omitempty"`
+	// Include lists build file fragments that are merged in order over the
+	// extended build file
+	Include []string `yaml:"include,omitempty"`
+	// Locked lists the dotted keys that the build files extending this one
+	// can not change
+	Locked []string `yaml:"locked,omitempty"`
+	// Matrix lists the values of the parameters that a job is submitted
+	// for each combination of
+	Matrix yaml.MapSlice `yaml:"matrix,omitempty"`
+	// Profiles are named sets of sections that are merged into the build
+	// file when selected using --profile
+	Profiles yaml.MapSlice `yaml:"profiles,omitempty"`
+}
+
+// buildFileLocation returns the path of the build file that is submitted
+// with the job. It is either specified using --build or is the
+// rai_build.yml file within the submitted directory.
+func buildFileLocation() string {
+	if buildFilePath != "" {
+		if absPath, err := filepath.Abs(buildFilePath); err == nil {
+			return absPath
+		}
+		return buildFilePath
+	}
+	name := viper.GetString("client.build_file")
+	if name == "" {
+		name = "rai_build"
+	}
+	// the build file can be written in any of the supported formats
+	for _, ext := range buildfile.Extensions() {
+		if path := filepath.Join(workingDir, name+ext); com.IsFile(path) {
+			return path
+		}
+	}
+	return filepath.Join(workingDir, name+".yml")
+}
+
+// readBuildSpecification parses the build file and checks it against the
+// schema of its version. Syntax and type errors are returned as errors
+// while keys that are not part of the build specification are returned as
+// warnings, or as errors when strictBuildFile is set.
+func readBuildSpecification(path string) (*buildSpecification, []string, error) {
+	buf, err := readBuildFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := loadBuildDocument(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	violations, err := validateBuildFile(buf, doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings := []string{}
+	problems := []string{}
+	for _, violation := range violations {
+		msg := violation.format(path)
+		if violation.Unknown && !strictBuildFile {
+			warnings = append(warnings, msg)
+		} else {
+			problems = append(problems, msg)
+		}
+	}
+	if len(problems) != 0 {
+		return nil, warnings, errors.New(strings.Join(problems, "\n"))
+	}
+
+	// the rest of the checks apply to the selected profile
+	_, doc, _, err = readBuildDocument(path)
+	if err != nil {
+		return nil, warnings, err
+	}
+	doc, _ = normalizeResources(doc)
+	if doc, _, err = compileBuildFileSteps(doc); err != nil {
+		return nil, warnings, err
+	}
+	if buf, err = yaml.Marshal(doc); err != nil {
+		return nil, warnings, err
+	}
+	spec := &buildSpecification{}
+	if err := yaml.Unmarshal(buf, spec); err != nil {
+		return nil, warnings, errors.Wrapf(err, "unable to parse %v", path)
+	}
+
+	if spec.RAI.Version == "" {
+		return spec, warnings, errors.Errorf("%v: the rai.version field is required", path)
+	}
+	if len(spec.Commands.Build) == 0 && spec.Commands.BuildImage == nil {
+		return spec, warnings, errors.Errorf("%v: no build commands were specified", path)
+	}
+	return spec, warnings, nil
+}
+
+// buildFileTransform rewrites the build file document before it is
+// submitted. It returns false if it left the document unchanged.
+type buildFileTransform func(doc yaml.MapSlice) (yaml.MapSlice, bool, error)
+
+// buildFileTransforms are applied in order to the build file before it is
+// submitted
+var buildFileTransforms []buildFileTransform
+
+// resolvingForRecord is set while the build file is resolved to be shown,
+// kept or digested rather than submitted. The transforms that only apply to
+// the submitted job check it.
+var resolvingForRecord bool
+
+// overrideBuildCommands, when set, rewrites the build commands of the
+// build file before it is submitted
+var overrideBuildCommands func(build []string) []string
+
+func init() {
+	// the steps are compiled first so that the other transforms see plain
+	// build commands. The steps merged with on_failure or always are given
+	// their commands.timeout while they are compiled.
+	buildFileTransforms = append(buildFileTransforms, compileBuildFileSteps)
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		if overrideBuildCommands == nil {
+			return doc, false, nil
+		}
+		return setBuildCommands(doc, overrideBuildCommands(buildCommands(doc))), true, nil
+	})
+}
+
+// buildCommands returns the commands.build section of the document
+func buildCommands(doc yaml.MapSlice) []string {
+	commands, _ := getMapSliceEntry(doc, "commands")
+	section, _ := commands.(yaml.MapSlice)
+	build, _ := getMapSliceEntry(section, "build")
+	return cast.ToStringSlice(build)
+}
+
+// setBuildCommands replaces the commands.build section of the document
+func setBuildCommands(doc yaml.MapSlice, build []string) yaml.MapSlice {
+	commands, _ := getMapSliceEntry(doc, "commands")
+	section, _ := commands.(yaml.MapSlice)
+	return setMapSliceEntry(doc, "commands", setMapSliceEntry(section, "build", build))
+}
+
+// readBuildDocument parses the build file, merged with the files it
+// extends or includes and with the profile selected using --profile. It
+// returns the content of the file and whether the document differs from
+// it.
+func readBuildDocument(path string) ([]byte, yaml.MapSlice, bool, error) {
+	buf, err := readBuildFile(path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	own, err := buildfile.Decode(path, buf)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	doc, err := loadBuildDocument(path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	extended := !reflect.DeepEqual(doc, own)
+	doc, selected, err := selectBuildProfile(doc, buildProfile)
+	if err != nil {
+		return nil, nil, false, errors.Wrapf(err, "%v", path)
+	}
+	return buf, doc, extended || selected, nil
+}
+
+// resolvedBuildFile returns the content of the build file as it is
+// submitted with the job
+func resolvedBuildFile() ([]byte, error) {
+	path := buildFileLocation()
+	buf, doc, changed, err := readBuildDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	// the build file is submitted as YAML whatever its format
+	changed = changed || !buildfile.IsYAML(path)
+	for _, transform := range buildFileTransforms {
+		var ok bool
+		if doc, ok, err = transform(doc); err != nil {
+			return nil, err
+		}
+		changed = changed || ok
+	}
+	if !changed {
+		return buf, nil
+	}
+	return yaml.Marshal(doc)
+}
+
+// buildCommandsDigest identifies the build commands of the resolved build
+// file. It returns an empty string if the build file can not be read.
+func buildCommandsDigest() string {
+	buf, err := redactedBuildFile()
+	if err != nil {
+		return ""
+	}
+	doc := yaml.MapSlice{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return ""
+	}
+	digest := sha256.Sum256([]byte(strings.Join(buildCommands(doc), "\n")))
+	return hex.EncodeToString(digest[:])
+}
+
+// stageBuildFile writes the resolved build file to a temporary directory
+// when it differs from the build file on disk. It returns an empty path
+// if the build file on disk can be submitted as is.
+func stageBuildFile() (string, func(), error) {
+	noop := func() {}
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return "", noop, nil
+	}
+	original, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", noop, err
+	}
+	resolved, err := resolvedBuildFile()
+	if err != nil {
+		return "", noop, err
+	}
+	if bytes.Equal(original, resolved) {
+		return "", noop, nil
+	}
+	dir, err := ioutil.TempDir("", "rai")
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".yml"
+	staged := filepath.Join(dir, name)
+	// the build file may hold the values of secrets
+	if err := ioutil.WriteFile(staged, resolved, 0600); err != nil {
+		cleanup()
+		return "", noop, err
+	}
+	return staged, cleanup, nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// extensionKeyPrefix marks the top level keys that only hold YAML anchors,
+// such as x-defaults: &defaults. They are not validated and not submitted.
+const extensionKeyPrefix = "x-"
+
+// withoutExtensionKeys removes the top level keys that start with
+// extensionKeyPrefix. Aliases to their anchors were already expanded when
+// the document was parsed.
+func withoutExtensionKeys(doc yaml.MapSlice) (yaml.MapSlice, bool) {
+	stripped := yaml.MapSlice{}
+	for _, item := range doc {
+		if !strings.HasPrefix(cast.ToString(item.Key), extensionKeyPrefix) {
+			stripped = append(stripped, item)
+		}
+	}
+	return stripped, len(stripped) != len(doc)
+}
+
+func init() {
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		doc, changed := withoutExtensionKeys(doc)
+		return doc, changed, nil
+	})
+}
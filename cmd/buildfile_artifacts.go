@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// downloadArtifactsFlag fetches the artifacts of the build file once the
+// job has finished
+var downloadArtifactsFlag bool
+
+// artifactPatterns returns the patterns of the artifacts section of the
+// document, relative to the build directory
+func artifactPatterns(doc yaml.MapSlice) ([]string, error) {
+	value, ok := getMapSliceEntry(doc, "artifacts")
+	if !ok {
+		return nil, nil
+	}
+	patterns := []string{}
+	for _, pattern := range cast.ToStringSlice(value) {
+		p := path.Clean(pattern)
+		if p == buildDirectory || strings.HasPrefix(p, buildDirectory+"/") {
+			p = strings.TrimPrefix(strings.TrimPrefix(p, buildDirectory), "/")
+		}
+		if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+			return nil, errors.Errorf("the artifact %v is outside the build directory. Only %v is kept after the job", pattern, buildDirectory)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, errors.Errorf("invalid artifact pattern %v", pattern)
+		}
+		if p == "" {
+			p = "."
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// buildFileArtifacts returns the artifact patterns of the build file
+func buildFileArtifacts() ([]string, error) {
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return nil, nil
+	}
+	_, doc, _, err := readBuildDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	return artifactPatterns(doc)
+}
+
+// matchArtifact reports whether the slash separated name, relative to the
+// build directory, is an artifact or is within a directory that is one
+func matchArtifact(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == "." {
+			return true
+		}
+		for p := name; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// printArtifacts shows where the artifacts of the job can be downloaded
+// from and downloads them if --download-artifacts is set
+func printArtifacts(job *jobRecord, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+	if job.BuildURL == "" {
+		return errors.Errorf("job %v did not produce a build directory, the artifacts %v are not available",
+			job.ID, strings.Join(patterns, ", "))
+	}
+	fmt.Printf("The artifacts (%v) can be downloaded from %v\n", strings.Join(patterns, ", "), job.BuildURL)
+	if !downloadArtifactsFlag {
+		return nil
+	}
+	out := "artifacts-" + job.ID
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	body, err := openArtifacts(job)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the compressed archive")
+	}
+	defer gz.Close()
+	count, err := extractTarMatching(gz, out, func(name string) (string, bool) {
+		return name, matchArtifact(patterns, name)
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.Errorf("none of the artifacts (%v) exist in the build directory of job %v", strings.Join(patterns, ", "), job.ID)
+	}
+	fmt.Printf("The artifacts were downloaded to %v\n", out)
+	return nil
+}
+
+func init() {
+	// the worker keeps the whole build directory, so the artifacts are only
+	// used by the client to pick what to download
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		if _, err := artifactPatterns(doc); err != nil {
+			return nil, false, err
+		}
+		if _, ok := getMapSliceEntry(doc, "artifacts"); !ok {
+			return doc, false, nil
+		}
+		return deleteMapSliceEntry(doc, "artifacts"), true, nil
+	})
+}
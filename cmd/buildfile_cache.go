@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Unknwon/com"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// cacheArchiveName is the archive of the cached directories that is
+// uploaded with the job when the cache is hit
+const cacheArchiveName = ".rai_cache.tar.gz"
+
+// maxCacheArchives is the number of cache archives kept on this machine
+const maxCacheArchives = 5
+
+// buildCache is the cache section of the build file. The directories
+// listed by Paths, relative to the build directory, are kept between jobs
+// as long as the files matched by the key do not change.
+type buildCache struct {
+	Key   string
+	Paths []string
+}
+
+// cacheDir returns the directory where the cache archives are kept
+func cacheDir() (string, error) {
+	dir, err := homedir.Expand("~/.rai_cache")
+	if err != nil {
+		return "", errors.Wrap(err, "unable to locate the cache directory")
+	}
+	if !com.IsDir(dir) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", errors.Wrapf(err, "unable to create the cache directory %v", dir)
+		}
+	}
+	return dir, nil
+}
+
+// matchCacheKeyFile matches the slash separated path against the pattern,
+// where ** matches any number of directories and **.ext any file with the
+// extension below the directory
+func matchCacheKeyFile(pattern, name string) bool {
+	segments := []string{}
+	for _, segment := range strings.Split(path.Clean(pattern), "/") {
+		if strings.HasPrefix(segment, "**") && segment != "**" {
+			segments = append(segments, "**", "*"+strings.TrimLeft(segment, "*"))
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	return matchIgnoreSegments(segments, strings.Split(name, "/"))
+}
+
+// readBuildCache parses the cache section of the document and computes its
+// key from the content of the files of the directory matched by the key
+func readBuildCache(doc yaml.MapSlice, dir string) (*buildCache, error) {
+	value, ok := getMapSliceEntry(doc, "cache")
+	section, _ := value.(yaml.MapSlice)
+	if !ok || section == nil {
+		return nil, nil
+	}
+	cache := &buildCache{}
+	paths, _ := getMapSliceEntry(section, "paths")
+	for _, p := range cast.ToStringSlice(paths) {
+		p = path.Clean(p)
+		if p == buildDirectory || strings.HasPrefix(p, buildDirectory+"/") {
+			p = strings.TrimPrefix(strings.TrimPrefix(p, buildDirectory), "/")
+		}
+		if p == "" || p == "." || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+			return nil, errors.Errorf("invalid cache path %v, expecting a directory within %v", p, buildDirectory)
+		}
+		cache.Paths = append(cache.Paths, p)
+	}
+	if len(cache.Paths) == 0 {
+		return nil, errors.New("the cache section does not list any paths")
+	}
+	sort.Strings(cache.Paths)
+	key, _ := getMapSliceEntry(section, "key")
+	keySection, _ := key.(yaml.MapSlice)
+	value, _ = getMapSliceEntry(keySection, "files")
+	patterns := cast.ToStringSlice(value)
+	if len(patterns) == 0 {
+		return nil, errors.New("the cache section has no key.files to compute its key from")
+	}
+	files, _, err := collectUploadFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	matched := []uploadFile{}
+	for _, file := range files {
+		for _, pattern := range patterns {
+			if matchCacheKeyFile(pattern, file.Path) {
+				matched = append(matched, file)
+				break
+			}
+		}
+	}
+	checksums, err := uploadChecksums(matched)
+	if err != nil {
+		return nil, err
+	}
+	// the image and the cached paths are part of the key since the cache
+	// can not be shared between them
+	image := ""
+	if rai, ok := getMapSliceEntry(doc, "rai"); ok {
+		raiSection, _ := rai.(yaml.MapSlice)
+		value, _ := getMapSliceEntry(raiSection, "image")
+		image = cast.ToString(value)
+	}
+	header := image + "\n" + strings.Join(cache.Paths, "\n") + "\n"
+	cache.Key = checksumsDigest(append([]byte(header), checksums...))
+	return cache, nil
+}
+
+// currentBuildCache returns the cache of the build file, if it has one
+func currentBuildCache() (*buildCache, error) {
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return nil, nil
+	}
+	_, doc, _, err := readBuildDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	return readBuildCache(doc, workingDir)
+}
+
+// archivePath returns where the archive of the cached directories is kept
+func (c *buildCache) archivePath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, c.Key+".tar.gz"), nil
+}
+
+// isHit returns true if the cached directories for the key are available
+func (c *buildCache) isHit() bool {
+	path, err := c.archivePath()
+	return err == nil && com.IsFile(path)
+}
+
+// includes reports whether the slash separated name, relative to the build
+// directory, is within one of the cached directories
+func (c *buildCache) includes(name string) bool {
+	for _, p := range c.Paths {
+		if name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// save keeps the cached directories from the build directory archive of
+// the job, removing the oldest archives beyond maxCacheArchives
+func (c *buildCache) save(job *jobRecord) error {
+	body, err := openArtifacts(job)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	gzr, err := gzip.NewReader(body)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the compressed archive")
+	}
+	defer gzr.Close()
+	target, err := c.archivePath()
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(target), "partial")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	count := 0
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return errors.Wrap(err, "unable to read the archive")
+		}
+		name := strings.Trim(path.Clean("/"+hdr.Name), "/")
+		if !c.includes(name) {
+			continue
+		}
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			f.Close()
+			return err
+		}
+		count++
+	}
+	err = tw.Close()
+	if err == nil {
+		err = gzw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.Errorf("the cached directories (%v) do not exist in the build directory of job %v", strings.Join(c.Paths, ", "), job.ID)
+	}
+	if err := os.Rename(f.Name(), target); err != nil {
+		return err
+	}
+	return pruneCacheArchives()
+}
+
+// pruneCacheArchives removes the least recently written cache archives
+func pruneCacheArchives() error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	archives, err := filepath.Glob(filepath.Join(dir, "*.tar.gz"))
+	if err != nil || len(archives) <= maxCacheArchives {
+		return err
+	}
+	modTime := func(path string) int64 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0
+		}
+		return info.ModTime().UnixNano()
+	}
+	sort.Slice(archives, func(ii, jj int) bool {
+		return modTime(archives[ii]) > modTime(archives[jj])
+	})
+	for _, path := range archives[maxCacheArchives:] {
+		os.Remove(path)
+	}
+	return nil
+}
+
+// shortKey is the prefix of the cache key that is shown to the user
+func (c *buildCache) shortKey() string {
+	if len(c.Key) > 12 {
+		return c.Key[:12]
+	}
+	return c.Key
+}
+
+// String describes whether the cache is hit
+func (c *buildCache) String() string {
+	if c.isHit() {
+		return fmt.Sprintf("Cache hit (key %v), restoring %v", c.shortKey(), strings.Join(c.Paths, ", "))
+	}
+	return fmt.Sprintf("Cache miss (key %v), %v will be cached after the job", c.shortKey(), strings.Join(c.Paths, ", "))
+}
+
+// cacheRestoreCommand extracts the uploaded cache archive into the build
+// directory before the build commands run
+const cacheRestoreCommand = "tar -xzf /src/" + cacheArchiveName + " -C " + buildDirectory
+
+func init() {
+	// the cache is kept on this machine, so it is not submitted. When it is
+	// hit, the cached directories are uploaded and restored on the worker.
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		cache, err := readBuildCache(doc, workingDir)
+		if err != nil {
+			return nil, false, err
+		}
+		if _, ok := getMapSliceEntry(doc, "cache"); !ok {
+			return doc, false, nil
+		}
+		doc = deleteMapSliceEntry(doc, "cache")
+		build := buildCommands(doc)
+		if cache == nil || !cache.isHit() || len(build) == 0 {
+			return doc, true, nil
+		}
+		return setBuildCommands(doc, append([]string{cacheRestoreCommand}, build...)), true, nil
+	})
+}
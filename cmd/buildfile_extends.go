@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/buildfile"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// maxBuildFileDepth bounds the chain of extended and included build files
+const maxBuildFileDepth = 10
+
+// mergeBuildDocuments merges the override into the base. Mappings are
+// merged key by key, while lists and scalars of the override replace the
+// ones of the base.
+func mergeBuildDocuments(base, override yaml.MapSlice) yaml.MapSlice {
+	merged := append(yaml.MapSlice{}, base...)
+	for _, item := range override {
+		key := cast.ToString(item.Key)
+		current, _ := getMapSliceEntry(merged, key)
+		currentSection, currentOk := current.(yaml.MapSlice)
+		section, ok := item.Value.(yaml.MapSlice)
+		if ok && currentOk {
+			merged = setMapSliceEntry(merged, key, mergeBuildDocuments(currentSection, section))
+			continue
+		}
+		merged = setMapSliceEntry(merged, key, item.Value)
+	}
+	return merged
+}
+
+// lookupBuildDocument returns the value at the dotted path
+func lookupBuildDocument(doc yaml.MapSlice, path string) (interface{}, bool) {
+	var value interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		section, ok := value.(yaml.MapSlice)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = getMapSliceEntry(section, key); !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// loadBuildDocument reads the build file and the files it builds upon. The
+// file named by extends is the base, the fragments listed by include are
+// merged over it in order, and the file itself is merged last. Paths are
+// relative to the file that names them. A base can list dotted keys under
+// locked that the fragments and the files extending it must not change.
+// The lock is only checked by the client, which does not stop a file from
+// dropping extends, so it guards against mistakes rather than enforcing a
+// policy.
+func loadBuildDocument(path string) (yaml.MapSlice, error) {
+	doc, _, err := loadExtendedBuildDocument(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	return deleteMapSliceEntry(doc, "locked"), nil
+}
+
+func loadExtendedBuildDocument(path string, depth int) (yaml.MapSlice, []string, error) {
+	if depth > maxBuildFileDepth {
+		return nil, nil, errors.Errorf("%v: build files extend or include each other too deeply", path)
+	}
+	buf, err := readBuildFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := buildfile.Decode(path, buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	extends, hasExtends := getMapSliceEntry(doc, "extends")
+	include, hasInclude := getMapSliceEntry(doc, "include")
+	if !hasExtends && !hasInclude {
+		return doc, nil, nil
+	}
+	doc = deleteMapSliceEntry(deleteMapSliceEntry(doc, "extends"), "include")
+
+	dir := filepath.Dir(path)
+	resolve := func(name string) string {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	base := yaml.MapSlice{}
+	locked := []string{}
+	parents := cast.ToStringSlice(include)
+	if hasExtends {
+		parents = append([]string{cast.ToString(extends)}, parents...)
+	}
+	for _, parent := range parents {
+		parentPath := resolve(parent)
+		parentDoc, parentLocked, err := loadExtendedBuildDocument(parentPath, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if keys, ok := getMapSliceEntry(parentDoc, "locked"); ok {
+			parentLocked = append(parentLocked, cast.ToStringSlice(keys)...)
+			parentDoc = deleteMapSliceEntry(parentDoc, "locked")
+		}
+		// the fragments are merged over the base, so they must not change
+		// what it locks either
+		if err := checkLockedKeys(parentPath, parentDoc, base, locked); err != nil {
+			return nil, nil, err
+		}
+		base = mergeBuildDocuments(base, parentDoc)
+		locked = append(locked, parentLocked...)
+	}
+	if err := checkLockedKeys(path, doc, base, locked); err != nil {
+		return nil, nil, err
+	}
+	return mergeBuildDocuments(base, doc), locked, nil
+}
+
+// checkLockedKeys fails if the document at path changes one of the locked
+// keys of the base
+func checkLockedKeys(path string, doc, base yaml.MapSlice, locked []string) error {
+	for _, key := range locked {
+		value, ok := lookupBuildDocument(doc, key)
+		if !ok {
+			continue
+		}
+		if baseValue, _ := lookupBuildDocument(base, key); !reflect.DeepEqual(value, baseValue) {
+			return errors.Errorf("%v: %v is locked by the build file it extends and can not be changed", path, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// buildParams are the --param name=value pairs that are substituted for
+// {{ .Params.name }} in the build file
+var buildParams []string
+
+var envReferencePattern = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// buildFileData is the data the build file template is executed with
+type buildFileData struct {
+	Params map[string]string
+}
+
+// parseBuildParams parses the name=value pairs
+func parseBuildParams(pairs []string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, pair := range pairs {
+		idx := strings.Index(pair, "=")
+		if idx <= 0 {
+			return nil, errors.Errorf("invalid parameter %v, expecting name=value", pair)
+		}
+		params[pair[:idx]] = pair[idx+1:]
+	}
+	return params, nil
+}
+
+// interpolateBuildFile replaces the ${env:VAR} references with the value
+// of the environment variables and executes the {{ }} template actions
+// with the --param values. Referring to a variable that is not set or to
+// a parameter that was not given is an error.
+func interpolateBuildFile(path string, buf []byte) ([]byte, error) {
+	var missing []string
+	buf = envReferencePattern.ReplaceAllFunc(buf, func(ref []byte) []byte {
+		name := string(envReferencePattern.FindSubmatch(ref)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return []byte(value)
+	})
+	if len(missing) != 0 {
+		return nil, errors.Errorf("%v: the environment variables %v are not set", path, strings.Join(missing, ", "))
+	}
+	if !bytes.Contains(buf, []byte("{{")) {
+		return buf, nil
+	}
+	params, err := parseBuildParams(buildParams)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(string(buf))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %v", path)
+	}
+	out := new(bytes.Buffer)
+	if err := tmpl.Execute(out, buildFileData{Params: params}); err != nil {
+		return nil, errors.Wrapf(err, "unable to substitute the parameters of %v, set them using --param name=value", path)
+	}
+	return out.Bytes(), nil
+}
+
+// readBuildFile reads the build file with the environment variables and
+// parameters substituted
+func readBuildFile(path string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return interpolateBuildFile(path, buf)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// buildProfile is the name of the entry of the profiles section of the
+// build file that is submitted
+var buildProfile string
+
+// buildProfileNames returns the names of the profiles of the document
+func buildProfileNames(doc yaml.MapSlice) []string {
+	profiles, _ := getMapSliceEntry(doc, "profiles")
+	section, _ := profiles.(yaml.MapSlice)
+	names := []string{}
+	for _, item := range section {
+		names = append(names, cast.ToString(item.Key))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectBuildProfile merges the sections of the profile into the document
+// and removes the profiles section, which is not submitted. Sections that
+// are mappings in both are merged key by key, the others are replaced.
+func selectBuildProfile(doc yaml.MapSlice, name string) (yaml.MapSlice, bool, error) {
+	names := buildProfileNames(doc)
+	profiles, declared := getMapSliceEntry(doc, "profiles")
+	if declared {
+		doc = deleteMapSliceEntry(doc, "profiles")
+	}
+	if name == "" {
+		return doc, declared, nil
+	}
+	section, _ := profiles.(yaml.MapSlice)
+	value, ok := getMapSliceEntry(section, name)
+	if !ok {
+		if len(names) == 0 {
+			return nil, false, errors.Errorf("the build file has no profiles, unable to select the %v profile", name)
+		}
+		return nil, false, errors.Errorf("unknown build profile %v, expecting one of %v", name, strings.Join(names, ", "))
+	}
+	profile, _ := value.(yaml.MapSlice)
+	for _, item := range profile {
+		key := cast.ToString(item.Key)
+		base, _ := getMapSliceEntry(doc, key)
+		baseSection, baseOk := base.(yaml.MapSlice)
+		overrides, ok := item.Value.(yaml.MapSlice)
+		if !ok || !baseOk {
+			doc = setMapSliceEntry(doc, key, item.Value)
+			continue
+		}
+		for _, override := range overrides {
+			baseSection = setMapSliceEntry(baseSection, cast.ToString(override.Key), override.Value)
+		}
+		doc = setMapSliceEntry(doc, key, baseSection)
+	}
+	return doc, true, nil
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"github.com/Unknwon/com"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// resourceRequest is what the build file asks of the worker
+type resourceRequest struct {
+	GPUs   int
+	CPUs   int
+	Memory uint64
+}
+
+// normalizeResources rewrites the shorthand resources.gpus: N and
+// resources.cpu: N into the gpu.count and cpu.count keys of the build
+// specification
+func normalizeResources(doc yaml.MapSlice) (yaml.MapSlice, bool) {
+	value, ok := getMapSliceEntry(doc, "resources")
+	resources, _ := value.(yaml.MapSlice)
+	if !ok || resources == nil {
+		return doc, false
+	}
+	changed := false
+	if gpus, ok := getMapSliceEntry(resources, "gpus"); ok {
+		gpu, _ := getMapSliceEntry(resources, "gpu")
+		section, _ := gpu.(yaml.MapSlice)
+		resources = setMapSliceEntry(deleteMapSliceEntry(resources, "gpus"), "gpu", setMapSliceEntry(section, "count", gpus))
+		changed = true
+	}
+	if cpu, ok := getMapSliceEntry(resources, "cpu"); ok {
+		if _, isSection := cpu.(yaml.MapSlice); !isSection {
+			resources = setMapSliceEntry(resources, "cpu", yaml.MapSlice{{Key: "count", Value: cpu}})
+			changed = true
+		}
+	}
+	if !changed {
+		return doc, false
+	}
+	return setMapSliceEntry(doc, "resources", resources), true
+}
+
+// requestedResources returns the resources requested by the build file
+func requestedResources(doc yaml.MapSlice) (resourceRequest, error) {
+	doc, _ = normalizeResources(doc)
+	request := resourceRequest{}
+	value, _ := getMapSliceEntry(doc, "resources")
+	resources, _ := value.(yaml.MapSlice)
+	if gpu, ok := getMapSliceEntry(resources, "gpu"); ok {
+		section, _ := gpu.(yaml.MapSlice)
+		count, _ := getMapSliceEntry(section, "count")
+		request.GPUs = cast.ToInt(count)
+	}
+	if cpu, ok := getMapSliceEntry(resources, "cpu"); ok {
+		section, _ := cpu.(yaml.MapSlice)
+		count, _ := getMapSliceEntry(section, "count")
+		request.CPUs = cast.ToInt(count)
+	}
+	if memory, ok := getMapSliceEntry(resources, "memory"); ok {
+		bytes, err := humanize.ParseBytes(cast.ToString(memory))
+		if err != nil {
+			return request, errors.Errorf("invalid resources.memory %v, expecting a size such as 8GiB", memory)
+		}
+		request.Memory = bytes
+	}
+	return request, nil
+}
+
+// checkResources fails if the build file requests more resources than the
+// queue allows
+func checkResources(queueName string) error {
+	queue, err := findQueue(queueName)
+	if err != nil || queue == nil {
+		return err
+	}
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return nil
+	}
+	_, doc, _, err := readBuildDocument(path)
+	if err != nil {
+		return err
+	}
+	request, err := requestedResources(doc)
+	if err != nil {
+		return err
+	}
+	if queue.MaxGPUs > 0 && request.GPUs > queue.MaxGPUs {
+		unit := "GPUs"
+		if queue.MaxGPUs == 1 {
+			unit = "GPU"
+		}
+		return errors.Errorf("queue %v allows at most %v %v, the build file requests %v", queue.Name, queue.MaxGPUs, unit, request.GPUs)
+	}
+	if queue.MaxCPUs > 0 && request.CPUs > queue.MaxCPUs {
+		return errors.Errorf("queue %v allows at most %v CPUs, the build file requests %v", queue.Name, queue.MaxCPUs, request.CPUs)
+	}
+	if queue.MaxMemory != "" && request.Memory > 0 {
+		limit, err := humanize.ParseBytes(queue.MaxMemory)
+		if err != nil {
+			return errors.Wrapf(err, "invalid max_memory for the %v queue", queue.Name)
+		}
+		if request.Memory > limit {
+			return errors.Errorf("queue %v allows at most %v of memory, the build file requests %v",
+				queue.Name, humanize.IBytes(limit), humanize.IBytes(request.Memory))
+		}
+	}
+	return nil
+}
+
+func init() {
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		doc, changed := normalizeResources(doc)
+		return doc, changed, nil
+	})
+}
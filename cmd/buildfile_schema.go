@@ -0,0 +1,548 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// buildFileSchemas are the JSON schemas of the build file, by the version
+// in rai.version. Only the subset of JSON schema that is needed to describe
+// the build file is supported: type, properties, additionalProperties,
+// required, items and $ref to the properties or definitions of the root.
+var buildFileSchemas = map[string]string{
+	"0.2": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "rai_build.yml",
+  "definitions": {
+    "step": {
+      "type": ["string", "object"],
+      "required": ["run"],
+      "additionalProperties": false,
+      "properties": {
+        "run": {"type": "string"},
+        "retries": {"type": "integer"},
+        "when": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "exists": {"type": "string"},
+            "not_exists": {"type": "string"},
+            "param": {"type": "string"}
+          }
+        }
+      }
+    }
+  },
+  "type": "object",
+  "required": ["rai", "commands"],
+  "additionalProperties": false,
+  "properties": {
+    "rai": {
+      "type": "object",
+      "required": ["version"],
+      "additionalProperties": false,
+      "properties": {
+        "version": {"type": ["string", "number"]},
+        "image": {"type": "string"}
+      }
+    },
+    "resources": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "cpu": {
+          "type": ["object", "integer"],
+          "additionalProperties": false,
+          "properties": {
+            "architecture": {"type": "string"},
+            "count": {"type": "integer"}
+          }
+        },
+        "gpu": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "architecture": {"type": "string"},
+            "count": {"type": "integer"}
+          }
+        },
+        "gpus": {"type": "integer"},
+        "memory": {"type": "string"},
+        "network": {"type": "boolean"}
+      }
+    },
+    "commands": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "build_image": {
+          "type": "object",
+          "required": ["image_name", "dockerfile"],
+          "additionalProperties": false,
+          "properties": {
+            "image_name": {"type": "string"},
+            "dockerfile": {"type": "string"},
+            "no_cache": {"type": "boolean"},
+            "push": {
+              "type": "object",
+              "additionalProperties": false,
+              "properties": {
+                "push": {"type": "boolean"},
+                "credentials": {
+                  "type": "object",
+                  "additionalProperties": false,
+                  "properties": {
+                    "username": {"type": "string"},
+                    "password": {"type": "string"}
+                  }
+                }
+              }
+            }
+          }
+        },
+        "build": {"type": "array", "items": {"$ref": "#/definitions/step"}},
+        "on_failure": {"type": "array", "items": {"$ref": "#/definitions/step"}},
+        "always": {"type": "array", "items": {"$ref": "#/definitions/step"}},
+        "timeout": {"type": "string"}
+      }
+    },
+    "timeout": {"type": "string"},
+    "includes": {"type": "array", "items": {"type": "string"}},
+    "executables": {"type": "array", "items": {"type": "string"}},
+    "secrets": {"type": "array", "items": {"type": "string"}},
+    "artifacts": {"type": "array", "items": {"type": "string"}},
+    "test_results": {"type": "string"},
+    "cache": {
+      "type": "object",
+      "required": ["key", "paths"],
+      "additionalProperties": false,
+      "properties": {
+        "key": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "files": {"type": "array", "items": {"type": "string"}}
+          }
+        },
+        "paths": {"type": "array", "items": {"type": "string"}}
+      }
+    },
+    "matrix": {
+      "type": "object",
+      "additionalProperties": {"type": "array", "items": {"type": ["string", "number", "boolean"]}}
+    },
+    "profiles": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "resources": {"$ref": "#/properties/resources"},
+          "commands": {"$ref": "#/properties/commands"},
+          "includes": {"$ref": "#/properties/includes"},
+          "executables": {"$ref": "#/properties/executables"}
+        }
+      }
+    }
+  }
+}`,
+}
+
+// defaultBuildFileSchemaVersion is used when the build file declares a
+// version that has no schema
+const defaultBuildFileSchemaVersion = "0.2"
+
+// strictBuildFile turns unknown keys in the build file into errors
+var strictBuildFile bool
+
+// schemaNode is a node of a JSON schema
+type schemaNode struct {
+	Ref string `json:"$ref"`
+	// Definitions are the nodes that can be referred to by #/definitions/
+	Definitions map[string]*schemaNode `json:"definitions"`
+	// Type is either a single type name or a list of them
+	Type       interface{}            `json:"type"`
+	Properties map[string]*schemaNode `json:"properties"`
+	// AdditionalProperties is either a boolean or the schema of the
+	// properties that are not listed
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	Required             []string        `json:"required"`
+	Items                *schemaNode     `json:"items"`
+}
+
+// additional returns whether properties that are not listed are allowed
+// and the schema they must conform to, if any
+func (n *schemaNode) additional() (bool, *schemaNode) {
+	switch strings.TrimSpace(string(n.AdditionalProperties)) {
+	case "", "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	node := &schemaNode{}
+	if err := json.Unmarshal(n.AdditionalProperties, node); err != nil {
+		return true, nil
+	}
+	return true, node
+}
+
+// resolve returns the node that the $ref of the node points to
+func (n *schemaNode) resolve(root *schemaNode) *schemaNode {
+	targets := map[string]map[string]*schemaNode{
+		"#/properties/":  root.Properties,
+		"#/definitions/": root.Definitions,
+	}
+	for prefix, nodes := range targets {
+		if !strings.HasPrefix(n.Ref, prefix) {
+			continue
+		}
+		if target, ok := nodes[strings.TrimPrefix(n.Ref, prefix)]; ok {
+			return target
+		}
+	}
+	return n
+}
+
+func (n *schemaNode) types() []string {
+	if n.Type == nil {
+		return nil
+	}
+	if name, ok := n.Type.(string); ok {
+		return []string{name}
+	}
+	return cast.ToStringSlice(n.Type)
+}
+
+// buildFileSchema returns the schema for the version of the build file
+func buildFileSchema(version string) (*schemaNode, error) {
+	text, ok := buildFileSchemas[version]
+	if !ok {
+		text = buildFileSchemas[defaultBuildFileSchemaVersion]
+	}
+	schema := &schemaNode{}
+	if err := json.Unmarshal([]byte(text), schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// schemaViolation is a place where the build file does not conform to
+// the schema
+type schemaViolation struct {
+	Path    []string
+	Line    int
+	Column  int
+	Message string
+	// Unknown is true if the violation is a key that is not in the schema
+	Unknown bool
+}
+
+// String describes the violation by the path of the key
+func (v schemaViolation) String() string {
+	location := strings.Replace(strings.Join(v.Path, "."), ".[", "[", -1)
+	if location == "" {
+		location = "the build file"
+	}
+	return fmt.Sprintf("%v: %v", location, v.Message)
+}
+
+// format describes the violation within the file, prefixed with its
+// line and column when they are known
+func (v schemaViolation) format(file string) string {
+	if v.Line > 0 {
+		return fmt.Sprintf("%v:%v:%v: %v", file, v.Line, v.Column, v)
+	}
+	return fmt.Sprintf("%v: %v", file, v)
+}
+
+// schemaType returns the JSON schema type name of a decoded YAML value
+func schemaType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case yaml.MapSlice, map[interface{}]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func matchesType(value interface{}, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	actual := schemaType(value)
+	for _, expected := range types {
+		if expected == actual || (expected == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSchema returns the places where the value does not conform to
+// the schema node. References are resolved against the root schema.
+func validateSchema(root, node *schemaNode, value interface{}, path []string) []schemaViolation {
+	node = node.resolve(root)
+	violations := []schemaViolation{}
+	if !matchesType(value, node.types()) {
+		return append(violations, schemaViolation{
+			Path:    path,
+			Message: fmt.Sprintf("expected %v but found %v", strings.Join(node.types(), " or "), schemaType(value)),
+		})
+	}
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		known := []string{}
+		for name := range node.Properties {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		for _, name := range node.Required {
+			if _, ok := getMapSliceEntry(value, name); !ok {
+				violations = append(violations, schemaViolation{
+					Path:    path,
+					Message: fmt.Sprintf("the %v key is required", name),
+				})
+			}
+		}
+		for _, item := range value {
+			key := cast.ToString(item.Key)
+			child := append(append([]string{}, path...), key)
+			if prop, ok := node.Properties[key]; ok {
+				violations = append(violations, validateSchema(root, prop, item.Value, child)...)
+				continue
+			}
+			allowed, schema := node.additional()
+			if schema != nil {
+				violations = append(violations, validateSchema(root, schema, item.Value, child)...)
+				continue
+			}
+			if !allowed {
+				msg := "unknown key " + key
+				if suggestion := didYouMean(key, known); suggestion != "" {
+					msg += fmt.Sprintf(", did you mean %v?", suggestion)
+				}
+				violations = append(violations, schemaViolation{
+					Path:    child,
+					Message: msg,
+					Unknown: true,
+				})
+			}
+		}
+	case []interface{}:
+		if node.Items == nil {
+			break
+		}
+		for ii, item := range value {
+			child := append(append([]string{}, path...), fmt.Sprintf("[%d]", ii))
+			violations = append(violations, validateSchema(root, node.Items, item, child)...)
+		}
+	}
+	return violations
+}
+
+// didYouMean returns the known name that is closest to the name, if it is
+// close enough to be a typo
+func didYouMean(name string, known []string) string {
+	best, bestDistance := "", len(name)/3+2
+	for _, candidate := range known {
+		if distance := editDistance(name, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between the strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for jj := range prev {
+		prev[jj] = jj
+	}
+	for ii := 1; ii <= len(a); ii++ {
+		curr[0] = ii
+		for jj := 1; jj <= len(b); jj++ {
+			cost := 1
+			if a[ii-1] == b[jj-1] {
+				cost = 0
+			}
+			curr[jj] = minInt(minInt(prev[jj]+1, curr[jj-1]+1), prev[jj-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+var yamlKeyPattern = regexp.MustCompile(`^(\s*(?:-\s+)?)([^\s#'"\-][^:#]*?|'[^']*'|"[^"]*")\s*:(\s|$)`)
+
+var (
+	yamlAnchorPattern = regexp.MustCompile(`^&([^\s,\[\]{}]+)`)
+	yamlAliasPattern  = regexp.MustCompile(`\*([^\s,\[\]{}]+)`)
+	yamlAliasesValue  = regexp.MustCompile(`^(\*[^\s,\[\]{}]+|\[\s*\*[^\]]*\])\s*(#.*)?$`)
+)
+
+// yamlKeyLine is a line of the YAML source that holds a key
+type yamlKeyLine struct {
+	Line, Column int
+	// Path are the keys leading to the key, including it
+	Path []string
+	// Anchor is the anchor defined by the value of the key
+	Anchor string
+	// Aliases are the anchors that the value of the key refers to, which
+	// for a merge key (<<) are merged into the enclosing mapping
+	Aliases []string
+}
+
+// scanYAMLKeys lists the keys of the YAML source along with the anchors
+// and aliases of their values. Sequence items are not part of the path.
+func scanYAMLKeys(buf []byte) []yamlKeyLine {
+	type entry struct {
+		indent int
+		key    string
+	}
+	stack := []entry{}
+	lines := []yamlKeyLine{}
+	for ii, line := range strings.Split(string(buf), "\n") {
+		match := yamlKeyPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		indent := len(match[1])
+		key := strings.Trim(match[2], `'"`)
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, entry{indent: indent, key: key})
+		keyLine := yamlKeyLine{Line: ii + 1, Column: indent + 1}
+		for _, e := range stack {
+			keyLine.Path = append(keyLine.Path, e.key)
+		}
+		value := strings.TrimSpace(line[len(match[0]):])
+		if anchor := yamlAnchorPattern.FindStringSubmatch(value); anchor != nil {
+			keyLine.Anchor = anchor[1]
+		} else if yamlAliasesValue.MatchString(value) {
+			for _, alias := range yamlAliasPattern.FindAllStringSubmatch(value, -1) {
+				keyLine.Aliases = append(keyLine.Aliases, alias[1])
+			}
+		}
+		lines = append(lines, keyLine)
+	}
+	return lines
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ii := range a {
+		if a[ii] != b[ii] {
+			return false
+		}
+	}
+	return true
+}
+
+// locateKey returns the line and column of the key at the path in the
+// YAML source. Sequence indices in the path are skipped. A key that comes
+// from an alias or a merge key is located where the anchor it refers to
+// defines it. It returns zeros if the key can not be found.
+func locateKey(buf []byte, path []string) (int, int) {
+	keys := []string{}
+	for _, segment := range path {
+		if !strings.HasPrefix(segment, "[") {
+			keys = append(keys, segment)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, 0
+	}
+	lines := scanYAMLKeys(buf)
+	anchors := map[string][]string{}
+	for _, line := range lines {
+		if line.Anchor != "" {
+			anchors[line.Anchor] = line.Path
+		}
+	}
+	var locate func(keys []string, depth int) (int, int)
+	locate = func(keys []string, depth int) (int, int) {
+		for _, line := range lines {
+			if equalPaths(line.Path, keys) {
+				return line.Line, line.Column
+			}
+		}
+		if depth > maxBuildFileDepth {
+			return 0, 0
+		}
+		// look for the longest prefix of the path that is an alias or
+		// that merges an anchor
+		for n := len(keys) - 1; n >= 1; n-- {
+			prefix := keys[:n]
+			merge := append(append([]string{}, prefix...), "<<")
+			for _, line := range lines {
+				if !equalPaths(line.Path, merge) && !equalPaths(line.Path, prefix) {
+					continue
+				}
+				for _, alias := range line.Aliases {
+					anchor, ok := anchors[alias]
+					if !ok {
+						continue
+					}
+					target := append(append([]string{}, anchor...), keys[n:]...)
+					if row, column := locate(target, depth+1); row > 0 {
+						return row, column
+					}
+				}
+			}
+		}
+		return 0, 0
+	}
+	return locate(keys, 0)
+}
+
+// validateBuildFile checks the build document, with its aliases expanded,
+// against the schema of its version. The violations are located within
+// the content of the build file, so keys that come from the files it
+// extends have no location.
+func validateBuildFile(buf []byte, doc yaml.MapSlice) ([]schemaViolation, error) {
+	version := ""
+	if rai, ok := getMapSliceEntry(doc, "rai"); ok {
+		if section, ok := rai.(yaml.MapSlice); ok {
+			v, _ := getMapSliceEntry(section, "version")
+			version = cast.ToString(v)
+		}
+	}
+	schema, err := buildFileSchema(version)
+	if err != nil {
+		return nil, err
+	}
+	doc, _ = withoutExtensionKeys(doc)
+	violations := validateSchema(schema, schema, doc, nil)
+	for ii := range violations {
+		violations[ii].Line, violations[ii].Column = locateKey(buf, violations[ii].Path)
+	}
+	return violations, nil
+}
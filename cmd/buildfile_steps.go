@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// buildStepCondition parses the when section of a build step. A step can
+// run only if a file exists on the worker, or only if a parameter given
+// using --param has a value.
+func buildStepCondition(when yaml.MapSlice, params map[string]string) (string, bool, error) {
+	tests := []string{}
+	for _, item := range when {
+		value := cast.ToString(item.Value)
+		switch key := cast.ToString(item.Key); key {
+		case "exists":
+			tests = append(tests, "[ -e "+shellQuote(value)+" ]")
+		case "not_exists":
+			tests = append(tests, "[ ! -e "+shellQuote(value)+" ]")
+		case "param":
+			idx := strings.Index(value, "=")
+			if idx <= 0 {
+				return "", false, errors.Errorf("invalid when.param %v, expecting name=value", value)
+			}
+			if params[value[:idx]] != value[idx+1:] {
+				return "", false, nil
+			}
+		default:
+			return "", false, errors.Errorf("unknown build step condition %v, expecting exists, not_exists or param", key)
+		}
+	}
+	return strings.Join(tests, " && "), true, nil
+}
+
+// withRetries runs the command again, up to retries more times, while it
+// fails. The loop ends with the status of the last attempt.
+func withRetries(command string, retries int) string {
+	attempts := []string{}
+	for ii := 1; ii <= retries+1; ii++ {
+		attempts = append(attempts, strconv.Itoa(ii))
+	}
+	return "for RAI_ATTEMPT in " + strings.Join(attempts, " ") + "; do\n" +
+		"if [ $RAI_ATTEMPT -gt 1 ]; then echo \"retrying, attempt $RAI_ATTEMPT of " + strconv.Itoa(retries+1) + "\"; fi\n" +
+		"(\n" + command + "\n) && break\ndone"
+}
+
+// compileBuildSteps turns the steps of a command list into commands. A
+// step is either a command or a mapping with the command under run and
+// its condition under when and the number of retries under retries. Steps whose parameter condition does not hold
+// are dropped, while file conditions are tested on the worker.
+func compileBuildSteps(section string, steps interface{}, params map[string]string) ([]string, error) {
+	list, ok := steps.([]interface{})
+	if !ok {
+		return cast.ToStringSlice(steps), nil
+	}
+	commands := []string{}
+	for ii, step := range list {
+		mapping, ok := step.(yaml.MapSlice)
+		if !ok {
+			commands = append(commands, cast.ToString(step))
+			continue
+		}
+		run, ok := getMapSliceEntry(mapping, "run")
+		if !ok {
+			return nil, errors.Errorf("commands.%v[%v] has no run command", section, ii)
+		}
+		when, _ := getMapSliceEntry(mapping, "when")
+		whenSection, _ := when.(yaml.MapSlice)
+		test, enabled, err := buildStepCondition(whenSection, params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "commands.%v[%v]", section, ii)
+		}
+		if !enabled {
+			continue
+		}
+		command := cast.ToString(run)
+		if value, ok := getMapSliceEntry(mapping, "retries"); ok {
+			retries, err := cast.ToIntE(value)
+			if err != nil || retries < 0 {
+				return nil, errors.Errorf("invalid commands.%v[%v].retries %v, expecting a number", section, ii, value)
+			}
+			if retries > 0 {
+				command = withRetries(command, retries)
+			}
+		}
+		if test != "" {
+			command = "if " + test + "; then\n" + command + "\nfi"
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+// withFailureHandling combines the build commands into one command that
+// runs the on_failure commands when one of them fails and the always
+// commands in any case. Every command still runs in its own shell.
+func withFailureHandling(build, onFailure, always []string) string {
+	subshells := func(commands []string) string {
+		parts := []string{}
+		for _, command := range commands {
+			parts = append(parts, "(\n"+command+"\n)")
+		}
+		return strings.Join(parts, " && ")
+	}
+	script := subshells(build) + "\nRAI_STATUS=$?\n"
+	if len(onFailure) != 0 {
+		script += "if [ $RAI_STATUS -ne 0 ]; then\n" + subshells(onFailure) + "\nfi\n"
+	}
+	if len(always) != 0 {
+		script += subshells(always) + "\n"
+	}
+	return script + "(exit $RAI_STATUS)"
+}
+
+// compileBuildFileSteps rewrites the build steps, on_failure and always
+// sections of the document into plain build commands
+func compileBuildFileSteps(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+	value, _ := getMapSliceEntry(doc, "commands")
+	section, _ := value.(yaml.MapSlice)
+	steps, hasBuild := getMapSliceEntry(section, "build")
+	onFailureSteps, hasOnFailure := getMapSliceEntry(section, "on_failure")
+	alwaysSteps, hasAlways := getMapSliceEntry(section, "always")
+	if !hasBuild && !hasOnFailure && !hasAlways {
+		return doc, false, nil
+	}
+	params, err := parseBuildParams(buildParams)
+	if err != nil {
+		return nil, false, err
+	}
+	build, err := compileBuildSteps("build", steps, params)
+	if err != nil {
+		return nil, false, err
+	}
+	onFailure, err := compileBuildSteps("on_failure", onFailureSteps, params)
+	if err != nil {
+		return nil, false, err
+	}
+	always, err := compileBuildSteps("always", alwaysSteps, params)
+	if err != nil {
+		return nil, false, err
+	}
+	changed := hasOnFailure || hasAlways
+	if list, ok := steps.([]interface{}); ok {
+		for _, step := range list {
+			if _, ok := step.(yaml.MapSlice); ok {
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return doc, false, nil
+	}
+	section = deleteMapSliceEntry(deleteMapSliceEntry(section, "on_failure"), "always")
+	if len(build) != 0 && (len(onFailure) != 0 || len(always) != 0) {
+		// commands.timeout limits each step, so it is applied before the
+		// steps are merged into a single command
+		timeout, ok, err := commandTimeout(section)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			build = withTimeouts(build, timeout)
+			onFailure = withTimeouts(onFailure, timeout)
+			always = withTimeouts(always, timeout)
+			section = deleteMapSliceEntry(section, "timeout")
+		}
+		build = []string{withFailureHandling(build, onFailure, always)}
+	}
+	doc = setMapSliceEntry(doc, "commands", section)
+	return setBuildCommands(doc, build), true, nil
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// waitTimeout bounds how long the client waits for the job to finish. It
+// defaults to the timeout of the build file.
+var waitTimeout time.Duration
+
+// parseBuildTimeout parses a duration of the build file, such as 10m
+func parseBuildTimeout(key string, value interface{}) (time.Duration, error) {
+	timeout, err := time.ParseDuration(cast.ToString(value))
+	if err != nil || timeout <= 0 {
+		return 0, errors.Errorf("invalid %v %v, expecting a duration such as 10m", key, value)
+	}
+	return timeout, nil
+}
+
+// withTimeout runs the command in a shell that is killed after the timeout
+func withTimeout(command string, timeout time.Duration) string {
+	seconds := int(timeout / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return fmt.Sprintf("timeout %v sh -c %v", seconds, shellQuote(command))
+}
+
+// withTimeouts runs each of the commands with the timeout
+func withTimeouts(commands []string, timeout time.Duration) []string {
+	out := make([]string, len(commands))
+	for ii, command := range commands {
+		out[ii] = withTimeout(command, timeout)
+	}
+	return out
+}
+
+// commandTimeout returns the commands.timeout of the commands section
+func commandTimeout(section yaml.MapSlice) (time.Duration, bool, error) {
+	value, ok := getMapSliceEntry(section, "timeout")
+	if !ok {
+		return 0, false, nil
+	}
+	timeout, err := parseBuildTimeout("commands.timeout", value)
+	if err != nil {
+		return 0, false, err
+	}
+	return timeout, true, nil
+}
+
+// jobTimeout returns the timeout of the whole job, which is given using
+// --wait-timeout or the timeout key of the build file
+func jobTimeout() (time.Duration, error) {
+	if waitTimeout > 0 {
+		return waitTimeout, nil
+	}
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return 0, nil
+	}
+	_, doc, _, err := readBuildDocument(path)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := getMapSliceEntry(doc, "timeout")
+	if !ok {
+		return 0, nil
+	}
+	return parseBuildTimeout("timeout", value)
+}
+
+// waitWithTimeout waits for the job to finish, giving up after the timeout
+// if it is set
+func waitWithTimeout(wait func() error, timeout time.Duration) error {
+	if timeout <= 0 {
+		return wait()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return withExitCode(exitCodeTimeout, errors.Errorf("the job did not finish within %v", timeout))
+	}
+}
+
+func init() {
+	// every build command is run by timeout(1) on the worker, which kills
+	// it once commands.timeout elapses. When the steps were merged with
+	// on_failure or always, compileBuildFileSteps already applied it to
+	// each step. The job timeout is only enforced by the client, which
+	// stops waiting for the job, so it is not submitted and the server
+	// does not stop the job.
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		_, hasJobTimeout := getMapSliceEntry(doc, "timeout")
+		doc = deleteMapSliceEntry(doc, "timeout")
+		commands, _ := getMapSliceEntry(doc, "commands")
+		section, _ := commands.(yaml.MapSlice)
+		timeout, ok, err := commandTimeout(section)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return doc, hasJobTimeout, nil
+		}
+		doc = setMapSliceEntry(doc, "commands", deleteMapSliceEntry(section, "timeout"))
+		build := buildCommands(doc)
+		if len(build) == 0 {
+			return doc, true, nil
+		}
+		return setBuildCommands(doc, withTimeouts(build, timeout)), true, nil
+	})
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Unknwon/com"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+var showConfigOrigin bool
+
+// userConfigPath returns the location of the configuration file that
+// overrides the embedded configuration
+func userConfigPath() (string, error) {
+	return homedir.Expand("~/.rai_config.yml")
+}
+
+func readUserConfig() (map[interface{}]interface{}, error) {
+	path, err := userConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg := map[interface{}]interface{}{}
+	if !com.IsFile(path) {
+		return cfg, nil
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(buf, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the configuration file %v", path)
+	}
+	return cfg, nil
+}
+
+func writeUserConfig(cfg map[interface{}]interface{}) error {
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	buf, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0600)
+}
+
+// mergeConfig overlays the override configuration on top of the base one
+func mergeConfig(base, override map[interface{}]interface{}) map[interface{}]interface{} {
+	for key, value := range override {
+		overrideSection, ok := value.(map[interface{}]interface{})
+		baseSection, isSection := base[key].(map[interface{}]interface{})
+		if ok && isSection {
+			base[key] = mergeConfig(baseSection, overrideSection)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
+
+// withUserConfig returns the configuration content with the user
+// configuration file applied to it
+func withUserConfig(content string) string {
+	user, err := readUserConfig()
+	if err != nil || len(user) == 0 {
+		return content
+	}
+	base := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal([]byte(content), &base); err != nil {
+		return content
+	}
+	buf, err := yaml.Marshal(mergeConfig(base, user))
+	if err != nil {
+		return content
+	}
+	return string(buf)
+}
+
+// lookupConfig finds the dotted key (e.g. app.color) within the configuration
+func lookupConfig(cfg map[interface{}]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+	var current interface{} = cfg
+	for _, part := range parts {
+		section, ok := current.(map[interface{}]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = section[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setConfig sets the dotted key within the configuration, creating the
+// sections as needed
+func setConfig(cfg map[interface{}]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	section := cfg
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := section[part].(map[interface{}]interface{})
+		if !ok {
+			next = map[interface{}]interface{}{}
+			section[part] = next
+		}
+		section = next
+	}
+	section[parts[len(parts)-1]] = value
+}
+
+// isSecretConfigKey returns true for keys whose values must not be printed
+func isSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range []string{"password", "secret", "token", "access_key"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatConfigValue(key string, value interface{}) string {
+	if isSecretConfigKey(key) {
+		return "********"
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	buf, err := yaml.Marshal(value)
+	if err != nil {
+		return cast.ToString(value)
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// configOrigin returns where the value of the key comes from
+func configOrigin(key string, user map[interface{}]interface{}) string {
+	// the flags that are bound to the configuration
+	switch key {
+	case "app.debug", "app.verbose", "app.color":
+		if flag := RootCmd.PersistentFlags().Lookup(strings.TrimPrefix(key, "app.")); flag != nil && flag.Changed {
+			return "flag"
+		}
+	}
+	if _, ok := lookupConfig(user, key); ok {
+		path, _ := userConfigPath()
+		return path
+	}
+	return "default"
+}
+
+var configCmd = &cobra.Command{
+	Use:          "config",
+	Short:        "Get and set the configuration of the client.",
+	Long:         `Get and set the configuration of the client. The values that are set are stored in ~/.rai_config.yml and override the default configuration.`,
+	SilenceUsage: true,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:          "get <key>",
+	Short:        "Prints the value of a configuration key.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := strings.ToLower(args[0])
+		if !viper.IsSet(key) {
+			return errors.Errorf("the configuration key %v is not set", key)
+		}
+		fmt.Println(formatConfigValue(key, viper.Get(key)))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:          "set <key> <value>",
+	Short:        "Sets the value of a configuration key.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := strings.ToLower(args[0])
+		// the value is parsed as yaml so that booleans, numbers and lists
+		// keep their types
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(args[1]), &value); err != nil {
+			value = args[1]
+		}
+		cfg, err := readUserConfig()
+		if err != nil {
+			return err
+		}
+		setConfig(cfg, key, value)
+		return writeUserConfig(cfg)
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:          "unset <key>",
+	Short:        "Removes a configuration key from the user configuration.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := strings.ToLower(args[0])
+		cfg, err := readUserConfig()
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(key, ".")
+		section := cfg
+		if len(parts) > 1 {
+			parent, ok := lookupConfig(cfg, strings.Join(parts[:len(parts)-1], "."))
+			if section, ok = parent.(map[interface{}]interface{}); !ok {
+				return nil
+			}
+		}
+		delete(section, parts[len(parts)-1])
+		return writeUserConfig(cfg)
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:          "list",
+	Aliases:      []string{"ls"},
+	Short:        "Lists the configuration.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := readUserConfig()
+		if err != nil {
+			return err
+		}
+		keys := viper.AllKeys()
+		sort.Strings(keys)
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAutoWrapText(false)
+		if showConfigOrigin {
+			table.SetHeader([]string{"Key", "Value", "Origin"})
+		} else {
+			table.SetHeader([]string{"Key", "Value"})
+		}
+		for _, key := range keys {
+			row := []string{key, formatConfigValue(key, viper.Get(key))}
+			if showConfigOrigin {
+				row = append(row, configOrigin(key, user))
+			}
+			table.Append(row)
+		}
+		table.Render()
+		return nil
+	},
+}
+
+func init() {
+	configListCmd.Flags().BoolVar(&showConfigOrigin, "show-origin", false, "Show where each value comes from.")
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+	RootCmd.AddCommand(configCmd)
+}
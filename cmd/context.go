@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/xlab/closer"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// rootContext is the context that the jobs run with. Programs that embed
+// rai set it with ExecuteContext to time out or cancel the jobs.
+var rootContext = context.Background()
+
+// ExecuteContext runs rai like Execute, but leaves the signals to the
+// program. The job is stopped when the context is done.
+func ExecuteContext(ctx context.Context) error {
+	rootContext = ctx
+	return execute()
+}
+
+var (
+	interruptMu      sync.Mutex
+	interruptCancel  context.CancelFunc
+	interruptJobID   string
+	interruptPending bool
+)
+
+// withInterrupt returns a context that is cancelled when rai is
+// interrupted. While it is in use, Ctrl-C asks whether to cancel the job
+// instead of exiting rai, and a second Ctrl-C exits right away.
+func withInterrupt(parent context.Context, jobID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	interruptMu.Lock()
+	interruptCancel, interruptJobID = cancel, jobID
+	interruptMu.Unlock()
+	return ctx, func() {
+		interruptMu.Lock()
+		interruptCancel = nil
+		interruptPending = false
+		interruptMu.Unlock()
+		cancel()
+	}
+}
+
+// interrupt cancels the context of the job that is running, and returns
+// false if there is none
+func interrupt() bool {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	if interruptCancel == nil {
+		return false
+	}
+	interruptCancel()
+	interruptCancel = nil
+	return true
+}
+
+// stopJobMessage is printed when rai stops the job that is running
+const stopJobMessage = "Stopping. A job that was already queued keeps running on the server. Press Ctrl-C again to exit right away."
+
+// handleInterrupt stops the job that is running when rai is interrupted,
+// after asking for confirmation unless --cancel-on-interrupt is set, there
+// is no terminal to ask on, or the job was cancelled by `rai job cancel`.
+// Only the local steps are stopped: the client library has no request to
+// cancel a job on the server, so a published job keeps running there.
+// It returns false if rai should exit right away: when no job is running
+// or when it was already interrupted.
+func handleInterrupt() bool {
+	interruptMu.Lock()
+	running, pending, jobID := interruptCancel != nil, interruptPending, interruptJobID
+	interruptPending = running
+	interruptMu.Unlock()
+	if !running || pending {
+		return false
+	}
+	cancelled := false
+	if job, err := loadJobRecord(jobID); err == nil {
+		cancelled = job.Phase == jobPhaseCancelled
+	}
+	if cancelled || viper.GetBool("client.cancel_on_interrupt") || !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, stopJobMessage)
+		interrupt()
+		return true
+	}
+	go func() {
+		answer, err := prompt(bufio.NewReader(os.Stdin), "\nStop uploading or waiting for the job [y/N]", false)
+		if err == nil && strings.ToLower(answer) == "y" {
+			fmt.Fprintln(os.Stderr, stopJobMessage)
+			interrupt()
+			return
+		}
+		fmt.Println("Still waiting for the job.")
+		interruptMu.Lock()
+		interruptPending = false
+		interruptMu.Unlock()
+	}()
+	return true
+}
+
+// contextError returns the error of a job whose context is done
+func contextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return withExitCode(exitCodeTimeout, errors.New("the job did not finish before the deadline"))
+	}
+	return withExitCode(exitCodeCancelled, errors.New("the job was cancelled"))
+}
+
+// clientStep runs the step of the client until it returns or the context
+// is done. The client cannot interrupt its steps, so a step that is given
+// up on is left to finish in the background. Every run of the step is a
+// span of the trace of the job.
+func clientStep(ctx context.Context, name string, step func() error) func() error {
+	return func() (err error) {
+		end := traceFromContext(ctx).span(name)
+		defer func() {
+			end(err)
+		}()
+		if err := ctx.Err(); err != nil {
+			return contextError(err)
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- step()
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return contextError(ctx.Err())
+		}
+	}
+}
+
+var signalHandlersOnce sync.Once
+
+// installSignalHandlers makes Ctrl-C stop the job that is running rather
+// than exit rai. It is done by Execute, not when the package is imported,
+// so that programs that embed rai keep their own handling of the signals.
+func installSignalHandlers() {
+	signalHandlersOnce.Do(func() {
+		// Ctrl-C is handled below rather than by closer, so that it can
+		// stop the job
+		closer.Init(closer.Config{
+			ExitCodeErr:  exitCodeError,
+			ExitSignals:  []os.Signal{syscall.SIGHUP, syscall.SIGTERM},
+			AbortSignals: []os.Signal{syscall.SIGABRT},
+		})
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		go func() {
+			for range interrupts {
+				if !handleInterrupt() {
+					closer.Exit(exitCodeCancelled)
+				}
+			}
+		}()
+	})
+}
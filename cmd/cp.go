@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// jobPathPattern matches the <id>:<path> form used to refer to a path
+// within the build directory of a job
+var jobPathPattern = regexp.MustCompile(`^([0-9a-f]+):(.*)$`)
+
+// buildDirectory is where the build directory is located within the job
+const buildDirectory = "/build"
+
+// parseJobPath splits the argument into the job id and the path relative
+// to the build directory. It returns false if the argument is a local path.
+func parseJobPath(arg string) (string, string, bool) {
+	match := jobPathPattern.FindStringSubmatch(arg)
+	if match == nil {
+		return "", "", false
+	}
+	p := path.Clean("/" + match[2])
+	if p == buildDirectory {
+		p = "/"
+	} else if strings.HasPrefix(p, buildDirectory+"/") {
+		p = strings.TrimPrefix(p, buildDirectory)
+	}
+	return match[1], p, true
+}
+
+var cpCmd = &cobra.Command{
+	Use:   "cp <id>:<path> <dir>",
+	Short: "Copies files from the build directory of a finished job.",
+	Long: `Copies a file or directory from the build directory of a finished job into a local directory. ` +
+		`The path is relative to the build directory, which may also be written as /build. ` +
+		`For example, rai cp 3f2a:/build/output ./out creates ./out/output.`,
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, _, ok := parseJobPath(args[1]); ok {
+			return errors.New("copying files into a job is not supported. Jobs can not be modified once they are submitted")
+		}
+		id, src, ok := parseJobPath(args[0])
+		if !ok {
+			return errors.Errorf("expecting the source to be of the form <id>:<path>, but got %v", args[0])
+		}
+		job, err := loadJobRecord(id)
+		if err != nil {
+			return err
+		}
+
+		out := args[1]
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return err
+		}
+		body, err := openArtifacts(job)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		count, err := extractTarGzPath(body, out, src)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.Errorf("%v does not exist in the build directory of job %v", path.Join(buildDirectory, src), job.ID)
+		}
+		fmt.Printf("Copied %v to %v\n", path.Join(buildDirectory, src), out)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cpCmd)
+}
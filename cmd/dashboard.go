@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/acarl005/stripansi"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// maxDashboardLines is the number of lines of output the dashboard keeps
+const maxDashboardLines = 2000
+
+const (
+	enterAlternateScreen = "\033[?1049h\033[?25l"
+	leaveAlternateScreen = "\033[?25h\033[?1049l"
+)
+
+// tuiOutput shows the progress of the job in a full-screen dashboard
+var tuiOutput bool
+
+// jobDashboard redraws the phase of the job, the upload, the queue
+// estimate, the resource usage and the latest lines of output on the
+// terminal. Everything printed while it is shown is read from a pipe that
+// replaces stdout and stderr.
+type jobDashboard struct {
+	sync.Mutex
+	job *jobRecord
+	// stdout is the terminal, which the output is written to again once the
+	// dashboard is left
+	stdout   *os.File
+	stderr   *os.File
+	reader   *os.File
+	writer   *os.File
+	state    *terminal.State
+	active   bool
+	start    time.Time
+	lines    []string
+	follow   bool
+	offset   int
+	samples  []statsSample
+	upload   int64
+	uploadAt time.Time
+	uploaded time.Duration
+	queuedAt time.Time
+	estimate string
+	done     chan struct{}
+	stopped  sync.Once
+	// refreshed is done once the dashboard stops redrawing and drained once
+	// all the output was read
+	refreshed sync.WaitGroup
+	drained   chan struct{}
+}
+
+// dashboard is nil unless --tui is set
+var dashboard *jobDashboard
+
+// startDashboard switches the terminal to the dashboard. Keys are read
+// unbuffered, so c cancels the job, f toggles following the output, j and k
+// scroll it and q leaves the dashboard while the job keeps running.
+func startDashboard() (*jobDashboard, error) {
+	if !terminal.IsTerminal(int(os.Stdout.Fd())) || !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, errors.New("--tui needs to be run in a terminal")
+	}
+	state, err := terminal.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read keys from the terminal")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		terminal.Restore(int(os.Stdin.Fd()), state)
+		return nil, err
+	}
+	d := &jobDashboard{
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		reader:  r,
+		writer:  w,
+		state:   state,
+		active:  true,
+		start:   time.Now(),
+		follow:  true,
+		done:    make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+	os.Stdout, os.Stderr = w, w
+	resultOutput = w
+	fmt.Fprint(d.stdout, enterAlternateScreen)
+	d.refreshed.Add(1)
+	go d.readOutput()
+	go d.refresh()
+	go d.readKeys()
+	return d, nil
+}
+
+// attach sets the job that the dashboard shows
+func (d *jobDashboard) attach(job *jobRecord) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.job = job
+}
+
+// uploadStarted notes the start of the upload of size bytes
+func (d *jobDashboard) uploadStarted(size int64) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.upload, d.uploadAt = size, time.Now()
+}
+
+// readOutput collects the lines printed while the dashboard is shown and
+// passes them through to the terminal once it is left
+func (d *jobDashboard) readOutput() {
+	defer close(d.drained)
+	scanner := bufio.NewScanner(d.reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		d.Lock()
+		if !d.active {
+			d.Unlock()
+			fmt.Fprintln(d.stdout, raw)
+			continue
+		}
+		line := stripansi.Strip(raw)
+		// keep what a progress line ends up showing
+		if idx := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); idx >= 0 {
+			line = line[idx+1:]
+		}
+		line = strings.TrimRight(line, "\r")
+		d.lines = append(d.lines, strings.Replace(line, "\t", "    ", -1))
+		if len(d.lines) > maxDashboardLines {
+			d.lines = d.lines[len(d.lines)-maxDashboardLines:]
+		}
+		d.Unlock()
+	}
+}
+
+// dashboardSamples passes the resource usage samples of the job output,
+// which are dropped from what is printed, to the dashboard
+type dashboardSamples struct {
+	d       *jobDashboard
+	pending []byte
+}
+
+func (s *dashboardSamples) Write(p []byte) (int, error) {
+	s.pending = append(s.pending, p...)
+	for {
+		idx := bytes.IndexByte(s.pending, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		sample, ok := parseStatsSample(strings.TrimSpace(string(s.pending[:idx])))
+		s.pending = s.pending[idx+1:]
+		if !ok {
+			continue
+		}
+		s.d.Lock()
+		s.d.samples = append(s.d.samples, sample)
+		if len(s.d.samples) > 2 {
+			s.d.samples = s.d.samples[len(s.d.samples)-2:]
+		}
+		s.d.Unlock()
+	}
+}
+
+// withDashboard passes the samples of the output to the dashboard if it is
+// shown
+func withDashboard(w io.Writer) io.Writer {
+	if dashboard == nil {
+		return w
+	}
+	return io.MultiWriter(w, &dashboardSamples{d: dashboard})
+}
+
+// refresh redraws the dashboard every half second
+func (d *jobDashboard) refresh() {
+	defer d.refreshed.Done()
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		d.draw()
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readKeys handles the key bindings until the dashboard is left
+func (d *jobDashboard) readKeys() {
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		d.Lock()
+		active := d.active
+		d.Unlock()
+		if !active {
+			return
+		}
+		switch key := string(buf[:n]); key {
+		case "c", "\x03":
+			d.cancel()
+			return
+		case "q":
+			d.stop()
+			return
+		case "f":
+			d.Lock()
+			d.follow, d.offset = !d.follow, 0
+			d.Unlock()
+		case "k", "\033[A":
+			d.scroll(1)
+		case "j", "\033[B":
+			d.scroll(-1)
+		}
+		d.draw()
+	}
+}
+
+// scroll moves the output up by lines, which stops following it
+func (d *jobDashboard) scroll(lines int) {
+	d.Lock()
+	defer d.Unlock()
+	d.follow = false
+	d.offset += lines
+	if d.offset < 0 {
+		d.offset = 0
+	}
+	if d.offset > len(d.lines) {
+		d.offset = len(d.lines)
+	}
+}
+
+// cancel leaves the dashboard and stops waiting for the job, which is
+// marked as cancelled
+func (d *jobDashboard) cancel() {
+	d.Lock()
+	job := d.job
+	d.Unlock()
+	d.stop()
+	if job == nil {
+		os.Exit(exitCodeCancelled)
+	}
+	job.Error = "cancelled by the user"
+	job.setPhase(jobPhaseCancelled)
+	fmt.Fprintf(d.stdout, "Job %v was cancelled.\n", job.ID)
+	os.Exit(exitCodeCancelled)
+}
+
+// stop restores the terminal and prints the output collected by the
+// dashboard, so that it is not lost with the alternate screen
+func (d *jobDashboard) stop() {
+	if d == nil {
+		return
+	}
+	d.stopped.Do(func() {
+		close(d.done)
+		d.refreshed.Wait()
+		d.Lock()
+		defer d.Unlock()
+		d.active = false
+		fmt.Fprint(d.stdout, leaveAlternateScreen)
+		terminal.Restore(int(os.Stdin.Fd()), d.state)
+		for _, line := range d.lines {
+			fmt.Fprintln(d.stdout, line)
+		}
+		os.Stdout, os.Stderr = d.stdout, d.stderr
+	})
+}
+
+// close stops the dashboard and waits for the remaining output
+func (d *jobDashboard) close() {
+	if d == nil {
+		return
+	}
+	d.stop()
+	d.writer.Close()
+	<-d.drained
+	if resultOutput == d.writer {
+		resultOutput = d.stdout
+	}
+}
+
+// draw renders the dashboard to fit the terminal
+func (d *jobDashboard) draw() {
+	width, height, err := terminal.GetSize(int(d.stdout.Fd()))
+	if err != nil || width < 20 || height < 10 {
+		width, height = 80, 24
+	}
+	d.Lock()
+	defer d.Unlock()
+	if !d.active {
+		return
+	}
+	header := []string{}
+	phase, id := "starting", ""
+	if d.job != nil {
+		phase, id = string(d.job.Phase), d.job.ID
+	}
+	header = append(header, fmt.Sprintf("rai job %v  %v  %v", id, phase, time.Since(d.start).Round(time.Second)))
+
+	switch {
+	case d.uploadAt.IsZero():
+		header = append(header, "Upload     waiting")
+	case phase == string(jobPhaseUploading):
+		header = append(header, fmt.Sprintf("Upload     %v (%v)", humanize.Bytes(uint64(d.upload)), time.Since(d.uploadAt).Round(time.Second)))
+	default:
+		if d.uploaded == 0 {
+			d.uploaded = time.Since(d.uploadAt)
+		}
+		header = append(header, fmt.Sprintf("Upload     %v in %v", humanize.Bytes(uint64(d.upload)), d.uploaded.Round(100*time.Millisecond)))
+	}
+
+	if phase == string(jobPhaseQueued) && d.queuedAt.IsZero() {
+		d.queuedAt = time.Now()
+		if jobs, err := listJobRecords(); err == nil {
+			queue := d.job.Queue
+			if queue == "" {
+				queue = defaultQueueName()
+			}
+			d.estimate = computeQueueStats(queue, jobs).estimate()
+		}
+	}
+	switch {
+	case d.queuedAt.IsZero():
+		header = append(header, "Queue      -")
+	case d.estimate != "":
+		header = append(header, "Queue      "+d.estimate)
+	default:
+		header = append(header, fmt.Sprintf("Queue      waited %v", time.Since(d.queuedAt).Round(time.Second)))
+	}
+
+	if len(d.samples) == 0 {
+		header = append(header, "Resources  not sampled, submit with --stats-interval to sample them")
+	} else {
+		last := d.samples[len(d.samples)-1]
+		cpu := 0.0
+		if len(d.samples) == 2 {
+			if elapsed := last.Time.Sub(d.samples[0].Time); elapsed > 0 {
+				cpu = 100 * float64(last.CPUTime-d.samples[0].CPUTime) / float64(elapsed)
+			}
+		}
+		usage := fmt.Sprintf("Resources  CPU %.0f%%  Memory %v", cpu, humanize.IBytes(last.Memory))
+		if last.GPUUtilization >= 0 {
+			usage += fmt.Sprintf("  GPU %d%%  GPU Memory %v", last.GPUUtilization, humanize.IBytes(last.GPUMemory))
+		}
+		header = append(header, usage)
+	}
+
+	mode := "following"
+	if !d.follow {
+		mode = fmt.Sprintf("paused, %v lines up", d.offset)
+	}
+	header = append(header, "", fmt.Sprintf("── Output (%v) %v", mode, strings.Repeat("─", width)))
+	footer := "c cancel  f follow  j/k scroll  q leave the dashboard"
+
+	rows := height - len(header) - 1
+	end := len(d.lines)
+	if !d.follow {
+		end -= d.offset
+	}
+	begin := end - rows
+	if begin < 0 {
+		begin = 0
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(clearScreen)
+	for _, line := range header {
+		buf.WriteString(truncateLine(line, width) + "\r\n")
+	}
+	for _, line := range d.lines[begin:end] {
+		buf.WriteString(truncateLine(line, width) + "\r\n")
+	}
+	for ii := end - begin; ii < rows; ii++ {
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString(truncateLine(footer, width))
+	d.stdout.Write(buf.Bytes())
+}
+
+// truncateLine cuts the line to the width of the terminal
+func truncateLine(line string, width int) string {
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	return string([]rune(line)[:width])
+}
+
+// runWithDashboard shows the dashboard while the job is submitted
+func runWithDashboard(submit func() error) error {
+	if events != nil || quietOutput {
+		return errors.New("--tui can not be used with --output-format or --quiet")
+	}
+	d, err := startDashboard()
+	if err != nil {
+		return err
+	}
+	dashboard = d
+	defer func() {
+		d.close()
+		dashboard = nil
+	}()
+	return submit()
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/acarl005/stripansi"
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffMatch   string
+	diffContext int
+)
+
+// readJobOutput returns the lines of the captured output of the job, without
+// color codes and resource usage samples. Only the lines matching the
+// pattern are returned when it is not nil.
+func readJobOutput(job *jobRecord, pattern *regexp.Regexp) ([]string, error) {
+	path, err := job.logPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no output was captured for job %v", job.ID)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := stripansi.Strip(scanner.Text())
+		if _, ok := parseStatsSample(line); ok {
+			continue
+		}
+		if pattern != nil && !pattern.MatchString(line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// diffOp is a line of a diff: ' ' for a common line, '-' for a line that
+// was removed and '+' for a line that was added
+type diffOp struct {
+	Kind byte
+	Line string
+}
+
+// diffLines computes the line diff from a to b using the longest common
+// subsequence of the lines
+func diffLines(a, b []string) []diffOp {
+	// the common prefix and suffix are kept out of the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[ii][jj] is the length of the common subsequence of ma[ii:] and mb[jj:]
+	lcs := make([][]int32, len(ma)+1)
+	for ii := range lcs {
+		lcs[ii] = make([]int32, len(mb)+1)
+	}
+	for ii := len(ma) - 1; ii >= 0; ii-- {
+		for jj := len(mb) - 1; jj >= 0; jj-- {
+			if ma[ii] == mb[jj] {
+				lcs[ii][jj] = lcs[ii+1][jj+1] + 1
+			} else if lcs[ii+1][jj] >= lcs[ii][jj+1] {
+				lcs[ii][jj] = lcs[ii+1][jj]
+			} else {
+				lcs[ii][jj] = lcs[ii][jj+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ii, jj := 0, 0
+	for ii < len(ma) || jj < len(mb) {
+		switch {
+		case ii < len(ma) && jj < len(mb) && ma[ii] == mb[jj]:
+			ops = append(ops, diffOp{' ', ma[ii]})
+			ii++
+			jj++
+		case jj == len(mb) || (ii < len(ma) && lcs[ii+1][jj] >= lcs[ii][jj+1]):
+			ops = append(ops, diffOp{'-', ma[ii]})
+			ii++
+		default:
+			ops = append(ops, diffOp{'+', mb[jj]})
+			jj++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// writeUnifiedDiff writes the diff in the unified format with the given
+// number of context lines. It returns false if there are no differences.
+func writeUnifiedDiff(w io.Writer, nameA, nameB string, ops []diffOp, context int) bool {
+	changed := false
+	for _, op := range ops {
+		if op.Kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	fmt.Fprintf(w, "--- %v\n+++ %v\n", nameA, nameB)
+	removed := color.New(color.FgRed)
+	added := color.New(color.FgGreen)
+
+	// lineA and lineB are the line numbers of ops[start] in each output
+	lineA, lineB := 1, 1
+	for start := 0; start < len(ops); {
+		// find the next change
+		next := start
+		for next < len(ops) && ops[next].Kind == ' ' {
+			next++
+		}
+		if next == len(ops) {
+			break
+		}
+		// the hunk extends until more than twice the context lines are
+		// unchanged
+		from := next - context
+		if from < start {
+			from = start
+		}
+		to := next
+		for to < len(ops) {
+			if ops[to].Kind != ' ' {
+				to++
+				continue
+			}
+			run := to
+			for run < len(ops) && ops[run].Kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-to > 2*context {
+				to += context
+				if to > run {
+					to = run
+				}
+				break
+			}
+			to = run
+		}
+
+		// advance the line numbers to the start of the hunk
+		for _, op := range ops[start:from] {
+			lineA, lineB = advanceDiffLines(op, lineA, lineB)
+		}
+		countA, countB := 0, 0
+		for _, op := range ops[from:to] {
+			if op.Kind != '+' {
+				countA++
+			}
+			if op.Kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, op := range ops[from:to] {
+			switch op.Kind {
+			case '-':
+				removed.Fprintf(w, "-%v\n", op.Line)
+			case '+':
+				added.Fprintf(w, "+%v\n", op.Line)
+			default:
+				fmt.Fprintf(w, " %v\n", op.Line)
+			}
+			lineA, lineB = advanceDiffLines(op, lineA, lineB)
+		}
+		start = to
+	}
+	return true
+}
+
+func advanceDiffLines(op diffOp, lineA, lineB int) (int, int) {
+	if op.Kind != '+' {
+		lineA++
+	}
+	if op.Kind != '-' {
+		lineB++
+	}
+	return lineA, lineB
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <id> <id>",
+	Short: "Compares the output of two jobs.",
+	Long: `Prints a unified diff between the output captured for two jobs. With --match only the lines ` +
+		`matching the regular expression are compared, for example --match 'Op Time' to compare ` +
+		`timing lines.`,
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var pattern *regexp.Regexp
+		if diffMatch != "" {
+			p, err := regexp.Compile(diffMatch)
+			if err != nil {
+				return errors.Wrapf(err, "invalid --match pattern %v", diffMatch)
+			}
+			pattern = p
+		}
+
+		var outputs [2][]string
+		var names [2]string
+		for ii, id := range args {
+			job, err := loadJobRecord(id)
+			if err != nil {
+				return err
+			}
+			if outputs[ii], err = readJobOutput(job, pattern); err != nil {
+				return err
+			}
+			names[ii] = "job " + job.ID
+		}
+
+		if !writeUnifiedDiff(os.Stdout, names[0], names[1], diffLines(outputs[0], outputs[1]), diffContext) {
+			fmt.Println("The outputs of the jobs are identical.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffMatch, "match", "", "Only compare the lines matching the regular expression.")
+	diffCmd.Flags().IntVarP(&diffContext, "unified", "U", 3, "Number of context lines.")
+	RootCmd.AddCommand(diffCmd)
+}
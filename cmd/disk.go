@@ -0,0 +1,15 @@
+// +build !windows
+
+package cmd
+
+import "syscall"
+
+// availableDiskSpace returns the number of bytes available to the user on
+// the file system containing the directory
+func availableDiskSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
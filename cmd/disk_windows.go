@@ -0,0 +1,19 @@
+// +build windows
+
+package cmd
+
+import "golang.org/x/sys/windows"
+
+// availableDiskSpace returns the number of bytes available to the user on
+// the volume containing the directory
+func availableDiskSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dockerfileInstruction is an instruction of a Dockerfile along with the
+// line it starts at
+type dockerfileInstruction struct {
+	Line    int
+	Command string
+	Args    string
+}
+
+// readDockerfile splits the Dockerfile into instructions, joining the lines
+// that are continued using a trailing backslash
+func readDockerfile(r io.Reader) ([]dockerfileInstruction, error) {
+	instructions := []dockerfileInstruction{}
+	scanner := bufio.NewScanner(r)
+	current, start, lineNumber := "", 0, 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") || (line == "" && current == "") {
+			continue
+		}
+		if current == "" {
+			start = lineNumber
+		}
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		current += line
+		fields := strings.SplitN(current, " ", 2)
+		instruction := dockerfileInstruction{Line: start, Command: strings.ToUpper(fields[0])}
+		if len(fields) == 2 {
+			instruction.Args = strings.TrimSpace(fields[1])
+		}
+		instructions = append(instructions, instruction)
+		current = ""
+	}
+	if current != "" {
+		return nil, errors.Errorf("line %v: the instruction is continued past the end of the file", start)
+	}
+	return instructions, scanner.Err()
+}
+
+// dockerfileCommand turns the arguments of RUN or CMD, in either the shell
+// or the exec form, into a shell command
+func dockerfileCommand(args string) string {
+	var exec []string
+	if strings.HasPrefix(args, "[") && json.Unmarshal([]byte(args), &exec) == nil {
+		quoted := make([]string, len(exec))
+		for ii, arg := range exec {
+			quoted[ii] = shellQuote(arg)
+		}
+		return strings.Join(quoted, " ")
+	}
+	return args
+}
+
+// dockerfileArgs splits the arguments of COPY or ADD, in either form, into
+// the options, the sources and the destination
+func dockerfileArgs(args string) ([]string, []string, string) {
+	var exec []string
+	if strings.HasPrefix(args, "[") && json.Unmarshal([]byte(args), &exec) == nil && len(exec) >= 2 {
+		return nil, exec[:len(exec)-1], exec[len(exec)-1]
+	}
+	options, fields := []string{}, []string{}
+	for _, field := range strings.Fields(args) {
+		if strings.HasPrefix(field, "--") && len(fields) == 0 {
+			options = append(options, field)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) < 2 {
+		return options, nil, ""
+	}
+	return options, fields[:len(fields)-1], fields[len(fields)-1]
+}
+
+// dockerfileEnv parses the KEY=value pairs, or the legacy KEY value form,
+// of ENV and ARG
+func dockerfileEnv(args string) [][2]string {
+	if !strings.Contains(strings.SplitN(args, " ", 2)[0], "=") {
+		fields := strings.SplitN(args, " ", 2)
+		if len(fields) == 1 {
+			return [][2]string{{fields[0], ""}}
+		}
+		return [][2]string{{fields[0], strings.TrimSpace(fields[1])}}
+	}
+	pairs := [][2]string{}
+	for _, field := range strings.Fields(args) {
+		idx := strings.Index(field, "=")
+		if idx <= 0 {
+			continue
+		}
+		pairs = append(pairs, [2]string{field[:idx], strings.Trim(field[idx+1:], `"'`)})
+	}
+	return pairs
+}
+
+// templateFromDockerfile translates the Dockerfile into a build file
+// template. The image is taken from FROM, RUN and COPY become build
+// commands that run in the order of the Dockerfile, and ENV and WORKDIR
+// are applied to the commands that follow them. It returns a warning for
+// every instruction that can not be translated.
+func templateFromDockerfile(r io.Reader) (buildTemplate, []string, error) {
+	tmpl := buildTemplate{Name: "dockerfile", Description: "Translated from a Dockerfile"}
+	instructions, err := readDockerfile(r)
+	if err != nil {
+		return tmpl, nil, err
+	}
+	warnings := []string{}
+	warn := func(instruction dockerfileInstruction, format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("line %v: %v ", instruction.Line, instruction.Command)+fmt.Sprintf(format, args...))
+	}
+	env := []string{}
+	workdir := buildDirectory
+	// every build command runs in its own shell, so the environment and the
+	// working directory are set by each of them
+	command := func(cmd string) string {
+		prefix := ""
+		if len(env) != 0 {
+			prefix = strings.Join(env, "; ") + "; "
+		}
+		if workdir != buildDirectory {
+			prefix += "cd " + shellQuote(workdir) + " && "
+		}
+		return prefix + cmd
+	}
+	for _, instruction := range instructions {
+		switch instruction.Command {
+		case "FROM":
+			image := ""
+			for _, field := range strings.Fields(instruction.Args) {
+				if !strings.HasPrefix(field, "--") {
+					image = field
+					break
+				}
+			}
+			if image == "" {
+				return tmpl, warnings, errors.Errorf("line %v: FROM has no image", instruction.Line)
+			}
+			if tmpl.Image != "" {
+				warn(instruction, "starts another build stage, only the last stage is translated")
+				tmpl.Commands, env, workdir = nil, nil, buildDirectory
+			}
+			tmpl.Image = image
+			image = strings.ToLower(image)
+			tmpl.GPU = strings.Contains(image, "cuda") || strings.Contains(image, "nvidia")
+		case "RUN":
+			tmpl.Commands = append(tmpl.Commands, command(dockerfileCommand(instruction.Args)))
+		case "CMD", "ENTRYPOINT":
+			warn(instruction, "is run as the last build command")
+			tmpl.Commands = append(tmpl.Commands, command(dockerfileCommand(instruction.Args)))
+		case "COPY", "ADD":
+			options, sources, dest := dockerfileArgs(instruction.Args)
+			for _, option := range options {
+				if strings.HasPrefix(option, "--from") {
+					warn(instruction, "copies from another build stage, which is not supported")
+					sources = nil
+				} else {
+					warn(instruction, "option %v is ignored", option)
+				}
+			}
+			if dest == "" || len(sources) == 0 {
+				continue
+			}
+			if !path.IsAbs(dest) {
+				dest = path.Join(workdir, dest)
+			}
+			for _, src := range sources {
+				if strings.Contains(src, "://") {
+					warn(instruction, "downloads %v, which is not supported since networking is disabled", src)
+					continue
+				}
+				if instruction.Command == "ADD" && (strings.HasSuffix(src, ".tar") || strings.Contains(src, ".tar.")) {
+					warn(instruction, "extracts %v, which is copied without being extracted", src)
+				}
+				src = path.Join("/src", path.Clean("/"+src))
+				if src == "/src" {
+					src = "/src/."
+				}
+				tmpl.Commands = append(tmpl.Commands, fmt.Sprintf("mkdir -p %v && cp -r %v %v", shellQuote(dest), src, shellQuote(dest)))
+			}
+		case "ENV", "ARG":
+			if instruction.Command == "ARG" {
+				warn(instruction, "is translated to an environment variable set to its default value")
+			}
+			for _, pair := range dockerfileEnv(instruction.Args) {
+				if pair[1] == "" && instruction.Command == "ARG" {
+					continue
+				}
+				// double quotes let the value refer to other variables, as in
+				// ENV PATH=/opt/bin:$PATH
+				value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(pair[1])
+				env = append(env, fmt.Sprintf(`export %v="%v"`, pair[0], value))
+			}
+		case "WORKDIR":
+			dir := instruction.Args
+			if !path.IsAbs(dir) {
+				dir = path.Join(workdir, dir)
+			}
+			workdir = dir
+			tmpl.Commands = append(tmpl.Commands, "mkdir -p "+shellQuote(workdir))
+		case "LABEL", "MAINTAINER", "EXPOSE":
+			// these do not affect the build
+		default:
+			warn(instruction, "is not supported and is ignored")
+		}
+	}
+	if tmpl.Image == "" {
+		return tmpl, warnings, errors.New("the Dockerfile has no FROM instruction")
+	}
+	if len(tmpl.Commands) == 0 {
+		warnings = append(warnings, "the Dockerfile has no RUN instruction, a placeholder command is used")
+		tmpl.Commands = []string{`echo "Building project"`}
+	}
+	return tmpl, warnings, nil
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/buildfile"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	doctorTimeout      = 10 * time.Second
+	maxClockSkew       = 2 * time.Minute
+	minTempDiskSpace   = 1 << 30
+	doctorCheckPadding = 24
+)
+
+// doctorCheck is a single diagnostic along with the fix that is suggested
+// when it fails
+type doctorCheck struct {
+	name string
+	fix  string
+	run  func() (string, error)
+}
+
+var doctorChecks = []doctorCheck{
+	{
+		name: "Profile",
+		fix:  "Run `rai login` or check the credentials in ~/.rai_profile with the course staff.",
+		run: func() (string, error) {
+			prof, err := loadProfile()
+			if err != nil {
+				return "", err
+			}
+			return "authenticated as " + prof.Info().Username, nil
+		},
+	},
+	{
+		name: "Broker",
+		fix:  "Check your network connection. Campus and corporate firewalls may block the broker port.",
+		run: func() (string, error) {
+			endpoints := viper.GetStringSlice("pubsub.endpoints")
+			if len(endpoints) == 0 {
+				return "", errors.New("no broker endpoint is configured")
+			}
+			conn, err := net.DialTimeout("tcp", endpoints[0], doctorTimeout)
+			if err != nil {
+				return "", err
+			}
+			conn.Close()
+			return "reachable at " + endpoints[0], nil
+		},
+	},
+	{
+		name: "Upload endpoint",
+		fix:  "Check your network connection and any proxy settings (HTTP_PROXY, HTTPS_PROXY).",
+		run: func() (string, error) {
+			url := viper.GetString("store.base_url")
+			if url == "" {
+				return "", errors.New("no upload endpoint is configured")
+			}
+			client := http.Client{Timeout: doctorTimeout}
+			resp, err := client.Head(url)
+			if err != nil {
+				return "", err
+			}
+			resp.Body.Close()
+			return "reachable at " + url, nil
+		},
+	},
+	{
+		name: "Clock",
+		fix:  "Synchronize your system clock. Uploads are rejected when the clock is off by more than a few minutes.",
+		run: func() (string, error) {
+			client := http.Client{Timeout: doctorTimeout}
+			resp, err := client.Head(viper.GetString("store.base_url"))
+			if err != nil {
+				return "", err
+			}
+			resp.Body.Close()
+			serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+			if err != nil {
+				return "", errors.New("the server did not report its time")
+			}
+			skew := time.Since(serverTime)
+			if skew < 0 {
+				skew = -skew
+			}
+			if skew > maxClockSkew {
+				return "", errors.Errorf("the clock is off by %v", skew.Round(time.Second))
+			}
+			return fmt.Sprintf("off by %v", skew.Round(time.Second)), nil
+		},
+	},
+	{
+		name: "Build file",
+		fix:  "Fix the syntax of the build file. See the Project Build Specification section of the user guide.",
+		run: func() (string, error) {
+			path := buildFileLocation()
+			buf, err := readBuildFile(path)
+			if err != nil {
+				return "", err
+			}
+			if _, err := buildfile.Decode(path, buf); err != nil {
+				return "", err
+			}
+			return path + " parses", nil
+		},
+	},
+	{
+		name: "Temporary disk space",
+		fix:  "Free up disk space or point TMPDIR to a directory with more space. The project archive is staged there before it is uploaded.",
+		run: func() (string, error) {
+			dir := os.TempDir()
+			available, err := availableDiskSpace(dir)
+			if err != nil {
+				return "", err
+			}
+			if available < minTempDiskSpace {
+				return "", errors.Errorf("only %v MB available in %v", available>>20, dir)
+			}
+			return fmt.Sprintf("%v MB available in %v", available>>20, dir), nil
+		},
+	},
+}
+
+var doctorCmd = &cobra.Command{
+	Use:          "doctor",
+	Short:        "Checks that the client is able to submit jobs.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failed := 0
+		for _, check := range doctorChecks {
+			status, err := check.run()
+			if err != nil {
+				failed++
+				themeColor(themeError).Printf("✘ %-*s", doctorCheckPadding, check.name)
+				fmt.Println(err)
+				fmt.Printf("  %-*s %v\n", doctorCheckPadding, "", check.fix)
+				continue
+			}
+			themeColor(themeSuccess).Printf("✔ %-*s", doctorCheckPadding, check.name)
+			fmt.Println(status)
+		}
+		if failed != 0 {
+			return errors.Errorf("%v of %v checks failed", failed, len(doctorChecks))
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var dryRunCmd = &cobra.Command{
+	Use:   "dry-run",
+	Short: "Shows what would be submitted without contacting the server.",
+	Long: `Shows the queue, the submission tag, the files that would be uploaded and the build file ` +
+		`that would be submitted. The server is not contacted.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, exclusions, err := collectUploadFiles(workingDir)
+		if err != nil {
+			return err
+		}
+		buildFile, err := redactedBuildFile()
+		if err != nil {
+			return err
+		}
+
+		queue := jobQueueName
+		if queue == "" {
+			queue = defaultQueueName() + " (inferred from the build file when possible)"
+		}
+		fmt.Printf("%-12s %v\n", "Directory:", workingDir)
+		fmt.Printf("%-12s %v\n", "Queue:", queue)
+		if submitionName != "" {
+			fmt.Printf("%-12s %v\n", "Submission:", submitionName)
+		}
+		fmt.Printf("%-12s %v\n", "Build file:", buildFileLocation())
+		fmt.Println()
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"File", "Size"})
+		for _, file := range files {
+			table.Append([]string{file.Path, humanize.Bytes(uint64(file.Size))})
+		}
+		table.SetFooter([]string{fmt.Sprintf("%v files", len(files)), humanize.Bytes(uint64(totalUploadSize(files)))})
+		table.Render()
+		if exclusions.Files > 0 {
+			fmt.Println(exclusions)
+		}
+
+		fmt.Println()
+		fmt.Println(string(buildFile))
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dryRunCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// similarJobs returns the jobs that ran the build commands with the digest
+func similarJobs(jobs []*jobRecord, digest string) []*jobRecord {
+	var similar []*jobRecord
+	for _, job := range jobs {
+		if digest != "" && job.CommandsDigest == digest {
+			similar = append(similar, job)
+		}
+	}
+	return similar
+}
+
+var estimateCmd = &cobra.Command{
+	Use:   "estimate",
+	Short: "Estimates how long the job would take on each queue.",
+	Long: `Estimates the wait and run time of the job on each queue. The run time is the average of ` +
+		`previous jobs with the same build commands, or of all jobs on the queue when the commands ` +
+		`have not been run before. Only jobs submitted from this machine are considered.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobs, err := listJobRecords()
+		if err != nil {
+			return err
+		}
+		similar := similarJobs(jobs, buildCommandsDigest())
+
+		names := []string{}
+		if jobQueueName != "" {
+			names = append(names, jobQueueName)
+		} else {
+			queues, err := configuredQueues()
+			if err != nil {
+				return err
+			}
+			for _, queue := range queues {
+				names = append(names, queue.Name)
+			}
+			if len(names) == 0 {
+				names = append(names, defaultQueueName())
+			}
+		}
+
+		round := func(d time.Duration) string {
+			if d == 0 {
+				return "-"
+			}
+			return d.Round(time.Second).String()
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Queue", "Similar Jobs", "Wait", "Run Time", "Total"})
+		for _, name := range names {
+			stats := computeQueueStats(name, jobs)
+			runTime := stats.AverageDuration
+			similarStats := computeQueueStats(name, similar)
+			if similarStats.AverageDuration != 0 {
+				runTime = similarStats.AverageDuration
+			}
+			wait := stats.AverageWait + time.Duration(stats.Active)*stats.AverageDuration
+			total := time.Duration(0)
+			if runTime != 0 {
+				total = wait + runTime
+			}
+			table.Append([]string{
+				name,
+				fmt.Sprint(similarStats.Jobs),
+				round(wait),
+				round(runTime),
+				round(total),
+			})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(estimateCmd)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acarl005/stripansi"
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/rai"
+)
+
+// the formats that the progress of a job can be reported in
+const (
+	outputFormatText   = "text"
+	outputFormatJSON   = "json"
+	outputFormatNDJSON = "ndjson"
+)
+
+// stepStartPrefix starts the line that the server prints before running a
+// build command
+const stepStartPrefix = "✱ Running "
+
+// outputFormat selects how the progress of the job is reported. The json
+// and ndjson formats write events to stdout while the text that is
+// normally shown is written to stderr.
+var outputFormat = outputFormatText
+
+// jobEvent is a step in the lifecycle of a job
+type jobEvent struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"`
+	JobID string    `json:"job_id,omitempty"`
+	// Stream is stdout or stderr for log lines
+	Stream string `json:"stream,omitempty"`
+	Line   string `json:"line,omitempty"`
+	// Step is the command of a step-started event
+	Step  string `json:"step,omitempty"`
+	Error string `json:"error,omitempty"`
+	// ErrorDetails gives the code, the hint and the exit code of the error
+	ErrorDetails *rai.Error             `json:"error_details,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+}
+
+// eventWriter reports the events of the job in the output format. Events
+// are written as they happen in the ndjson format and as one document once
+// the job finishes in the json format.
+type eventWriter struct {
+	sync.Mutex
+	w      io.Writer
+	events []jobEvent
+}
+
+// events is nil unless a structured output format is selected
+var events *eventWriter
+
+// setOutputFormat checks the format and, for the structured formats,
+// keeps stdout for the events while the text output goes to stderr
+func setOutputFormat(format string) error {
+	switch format {
+	case outputFormatText:
+		return nil
+	case outputFormatJSON, outputFormatNDJSON:
+	default:
+		return errors.Errorf("unknown output format %v, expecting text, json or ndjson", format)
+	}
+	events = &eventWriter{w: os.Stdout}
+	os.Stdout = os.Stderr
+	return nil
+}
+
+// emit reports the event, filling in its time
+func (e *eventWriter) emit(event jobEvent) {
+	if e == nil {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	event.Time = time.Now()
+	if outputFormat == outputFormatJSON {
+		e.events = append(e.events, event)
+		return
+	}
+	buf, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	e.w.Write(append(buf, '\n'))
+}
+
+// finish reports the end of the job and, in the json format, writes the
+// events along with the outcome of the job
+func (e *eventWriter) finish(job *jobRecord, jobErr error) {
+	if e == nil {
+		return
+	}
+	event := jobEvent{Type: "finished", JobID: job.ID, Data: map[string]interface{}{"phase": job.Phase}}
+	if jobErr != nil {
+		event.Error = jobErr.Error()
+		event.ErrorDetails = errorDetails(jobErr)
+	}
+	if job.BuildURL != "" {
+		event.Data["build_url"] = job.BuildURL
+	}
+	e.emit(event)
+	if outputFormat != outputFormatJSON {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	buf, err := json.MarshalIndent(map[string]interface{}{
+		"job_id":        job.ID,
+		"phase":         job.Phase,
+		"error":         event.Error,
+		"error_details": event.ErrorDetails,
+		"events":        e.events,
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+	e.w.Write(append(buf, '\n'))
+}
+
+// eventLines reports every line written to it as a log-line event, and the
+// lines that start a build command as step-started events
+type eventLines struct {
+	sync.Mutex
+	job     *jobRecord
+	stream  string
+	pending []byte
+}
+
+func (l *eventLines) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	l.pending = append(l.pending, p...)
+	for {
+		idx := bytes.IndexByte(l.pending, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimRight(stripansi.Strip(string(l.pending[:idx])), "\r")
+		l.pending = l.pending[idx+1:]
+		if strings.HasPrefix(line, stepStartPrefix) {
+			events.emit(jobEvent{Type: "step-started", JobID: l.job.ID, Step: strings.TrimPrefix(line, stepStartPrefix)})
+		}
+		events.emit(jobEvent{Type: "log-line", JobID: l.job.ID, Stream: l.stream, Line: line})
+	}
+}
+
+// withEvents adds the reporting of log lines to the output of the stream
+func withEvents(w io.Writer, job *jobRecord, stream string) io.Writer {
+	if events == nil {
+		return w
+	}
+	return io.MultiWriter(w, &eventLines{job: job, stream: stream})
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var execReplay bool
+
+var execCmd = &cobra.Command{
+	Use:   "exec <id> -- <command>",
+	Short: "Runs a command against the project and image of a previous job.",
+	Long: `Submits a fresh job using the directory, build file and queue of a previous job, ` +
+		`with the build commands replaced by the command. The command does not run in the container ` +
+		`of the previous job, which is gone once the job ends: the directory is uploaded again and the ` +
+		`job counts against the rate limit like any other. With --replay the command runs after the build ` +
+		`commands of the job. A queue can list disable_exec in the client configuration to turn this off; ` +
+		`it is a convenience, not a policy, since the configuration can be changed by the user.`,
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+		restoreJobSettings(job)
+		queue, err := findQueue(defaultQueueName())
+		if err != nil {
+			return err
+		}
+		if queue != nil && queue.DisableExec {
+			return errors.Errorf("running commands is not permitted on the %v queue", queue.Name)
+		}
+
+		command := strings.Join(args[1:], " ")
+		overrideBuildCommands = func(build []string) []string {
+			if execReplay {
+				return append(build, command)
+			}
+			return []string{command}
+		}
+
+		fmt.Printf("Running `%v` against job %v\n", command, job.ID)
+
+		return submitJob()
+	},
+}
+
+func init() {
+	execCmd.Flags().BoolVar(&execReplay, "replay", false, "Run the build commands of the job before the command.")
+	RootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/acarl005/stripansi"
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/rai"
+	"gopkg.in/yaml.v2"
+)
+
+// the sources that the exit code of rai can be taken from
+const (
+	exitCodeFromRemote = "remote"
+	exitCodeFromClient = "client"
+)
+
+// the exit codes of the failures of the client. Timeouts and cancellations
+// follow timeout(1) and the shells. The failures of the lifecycle carry
+// their own exit code, see rai.Error.
+const (
+	exitCodeError     = 1
+	exitCodeTimeout   = 124
+	exitCodeCancelled = 130
+)
+
+// exitCodeFrom selects whether rai exits with the exit status of the build
+// command that failed on the worker or only reports failures of the client
+var exitCodeFrom = exitCodeFromRemote
+
+// exitStatusMarker prefixes the line that reports the exit status of the
+// build command that failed. It is unique to the submission so that the
+// output of the build commands can not be mistaken for it.
+var exitStatusMarker = "RAI_EXIT_STATUS_" + newRandomID()
+
+// exitError is an error along with the exit code of rai
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// withExitCode sets the exit code of rai for the error, unless it already
+// has one
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*exitError); ok {
+		return err
+	}
+	return &exitError{code: code, err: err}
+}
+
+// withFailure marks the error as the failure of the lifecycle, unless it
+// already has an exit code, such as when the job was cancelled
+func withFailure(failure *rai.Error, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*exitError); ok || rai.AsError(err) != nil {
+		return err
+	}
+	return failure.Wrap(err)
+}
+
+// ExitCode returns the code that rai exits with for the error returned by
+// Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	for cause := err; cause != nil; {
+		if e, ok := cause.(*exitError); ok {
+			return e.code
+		}
+		if e, ok := cause.(interface {
+			RaiError() *rai.Error
+		}); ok {
+			return e.RaiError().ExitCode
+		}
+		next, ok := cause.(interface {
+			Cause() error
+		})
+		if !ok {
+			break
+		}
+		cause = next.Cause()
+	}
+	return exitCodeError
+}
+
+// errorDetails describes the error of the job in the JSON outputs: the
+// failures of the lifecycle with their code and hint, and otherwise a code
+// that follows the exit code
+func errorDetails(err error) *rai.Error {
+	if err == nil {
+		return nil
+	}
+	if e := rai.AsError(err); e != nil {
+		details := *e
+		details.Message = err.Error()
+		return &details
+	}
+	code := "build_failed"
+	switch ExitCode(err) {
+	case exitCodeError:
+		code = "client_error"
+	case exitCodeTimeout:
+		code = "timeout"
+	case exitCodeCancelled:
+		code = "cancelled"
+	}
+	return &rai.Error{Code: code, Message: err.Error(), ExitCode: ExitCode(err)}
+}
+
+// checkExitCodeFrom checks the value of --exit-code-from
+func checkExitCodeFrom() error {
+	if exitCodeFrom != exitCodeFromRemote && exitCodeFrom != exitCodeFromClient {
+		return errors.Errorf("unknown --exit-code-from %v, expecting remote or client", exitCodeFrom)
+	}
+	return nil
+}
+
+// withExitStatus reports the exit status of the command if it fails,
+// preserving it
+func withExitStatus(command string) string {
+	return command + "\n" +
+		"RAI_STATUS=$?\n" +
+		"if [ $RAI_STATUS -ne 0 ]; then echo \"" + exitStatusMarker + " $RAI_STATUS\"; fi\n" +
+		"(exit $RAI_STATUS)"
+}
+
+// exitStatusRecorder notes the exit status of the build command that
+// failed from the lines of the job output, and drops these lines from the
+// output written to w. Partial lines are held while they may still be
+// such a line.
+type exitStatusRecorder struct {
+	sync.Mutex
+	w       io.Writer
+	status  int
+	pending []byte
+}
+
+// parseExitStatus returns the exit status reported by the line
+func parseExitStatus(line []byte) (int, bool) {
+	fields := strings.Fields(stripansi.Strip(string(line)))
+	if len(fields) != 2 || fields[0] != exitStatusMarker {
+		return 0, false
+	}
+	status, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, false
+	}
+	return status, true
+}
+
+func (r *exitStatusRecorder) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.pending = append(r.pending, p...)
+	for {
+		idx := bytes.IndexByte(r.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		line := r.pending[:idx+1]
+		if status, ok := parseExitStatus(line); ok {
+			// only failures are reported
+			if status != 0 {
+				r.status = status
+			}
+		} else if _, err := r.w.Write(line); err != nil {
+			return 0, err
+		}
+		r.pending = r.pending[idx+1:]
+	}
+	if len(r.pending) > 0 && !bytes.HasPrefix([]byte(exitStatusMarker), r.pending) && !bytes.HasPrefix(r.pending, []byte(exitStatusMarker)) {
+		if _, err := r.w.Write(r.pending); err != nil {
+			return 0, err
+		}
+		r.pending = r.pending[:0]
+	}
+	return len(p), nil
+}
+
+// Flush writes the partial line that is held
+func (r *exitStatusRecorder) Flush() {
+	r.Lock()
+	defer r.Unlock()
+	if len(r.pending) > 0 {
+		r.w.Write(r.pending)
+		r.pending = nil
+	}
+}
+
+// lastStatus returns the exit status of the build command that failed, or
+// 0 if none did
+func (r *exitStatusRecorder) lastStatus() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.status
+}
+
+// remoteError returns the error of the job if a build command failed and
+// the exit code is taken from it
+func (r *exitStatusRecorder) remoteError() error {
+	if r == nil || exitCodeFrom != exitCodeFromRemote {
+		return nil
+	}
+	status := r.lastStatus()
+	if status == 0 {
+		return nil
+	}
+	return withExitCode(status, fmt.Errorf("the build command exited with status %v", status))
+}
+
+func init() {
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		build := buildCommands(doc)
+		// the marker changes with every submission, so it is left out of
+		// the build file that is shown, kept or digested
+		if exitCodeFrom != exitCodeFromRemote || resolvingForRecord || len(build) == 0 {
+			return doc, false, nil
+		}
+		for ii, command := range build {
+			build[ii] = withExitStatus(command)
+		}
+		return setBuildCommands(doc, build), true, nil
+	})
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var exportNoArtifacts bool
+
+// The names of the files within a job bundle
+const (
+	bundleMetadata  = "job.yml"
+	bundleBuildFile = "rai_build.yml"
+	bundleLog       = "output.log"
+	bundleCast      = "output.cast"
+	bundleArtifacts = "build.tar.gz"
+	bundleManifest  = "manifest.json"
+)
+
+// bundleFile is a file within a job bundle
+type bundleFile struct {
+	name string
+	buf  []byte
+}
+
+func (f bundleFile) write(tw *tar.Writer) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    f.name,
+		Mode:    0644,
+		Size:    int64(len(f.buf)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(f.buf)
+	return err
+}
+
+// readJobStoreFile reads a file that belongs to the job from the job store.
+// It returns nil if the file does not exist.
+func readJobStoreFile(path func() (string, error)) ([]byte, error) {
+	p, err := path()
+	if err != nil {
+		return nil, err
+	}
+	if !com.IsFile(p) {
+		return nil, nil
+	}
+	return ioutil.ReadFile(p)
+}
+
+// exportJob writes the job bundle as a gzip compressed tar archive
+func exportJob(job *jobRecord, out string) error {
+	metadata, err := yaml.Marshal(job)
+	if err != nil {
+		return err
+	}
+	files := []bundleFile{{bundleMetadata, metadata}}
+	add := func(name string, path func() (string, error)) error {
+		buf, err := readJobStoreFile(path)
+		if err != nil {
+			return err
+		}
+		if buf != nil {
+			files = append(files, bundleFile{name, buf})
+		}
+		return nil
+	}
+	if err := add(bundleBuildFile, job.submittedBuildFilePath); err != nil {
+		return err
+	}
+	if err := add(bundleManifest, job.manifestPath); err != nil {
+		return err
+	}
+	if err := add(bundleLog, job.logPath); err != nil {
+		return err
+	}
+	if err := add(bundleCast, job.castPath); err != nil {
+		return err
+	}
+	if !exportNoArtifacts {
+		if body, err := openArtifacts(job); err != nil {
+			fmt.Printf("The build directory is not included: %v\n", err)
+		} else {
+			buf, err := ioutil.ReadAll(body)
+			body.Close()
+			if err != nil {
+				return err
+			}
+			files = append(files, bundleFile{bundleArtifacts, buf})
+		}
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		if err := file.write(tw); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export <id> [bundle]",
+	Short: "Packages a job into a bundle that can be shared.",
+	Long: `Packages the metadata, submitted build file, source digest, output and build directory of a ` +
+		`job into a single gzip compressed tar archive, by default rai-job-<id>.tar.gz. The bundle can ` +
+		`be inspected on another machine with rai import.`,
+	SilenceUsage: true,
+	Args:         cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+		out := "rai-job-" + job.ID + ".tar.gz"
+		if len(args) == 2 {
+			out = args[1]
+		}
+		if !strings.HasSuffix(out, ".tar.gz") && !strings.HasSuffix(out, ".tgz") {
+			return errors.Errorf("bundles are gzip compressed tar archives, expecting %v to end with .tar.gz", out)
+		}
+		if com.IsFile(out) && !forceOutput {
+			return errors.Errorf("%v already exists. Use --force to overwrite it", out)
+		}
+		if err := exportJob(job, out); err != nil {
+			os.Remove(out)
+			return err
+		}
+		fmt.Printf("Job %v was exported to %v\n", job.ID, out)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().BoolVar(&exportNoArtifacts, "no-artifacts", false, "Do not include the build directory.")
+	RootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// feedbackItem is the grade given for a single rubric criterion
+type feedbackItem struct {
+	Criterion string  `json:"criterion"`
+	Score     float64 `json:"score"`
+	MaxScore  float64 `json:"max_score"`
+	Comment   string  `json:"comment"`
+}
+
+// feedback is the grading feedback of a submission
+type feedback struct {
+	Submission string         `json:"submission"`
+	Grader     string         `json:"grader"`
+	Score      float64        `json:"score"`
+	MaxScore   float64        `json:"max_score"`
+	Comment    string         `json:"comment"`
+	Items      []feedbackItem `json:"items"`
+}
+
+// fetchFeedback retrieves the grading feedback of the submission from the
+// endpoint configured by client.feedback_url. The {username} and
+// {submission} placeholders within the url are replaced accordingly.
+func fetchFeedback(submission string) (*feedback, error) {
+	endpoint := viper.GetString("client.feedback_url")
+	if endpoint == "" {
+		return nil, errors.New("grading feedback is not available. The client.feedback_url endpoint is not configured")
+	}
+
+	prof, err := loadProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint = strings.NewReplacer(
+		"{username}", url.PathEscape(prof.Info().Username),
+		"{submission}", url.PathEscape(submission),
+	).Replace(endpoint)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	info, err := profileInfo(prof)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(prof.Info().Username, cast.ToString(info["access_key"]))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch the grading feedback")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errors.Errorf("no feedback is available for the %v submission yet", submission)
+	default:
+		return nil, errors.Errorf("unable to fetch the grading feedback: %v", resp.Status)
+	}
+
+	fb := &feedback{}
+	if err := json.NewDecoder(resp.Body).Decode(fb); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the grading feedback")
+	}
+	return fb, nil
+}
+
+var feedbackCmd = &cobra.Command{
+	Use:          "feedback <submission>",
+	Short:        "Prints the grading feedback of a submission.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fb, err := fetchFeedback(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Feedback for the %v submission", args[0])
+		if fb.Grader != "" {
+			fmt.Printf(" (graded by %v)", fb.Grader)
+		}
+		fmt.Println()
+		fmt.Println()
+
+		score := func(s, max float64) string {
+			return cast.ToString(s) + " / " + cast.ToString(max)
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Criterion", "Score", "Comment"})
+		for _, item := range fb.Items {
+			table.Append([]string{item.Criterion, score(item.Score, item.MaxScore), item.Comment})
+		}
+		table.SetFooter([]string{"Total", score(fb.Score, fb.MaxScore), ""})
+		table.Render()
+
+		if fb.Comment != "" {
+			fmt.Println()
+			fmt.Println(fb.Comment)
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(feedbackCmd)
+}
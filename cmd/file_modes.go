@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// executableExtensions are the extensions of the files that are made
+// executable even if they do not start with a shebang
+var executableExtensions = []string{".sh", ".bash"}
+
+// hasShebang returns true if the file starts with #!
+func hasShebang(file uploadFile) bool {
+	f, err := os.Open(file.Source)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, 2)
+	n, _ := f.Read(buf)
+	return bytes.Equal(buf[:n], []byte("#!"))
+}
+
+// isExecutable returns true if the file is meant to be executable: it
+// matches one of the patterns of the executables section of the build
+// file, has a script extension, or starts with a shebang
+func isExecutable(file uploadFile, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, file.Path)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid executables pattern %v", pattern)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	for _, ext := range executableExtensions {
+		if strings.HasSuffix(file.Path, ext) {
+			return true, nil
+		}
+	}
+	return hasShebang(file), nil
+}
+
+// missingExecutableBits returns the files that are meant to be executable
+// but are uploaded without the executable bit, as happens for every file
+// submitted from Windows
+func missingExecutableBits(files []uploadFile, patterns []string) ([]string, error) {
+	missing := []string{}
+	for _, file := range files {
+		if !file.Mode.IsRegular() || file.Mode&0111 != 0 {
+			continue
+		}
+		ok, err := isExecutable(file, patterns)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			missing = append(missing, file.Path)
+		}
+	}
+	return missing, nil
+}
+
+// shellQuote quotes the string for use as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func init() {
+	// the executable bits are restored before the build commands run. The
+	// executables section is handled by the client and is not submitted.
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		executables, declared := getMapSliceEntry(doc, "executables")
+		if declared {
+			doc = deleteMapSliceEntry(doc, "executables")
+		}
+		build := buildCommands(doc)
+		if len(build) == 0 {
+			return doc, declared, nil
+		}
+		files, _, err := collectUploadFiles(workingDir)
+		if err != nil {
+			return nil, false, err
+		}
+		missing, err := missingExecutableBits(files, cast.ToStringSlice(executables))
+		if err != nil {
+			return nil, false, err
+		}
+		if len(missing) == 0 {
+			return doc, declared, nil
+		}
+		for ii, file := range missing {
+			missing[ii] = shellQuote(file)
+		}
+		chmod := "(cd /src && chmod +x " + strings.Join(missing, " ") + ")"
+		return setBuildCommands(doc, append([]string{chmod}, build...)), true, nil
+	})
+}
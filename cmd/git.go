@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// fromGitRef is the git reference whose committed tree is submitted
+// instead of the working tree
+var fromGitRef string
+
+// runGit runs git within the directory and returns its trimmed output
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", errors.Errorf("git %v failed: %v", strings.Join(args, " "), msg)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitSnapshot extracts the directory as committed in the reference into a
+// temporary directory. It returns the snapshot directory and the commit
+// the reference resolved to.
+func gitSnapshot(dir, ref string) (string, string, func(), error) {
+	noop := func() {}
+	commit, err := runGit(dir, "rev-parse", "--verify", ref+"^{commit}")
+	if err != nil {
+		return "", "", noop, errors.Wrapf(err, "unable to resolve %v", ref)
+	}
+	// the project may be a subdirectory of the repository
+	prefix, err := runGit(dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", "", noop, err
+	}
+	top, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", noop, err
+	}
+
+	snapshot, err := ioutil.TempDir("", "rai-git")
+	if err != nil {
+		return "", "", noop, err
+	}
+	cleanup := func() {
+		os.RemoveAll(snapshot)
+	}
+
+	cmd := exec.Command("git", "archive", "--format=tar", commit+":"+prefix)
+	cmd.Dir = top
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return "", "", noop, err
+	}
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return "", "", noop, err
+	}
+	_, extractErr := extractTarPath(stdout, snapshot, "")
+	if extractErr != nil {
+		// drain the archive so that git can exit
+		ioutil.ReadAll(stdout)
+	}
+	if err := cmd.Wait(); err != nil {
+		cleanup()
+		return "", "", noop, errors.Errorf("git archive failed: %v", strings.TrimSpace(stderr.String()))
+	}
+	if extractErr != nil {
+		cleanup()
+		return "", "", noop, extractErr
+	}
+	return snapshot, commit, cleanup, nil
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+
+	"github.com/acarl005/stripansi"
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+)
+
+var (
+	// grepPatterns only shows the lines of the output that match one of them
+	grepPatterns []string
+	// grepExcludePatterns hides the lines of the output that match one of
+	// them
+	grepExcludePatterns []string
+	// highlightPatterns colors the matches in the lines of the output
+	highlightPatterns []string
+)
+
+// lineFilter selects and highlights the lines of the job output that are
+// shown on the terminal
+type lineFilter struct {
+	grep      []*regexp.Regexp
+	exclude   []*regexp.Regexp
+	highlight []*regexp.Regexp
+}
+
+func compilePatterns(flag string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for ii, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %v pattern %v", flag, pattern)
+		}
+		compiled[ii] = re
+	}
+	return compiled, nil
+}
+
+// newLineFilter compiles the patterns of --grep, --grep-v and --highlight.
+// It returns nil if none are given.
+func newLineFilter() (*lineFilter, error) {
+	if len(grepPatterns) == 0 && len(grepExcludePatterns) == 0 && len(highlightPatterns) == 0 {
+		return nil, nil
+	}
+	var err error
+	filter := &lineFilter{}
+	if filter.grep, err = compilePatterns("--grep", grepPatterns); err != nil {
+		return nil, err
+	}
+	if filter.exclude, err = compilePatterns("--grep-v", grepExcludePatterns); err != nil {
+		return nil, err
+	}
+	if filter.highlight, err = compilePatterns("--highlight", highlightPatterns); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, line string) bool {
+	for _, re := range patterns {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns the line as it is shown, and false if it is hidden. The
+// patterns are matched against the line without its colors.
+func (f *lineFilter) apply(line string) (string, bool) {
+	plain := stripansi.Strip(line)
+	if len(f.grep) != 0 && !matchesAny(f.grep, plain) {
+		return "", false
+	}
+	if matchesAny(f.exclude, plain) {
+		return "", false
+	}
+	if color.NoColor || !matchesAny(f.highlight, plain) {
+		return line, true
+	}
+	for _, re := range f.highlight {
+		plain = re.ReplaceAllStringFunc(plain, func(match string) string {
+			return themeString(themeHighlight, match)
+		})
+	}
+	return plain, true
+}
+
+// filteredLines writes the lines that the filter shows. Partial lines are
+// held until they are complete.
+type filteredLines struct {
+	sync.Mutex
+	w       io.Writer
+	filter  *lineFilter
+	pending []byte
+}
+
+func (f *filteredLines) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.pending = append(f.pending, p...)
+	buf := new(bytes.Buffer)
+	for {
+		idx := bytes.IndexByte(f.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		if line, ok := f.filter.apply(string(f.pending[:idx])); ok {
+			buf.WriteString(line + "\n")
+		}
+		f.pending = f.pending[idx+1:]
+	}
+	if _, err := f.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the partial line that is held, if it is shown
+func (f *filteredLines) Flush() error {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.pending) == 0 {
+		return nil
+	}
+	line, ok := f.filter.apply(string(f.pending))
+	f.pending = nil
+	if !ok {
+		return nil
+	}
+	_, err := io.WriteString(f.w, line)
+	return err
+}
+
+// withLineFilter filters the lines written to w if patterns are given
+func withLineFilter(w io.Writer, filter *lineFilter) io.Writer {
+	if filter == nil {
+		return w
+	}
+	return &filteredLines{w: w, filter: filter}
+}
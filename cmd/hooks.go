@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acarl005/stripansi"
+)
+
+// uploadHookInterval is how often the upload progress hooks are called
+const uploadHookInterval = time.Second
+
+// jobHooks are the functions that programs embedding rai register to react
+// to the lifecycle of the jobs without reading their output
+type jobHooks struct {
+	sync.Mutex
+	uploadProgress []func(size int64, elapsed time.Duration, done bool)
+	queued         []func(jobID, queue string)
+	stdout         []func(line string)
+	stderr         []func(line string)
+	complete       []func(jobID string, err error)
+}
+
+var hooks jobHooks
+
+// OnUploadProgress registers fn to be called every second while the
+// directory of a job is uploaded, and once more with done set when the
+// upload ends. The client library does not report the bytes that were
+// sent, so the size of the upload is given along with the elapsed time.
+func OnUploadProgress(fn func(size int64, elapsed time.Duration, done bool)) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.uploadProgress = append(hooks.uploadProgress, fn)
+}
+
+// OnJobQueued registers fn to be called when a job is published to a queue
+func OnJobQueued(fn func(jobID, queue string)) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.queued = append(hooks.queued, fn)
+}
+
+// OnStdout registers fn to be called with every line that the build
+// commands write to stdout, without colors and with the secrets masked
+func OnStdout(fn func(line string)) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.stdout = append(hooks.stdout, fn)
+}
+
+// OnStderr registers fn to be called with every line that the build
+// commands write to stderr, without colors and with the secrets masked
+func OnStderr(fn func(line string)) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.stderr = append(hooks.stderr, fn)
+}
+
+// OnComplete registers fn to be called once a job ends, with the error
+// that it failed with. ExitCode gives the exit code of the error.
+func OnComplete(fn func(jobID string, err error)) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.complete = append(hooks.complete, fn)
+}
+
+// jobQueued calls the hooks registered with OnJobQueued
+func (h *jobHooks) jobQueued(job *jobRecord, queue string) {
+	h.Lock()
+	fns := h.queued
+	h.Unlock()
+	for _, fn := range fns {
+		fn(job.ID, queue)
+	}
+}
+
+// jobCompleted calls the hooks registered with OnComplete
+func (h *jobHooks) jobCompleted(job *jobRecord, err error) {
+	h.Lock()
+	fns := h.complete
+	h.Unlock()
+	for _, fn := range fns {
+		fn(job.ID, err)
+	}
+}
+
+// startUpload calls the hooks registered with OnUploadProgress until the
+// returned function is called at the end of the upload
+func (h *jobHooks) startUpload(size int64) func() {
+	h.Lock()
+	fns := h.uploadProgress
+	h.Unlock()
+	if len(fns) == 0 {
+		return func() {}
+	}
+	start := time.Now()
+	report := func(done bool) {
+		for _, fn := range fns {
+			fn(size, time.Since(start), done)
+		}
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(uploadHookInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				report(false)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		wg.Wait()
+		report(true)
+	}
+}
+
+// hookLines calls the line hooks of the stream with every line written to
+// it, leaving out the lines that are read by the client rather than shown
+type hookLines struct {
+	sync.Mutex
+	fns     []func(line string)
+	pending []byte
+}
+
+func (l *hookLines) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	l.pending = append(l.pending, p...)
+	for {
+		idx := bytes.IndexByte(l.pending, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimRight(stripansi.Strip(string(l.pending[:idx])), "\r")
+		l.pending = l.pending[idx+1:]
+		if isHiddenLine([]byte(line), false) {
+			continue
+		}
+		for _, fn := range l.fns {
+			fn(line)
+		}
+	}
+}
+
+// withHooks calls the line hooks registered for the stream, stdout or
+// stderr, with the output written to w
+func withHooks(w io.Writer, stream string) io.Writer {
+	hooks.Lock()
+	fns := hooks.stdout
+	if stream == "stderr" {
+		fns = hooks.stderr
+	}
+	hooks.Unlock()
+	if len(fns) == 0 {
+		return w
+	}
+	return io.MultiWriter(w, &hookLines{fns: fns})
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strings"
+)
+
+// ignoreRule is a pattern of an ignore file using the .gitignore syntax
+type ignoreRule struct {
+	// base is the slash separated directory containing the ignore file,
+	// relative to the uploaded directory
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ignoreMatcher decides which paths are excluded from the upload. The last
+// rule matching a path decides whether it is excluded.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// addIgnoreFile adds the rules of the ignore file located in the slash
+// separated base directory
+func (m *ignoreMatcher) addIgnoreFile(buf []byte, base string) {
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		// patterns without an inner slash match at any depth
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// excluded returns true if the slash separated path, relative to the
+// uploaded directory, is excluded
+func (m *ignoreMatcher) excluded(rel string, isDir bool) bool {
+	excluded := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		p := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			p = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if matchIgnoreSegments(rule.segments, strings.Split(p, "/")) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// matchIgnoreSegments matches the path segments against the pattern
+// segments, where ** matches any number of segments
+func matchIgnoreSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for ii := 0; ii <= len(segments); ii++ {
+			if matchIgnoreSegments(pattern[1:], segments[ii:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+		return false
+	}
+	return matchIgnoreSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// readBundle returns the files within a job bundle
+func readBundle(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%v is not a job bundle", path)
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the job bundle %v", path)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		buf, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = buf
+	}
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Adds a job exported with rai export to the local job store.",
+	Long: `Adds a job bundle created by rai export to the local job store, so that it can be inspected ` +
+		`with the job, logs, replay, diff, cp and artifacts commands.`,
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := readBundle(args[0])
+		if err != nil {
+			return err
+		}
+		metadata, ok := files[bundleMetadata]
+		if !ok {
+			return errors.Errorf("%v is not a job bundle, it does not contain %v", args[0], bundleMetadata)
+		}
+		job := &jobRecord{}
+		if err := yaml.Unmarshal(metadata, job); err != nil {
+			return errors.Wrapf(err, "unable to parse the job metadata in %v", args[0])
+		}
+		if job.ID == "" {
+			return errors.Errorf("the job metadata in %v has no id", args[0])
+		}
+		if err := checkJobID(job.ID); err != nil {
+			return errors.Wrapf(err, "the job metadata in %v is invalid", args[0])
+		}
+		// the process that submitted the job does not run on this machine
+		job.PID = 0
+
+		path, err := job.path()
+		if err != nil {
+			return err
+		}
+		if com.IsFile(path) && !forceOutput {
+			return errors.Errorf("job %v already exists in the job store. Use --force to overwrite it", job.ID)
+		}
+		if err := job.save(); err != nil {
+			return err
+		}
+
+		for name, dest := range map[string]func() (string, error){
+			bundleBuildFile: job.submittedBuildFilePath,
+			bundleManifest:  job.manifestPath,
+			bundleLog:       job.logPath,
+			bundleCast:      job.castPath,
+			bundleArtifacts: job.artifactsPath,
+		} {
+			buf, ok := files[name]
+			if !ok {
+				continue
+			}
+			p, err := dest()
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(p, buf, 0600); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Imported job %v (%v", job.ID, job.Phase)
+		if job.SubmissionTag != "" {
+			fmt.Printf(", %v submission", job.SubmissionTag)
+		}
+		fmt.Printf(", created %v)\n", job.CreatedAt.Format("2006-01-02 15:04:05"))
+		if job.SourceDigest != "" {
+			fmt.Printf("Source digest: %v\n", job.SourceDigest)
+		}
+		fmt.Printf("Use `rai job logs %v` or `rai replay %v` to inspect it.\n", job.ID, job.ID)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+}
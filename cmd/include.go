@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// includeFlags are the --include mappings given on the command line
+var includeFlags []string
+
+// uploadInclude maps a file or directory outside of the submitted
+// directory to a path within the upload
+type uploadInclude struct {
+	// Source is the absolute location of the included file or directory
+	Source string
+	// Dest is the slash separated path it is uploaded as
+	Dest string
+}
+
+// parseInclude parses a src:dest mapping. Relative sources are resolved
+// against base. The destination defaults to the base name of the source.
+func parseInclude(mapping, base string) (uploadInclude, error) {
+	src, dest := mapping, ""
+	if idx := strings.LastIndex(mapping, ":"); idx > 0 && !filepath.IsAbs(mapping[idx+1:]) {
+		src, dest = mapping[:idx], mapping[idx+1:]
+	}
+	if src == "" {
+		return uploadInclude{}, errors.Errorf("invalid include %v, expecting src:dest", mapping)
+	}
+	if !filepath.IsAbs(src) {
+		src = filepath.Join(base, src)
+	}
+	if dest == "" {
+		dest = filepath.Base(src)
+	}
+	dest = filepath.ToSlash(filepath.Clean(filepath.FromSlash(dest)))
+	if dest == "." || dest == ".." || strings.HasPrefix(dest, "../") || strings.HasPrefix(dest, "/") {
+		return uploadInclude{}, errors.Errorf("invalid include %v, the destination must be a path within the uploaded directory", mapping)
+	}
+	if _, err := os.Stat(src); err != nil {
+		return uploadInclude{}, errors.Wrapf(err, "unable to include %v", mapping)
+	}
+	return uploadInclude{Source: src, Dest: dest}, nil
+}
+
+// buildFileIncludes returns the entries of the includes section of the
+// build file
+func buildFileIncludes() ([]string, error) {
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return nil, nil
+	}
+	_, doc, _, err := readBuildDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	includes, _ := getMapSliceEntry(doc, "includes")
+	return cast.ToStringSlice(includes), nil
+}
+
+// uploadIncludes returns the mappings given using --include, which are
+// relative to the current directory, followed by the ones in the includes
+// section of the build file, which are relative to the build file
+func uploadIncludes() ([]uploadInclude, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	includes := []uploadInclude{}
+	for _, mapping := range includeFlags {
+		include, err := parseInclude(mapping, cwd)
+		if err != nil {
+			return nil, err
+		}
+		includes = append(includes, include)
+	}
+	mappings, err := buildFileIncludes()
+	if err != nil {
+		return nil, err
+	}
+	for _, mapping := range mappings {
+		include, err := parseInclude(mapping, filepath.Dir(buildFileLocation()))
+		if err != nil {
+			return nil, err
+		}
+		includes = append(includes, include)
+	}
+	return includes, nil
+}
+
+func init() {
+	// the includes are resolved by the client, so they are not part of the
+	// build file that is submitted
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		if _, ok := getMapSliceEntry(doc, "includes"); !ok {
+			return doc, false, nil
+		}
+		return deleteMapSliceEntry(doc, "includes"), true, nil
+	})
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initTemplateName string
+	initDockerfile   string
+)
+
+const defaultRaiIgnore = `# Files matching these patterns are not uploaded with the job.
+# The syntax is the same as the one used by .gitignore files.
+.git/
+build/
+*.o
+*.so
+__pycache__/
+`
+
+// promptDefault prompts for a value, returning the default value when the
+// user enters nothing
+func promptDefault(reader *bufio.Reader, label, defaultValue string) (string, error) {
+	value, err := prompt(reader, fmt.Sprintf("%v [%v]", label, defaultValue), false)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return defaultValue, nil
+	}
+	return value, nil
+}
+
+// selectBuildTemplate returns the template given using --template, or the
+// one that the user picks from the available templates
+func selectBuildTemplate(reader *bufio.Reader) (buildTemplate, error) {
+	templateName := initTemplateName
+	if templateName == "" {
+		templates, err := availableBuildTemplates()
+		if err != nil {
+			return buildTemplate{}, err
+		}
+		for _, tmpl := range templates {
+			fmt.Printf("  %-20s %v\n", tmpl.Name, tmpl.Description)
+		}
+		if templateName, err = promptDefault(reader, "Template", templates[0].Name); err != nil {
+			return buildTemplate{}, err
+		}
+	}
+	return findBuildTemplate(templateName)
+}
+
+// dockerfileTemplate translates the Dockerfile, relative to the project
+// directory, into a template and prints the instructions that were not
+// translated
+func dockerfileTemplate(name string) (buildTemplate, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return buildTemplate{}, err
+	}
+	defer f.Close()
+	tmpl, warnings, err := templateFromDockerfile(f)
+	if err != nil {
+		return buildTemplate{}, errors.Wrapf(err, "unable to translate %v", path)
+	}
+	for _, warning := range warnings {
+		fmt.Printf("Warning: %v: %v\n", name, warning)
+	}
+	return tmpl, nil
+}
+
+var initCmd = &cobra.Command{
+	Use:          "init",
+	Short:        "Creates a starter rai_build.yml file in the project directory.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := buildFileLocation()
+		if com.IsFile(path) && !forceOutput {
+			return errors.Errorf("%v already exists. Use --force to overwrite it", path)
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+
+		var tmpl buildTemplate
+		var err error
+		if initDockerfile != "" {
+			if tmpl, err = dockerfileTemplate(initDockerfile); err != nil {
+				return err
+			}
+		} else if tmpl, err = selectBuildTemplate(reader); err != nil {
+			return err
+		}
+
+		queueName := jobQueueName
+		if queueName == "" {
+			queues, err := configuredQueues()
+			if err != nil {
+				return err
+			}
+			for _, queue := range queues {
+				fmt.Printf("  %-20s %v %v\n", queue.Name, queue.Architecture, queue.GPU)
+			}
+			defaultQueue := tmpl.Queue
+			if defaultQueue == "" {
+				defaultQueue = defaultQueueName()
+			}
+			if queueName, err = promptDefault(reader, "Queue", defaultQueue); err != nil {
+				return err
+			}
+		}
+
+		if initDockerfile == "" {
+			if tmpl.Image, err = promptDefault(reader, "Docker image", tmpl.Image); err != nil {
+				return err
+			}
+		}
+
+		params, err := newBuildFileParams(tmpl, queueName)
+		if err != nil {
+			return err
+		}
+		buf, err := params.render()
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Created %v\n", path)
+
+		ignorePath := filepath.Join(workingDir, ".raiignore")
+		if com.IsFile(ignorePath) {
+			return nil
+		}
+		answer, err := prompt(reader, "Create a .raiignore file [y/N]", false)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(strings.ToLower(answer), "y") {
+			if err := ioutil.WriteFile(ignorePath, []byte(defaultRaiIgnore), 0644); err != nil {
+				return err
+			}
+			fmt.Printf("Created %v\n", ignorePath)
+		}
+		return nil
+	},
+}
+
+func init() {
+	initCmd.Flags().StringVar(&initTemplateName, "template", "", "Name of the build file template to use.")
+	initCmd.Flags().StringVar(&initDockerfile, "from-dockerfile", "", "Translate the Dockerfile (e.g. Dockerfile) into the build file instead of using a template.")
+	RootCmd.AddCommand(initCmd)
+}
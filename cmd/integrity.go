@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// checksumFileName is the file, in the format of sha256sum, that lists the
+// digest of every uploaded file. It is uploaded along with the files.
+const checksumFileName = ".rai_sha256sums"
+
+// verifyUploads returns true if the uploaded files are checked against the
+// checksum file before the build commands run. It is disabled using
+// --skip-upload-verification or client.skip_upload_verification.
+func verifyUploads() bool {
+	return !viper.GetBool("client.skip_upload_verification")
+}
+
+// uploadChecksums returns the content of the checksum file for the files.
+// Symbolic links are not listed since they are uploaded as is.
+func uploadChecksums(files []uploadFile) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, file := range files {
+		if file.Mode&os.ModeSymlink != 0 {
+			continue
+		}
+		f, err := os.Open(file.Source)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(buf, "%v  %v\n", hex.EncodeToString(h.Sum(nil)), file.Path)
+	}
+	return buf.Bytes(), nil
+}
+
+// checksumsDigest is the content hash of the submission. It can be
+// reproduced by running sha256sum on the checksum file.
+func checksumsDigest(checksums []byte) string {
+	digest := sha256.Sum256(checksums)
+	return hex.EncodeToString(digest[:])
+}
+
+// verifyChecksumsCommand fails the build if a file was corrupted during the
+// upload
+const verifyChecksumsCommand = "(cd /src && sha256sum --quiet -c " + checksumFileName + ")"
+
+func init() {
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		build := buildCommands(doc)
+		if !verifyUploads() || len(build) == 0 {
+			return doc, false, nil
+		}
+		return setBuildCommands(doc, append([]string{verifyChecksumsCommand}, build...)), true, nil
+	})
+}
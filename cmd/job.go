@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// jobCmd groups the commands that operate on previously submitted jobs
+var jobCmd = &cobra.Command{
+	Use:          "job",
+	Short:        "Inspect jobs that were submitted from this machine.",
+	SilenceUsage: true,
+}
+
+func init() {
+	RootCmd.AddCommand(jobCmd)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var jobArtifactsOutput string
+
+var jobArtifactsCmd = &cobra.Command{
+	Use:          "artifacts <id>",
+	Short:        "Downloads the build directory of a finished job.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+		out := jobArtifactsOutput
+		if out == "" {
+			out = "build-" + job.ID
+		}
+		if com.IsDir(out) && !forceOutput {
+			return errors.Errorf("the output directory %v already exists. Use --force to overwrite it", out)
+		}
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return err
+		}
+
+		return downloadArtifacts(job, out)
+	},
+}
+
+// fetchArtifacts opens the build directory archive of a job
+func fetchArtifacts(url string) (io.ReadCloser, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to download %v", url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("unable to download %v: %v. The build directory is only kept for a short duration of time", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// openArtifacts opens the build directory archive of the job. An archive
+// imported with the job is used before downloading it from the server.
+func openArtifacts(job *jobRecord) (io.ReadCloser, error) {
+	path, err := job.artifactsPath()
+	if err != nil {
+		return nil, err
+	}
+	if com.IsFile(path) {
+		return os.Open(path)
+	}
+	url := job.BuildURL
+	if url == "" {
+		url = job.findBuildURL()
+	}
+	if url == "" {
+		return nil, errors.Errorf("job %v did not produce a build directory", job.ID)
+	}
+	return fetchArtifacts(url)
+}
+
+// downloadArtifacts fetches the build directory archive and extracts it
+func downloadArtifacts(job *jobRecord, out string) error {
+	body, err := openArtifacts(job)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := extractTarGz(body, out); err != nil {
+		return err
+	}
+	fmt.Printf("The build directory was downloaded to %v\n", out)
+	return nil
+}
+
+func init() {
+	jobArtifactsCmd.Flags().StringVar(&jobArtifactsOutput, "out", "", "Directory to download the artifacts to. Defaults to build-<id>.")
+	jobCmd.AddCommand(jobArtifactsCmd)
+}
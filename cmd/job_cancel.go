@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobCancelAll bool
+	jobCancelYes bool
+)
+
+// cancelAllJobs cancels every job that a rai process is still attached to
+// after asking for confirmation. The other jobs that are not done are left
+// by processes that are gone, so they are marked as failed without
+// signalling their PIDs.
+func cancelAllJobs() error {
+	jobs, err := listJobRecords()
+	if err != nil {
+		return err
+	}
+	var active, stale []*jobRecord
+	for _, job := range jobs {
+		switch {
+		case job.isDone():
+		case job.isAttached():
+			active = append(active, job)
+		default:
+			stale = append(stale, job)
+		}
+	}
+	for _, job := range stale {
+		job.fail(errors.New("the rai process that submitted the job is no longer running"))
+		fmt.Printf("Job %v was marked as failed, since the rai process that submitted it is no longer running.\n", job.ID)
+	}
+	if len(active) == 0 {
+		fmt.Println("There are no queued or running jobs.")
+		return nil
+	}
+
+	for _, job := range active {
+		fmt.Printf("  %v  %-10v %v\n", job.ID, job.Phase, job.Directory)
+	}
+	if !jobCancelYes {
+		answer, err := prompt(bufio.NewReader(os.Stdin), fmt.Sprintf("Cancel these %v jobs [y/N]", len(active)), false)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(strings.ToLower(answer), "y") {
+			return nil
+		}
+	}
+
+	failed := 0
+	for _, job := range active {
+		if err := cancelJob(job); err != nil {
+			fmt.Println(err)
+			failed++
+			continue
+		}
+		fmt.Printf("Job %v was cancelled.\n", job.ID)
+	}
+	if failed > 0 {
+		return errors.Errorf("%v jobs could not be cancelled", failed)
+	}
+	return nil
+}
+
+var jobCancelCmd = &cobra.Command{
+	Use:   "cancel <id> | --all",
+	Short: "Aborts a queued or running job.",
+	Long: `Aborts a queued or running job by disconnecting the rai process that submitted it from the server. ` +
+		`With --all every queued or running job is aborted after confirmation. ` +
+		`Jobs whose rai process is no longer running are marked as failed. ` +
+		`The server is not asked to stop the job, so it may keep running and hold its rate limit slot until it ends.`,
+	SilenceUsage: true,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if jobCancelAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if jobCancelAll {
+			return cancelAllJobs()
+		}
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+		if err := cancelJob(job); err != nil {
+			return err
+		}
+		fmt.Printf("Job %v was cancelled.\n", job.ID)
+		return nil
+	},
+}
+
+// cancelJob interrupts the rai process attached to the job and marks the
+// job as cancelled. A job that no process is attached to, such as after a
+// crash, is marked as failed instead, since its PID may have been reused
+// by another process.
+func cancelJob(job *jobRecord) error {
+	if job.isDone() {
+		return errors.Errorf("job %v is already %v", job.ID, job.Phase)
+	}
+	if !job.isAttached() {
+		job.fail(errors.New("the rai process that submitted the job is no longer running"))
+		return errors.Errorf("the rai process that submitted job %v is no longer running. The job was marked as failed.", job.ID)
+	}
+	// the attached process cancels the job without asking once it is
+	// marked as cancelled
+	job.Error = "cancelled by the user"
+	job.setPhase(jobPhaseCancelled)
+	if err := interruptProcess(job.PID); err != nil {
+		return errors.Wrapf(err, "unable to interrupt the rai process (pid %v) attached to job %v", job.PID, job.ID)
+	}
+	return nil
+}
+
+func init() {
+	jobCancelCmd.Flags().BoolVar(&jobCancelAll, "all", false, "Cancel all queued and running jobs.")
+	jobCancelCmd.Flags().BoolVarP(&jobCancelYes, "yes", "y", false, "Do not ask for confirmation.")
+	jobCmd.AddCommand(jobCancelCmd)
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobListLimit int
+	jobListSince time.Duration
+)
+
+var jobListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lists the recent jobs submitted from this machine.",
+	Long: `Lists the recent jobs submitted from this machine, from the records that rai keeps in ` +
+		"`~/.rai_jobs`" + `. The server is not queried, so jobs submitted from other machines are ` +
+		`not shown and the status is the last one seen by the client.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobs, err := listJobRecords()
+		if err != nil {
+			return err
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Job", "Queue", "Submission", "Created", "Status"})
+
+		count := 0
+		for _, job := range jobs {
+			if jobListLimit > 0 && count >= jobListLimit {
+				break
+			}
+			if jobListSince > 0 && time.Since(job.CreatedAt) > jobListSince {
+				break
+			}
+			table.Append([]string{
+				job.ID,
+				job.Queue,
+				job.SubmissionTag,
+				job.CreatedAt.Format("2006-01-02 15:04:05"),
+				job.displayPhase(),
+			})
+			count++
+		}
+
+		if count == 0 {
+			fmt.Println("No jobs were found.")
+			return nil
+		}
+		table.Render()
+		return nil
+	},
+}
+
+func init() {
+	jobListCmd.Flags().IntVarP(&jobListLimit, "limit", "n", 10, "Maximum number of jobs to show (0 shows all jobs).")
+	jobListCmd.Flags().DurationVar(&jobListSince, "since", 0, "Only show jobs created within this duration (e.g. 24h).")
+	jobCmd.AddCommand(jobListCmd)
+}
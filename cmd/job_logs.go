@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobLogsFollow bool
+	jobLogsTail   int
+	jobLogsSince  time.Duration
+	jobLogsRange  string
+)
+
+// tailOffset returns the offset of the last n lines of the file, reading
+// it backwards from the end in blocks
+func tailOffset(f *os.File, n int) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	const blockSize = 64 * 1024
+	end := info.Size()
+	// a trailing newline does not start another line
+	lines := -1
+	buf := make([]byte, blockSize)
+	for offset := end; offset > 0; {
+		size := int64(blockSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		if _, err := f.ReadAt(buf[:size], offset); err != nil && err != io.EOF {
+			return 0, err
+		}
+		block := buf[:size]
+		for ii := len(block) - 1; ii >= 0; ii-- {
+			if block[ii] != '\n' {
+				continue
+			}
+			if offset+int64(ii) == end-1 {
+				continue
+			}
+			lines++
+			if lines == n-1 {
+				return offset + int64(ii) + 1, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// parseByteRange parses a range of bytes of the log as start-end, start-
+// or -length for the last bytes. end is -1 when the range extends to the
+// end of the log.
+func parseByteRange(spec string, size int64) (int64, int64, error) {
+	invalid := errors.Errorf("invalid range %v, expecting start-end, start- or -length", spec)
+	idx := strings.Index(spec, "-")
+	if idx < 0 {
+		return 0, 0, invalid
+	}
+	first, last := spec[:idx], spec[idx+1:]
+	if first == "" {
+		length, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || length < 0 {
+			return 0, 0, invalid
+		}
+		if length > size {
+			length = size
+		}
+		return size - length, -1, nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, invalid
+	}
+	if last == "" {
+		return start, -1, nil
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, invalid
+	}
+	return start, end, nil
+}
+
+// printRecordingSince prints the output of the job that was written in
+// the last since, using the timing of its recording
+func printRecordingSince(job *jobRecord, since time.Duration) error {
+	path, err := job.castPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "no recording was captured for job %v, which --since needs", job.ID)
+	}
+	defer f.Close()
+	// the times of the events are relative to the timestamp of the header
+	header := castHeader{}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return errors.Wrap(err, "invalid recording header")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	start := time.Unix(header.Timestamp, 0)
+	cutoff := time.Now().Add(-since)
+	_, err = readCastEvents(f, func(event castEvent) error {
+		at := start.Add(time.Duration(event.Time * float64(time.Second)))
+		if at.Before(cutoff) {
+			return nil
+		}
+		_, err := io.WriteString(os.Stdout, event.Data)
+		return err
+	})
+	return err
+}
+
+var jobLogsCmd = &cobra.Command{
+	Use:   "logs <id>",
+	Short: "Prints the output of a job.",
+	Long: `Prints the output captured for a job in ~/.rai_jobs by the rai process that submitted it. ` +
+		`With --follow the output keeps being printed while that process is running. ` +
+		`The output is not fetched from the server: once the submitting process has exited, ` +
+		`for example after Ctrl-C or a lost connection, the output written by the job afterwards can not be recovered. ` +
+		`--tail, --since and --range print a part of the output without reading the rest of it.`,
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+		path, err := job.logPath()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrapf(err, "no output was captured for job %v", job.ID)
+		}
+		defer f.Close()
+
+		if jobLogsSince > 0 {
+			if jobLogsTail > 0 || jobLogsRange != "" || jobLogsFollow {
+				return errors.New("--since can not be used with --tail, --range or --follow")
+			}
+			return printRecordingSince(job, jobLogsSince)
+		}
+		if jobLogsTail > 0 && jobLogsRange != "" {
+			return errors.New("--tail and --range can not be used together")
+		}
+		var reader io.Reader = f
+		switch {
+		case jobLogsTail > 0:
+			offset, err := tailOffset(f, jobLogsTail)
+			if err != nil {
+				return err
+			}
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+		case jobLogsRange != "":
+			info, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			start, end, err := parseByteRange(jobLogsRange, info.Size())
+			if err != nil {
+				return err
+			}
+			if _, err := f.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+			if end >= 0 {
+				if jobLogsFollow {
+					return errors.New("--follow can not be used with a range that has an end")
+				}
+				reader = io.LimitReader(f, end-start+1)
+			}
+		}
+
+		// replay the output captured so far
+		if _, err := io.Copy(os.Stdout, reader); err != nil {
+			return err
+		}
+		if !jobLogsFollow {
+			return nil
+		}
+		if !job.isDone() && !job.isAttached() {
+			fmt.Fprintf(os.Stderr, "The rai process that submitted job %v is no longer running, so the rest of its output can not be followed.\n", job.ID)
+			return nil
+		}
+
+		// keep reading the log while the submitting process captures it
+		for job.isAttached() {
+			time.Sleep(500 * time.Millisecond)
+			if _, err := io.Copy(os.Stdout, f); err != nil {
+				return err
+			}
+			if job, err = loadJobRecord(job.ID); err != nil {
+				return err
+			}
+		}
+		_, err = io.Copy(os.Stdout, f)
+		return err
+	},
+}
+
+func init() {
+	jobLogsCmd.Flags().BoolVarP(&jobLogsFollow, "follow", "F", false, "Keep printing the output while the rai process that submitted the job is running.")
+	jobLogsCmd.Flags().IntVar(&jobLogsTail, "tail", 0, "Only print the last lines of the output.")
+	jobLogsCmd.Flags().DurationVar(&jobLogsSince, "since", 0, "Only print the output written in the last duration (e.g. 5m).")
+	jobLogsCmd.Flags().StringVar(&jobLogsRange, "range", "", "Only print the bytes of the output in the range, as start-end, start- or -length.")
+	jobCmd.AddCommand(jobLogsCmd)
+}
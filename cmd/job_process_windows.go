@@ -0,0 +1,38 @@
+// +build windows
+
+package cmd
+
+import (
+	"os"
+)
+
+// lockJobFile creates the file and keeps it open until the returned
+// function is called or the process exits. Files are opened without
+// sharing deletion, so the file cannot be removed while it is open.
+func lockJobFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		f.Close()
+		os.Remove(path)
+	}, nil
+}
+
+// jobFileLocked returns true if a running process holds the file open. The
+// file is removed if it is not.
+func jobFileLocked(path string) bool {
+	err := os.Remove(path)
+	return err != nil && !os.IsNotExist(err)
+}
+
+// interrupts are not delivered to other processes on windows, so
+// the process is killed instead
+func interruptProcess(pid int) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return proc.Kill()
+}
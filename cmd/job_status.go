@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var jobStatusCmd = &cobra.Command{
+	Use:          "status <id>",
+	Short:        "Prints the current phase of a submitted job.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+
+		printTime := func(name string, t time.Time) {
+			if t.IsZero() {
+				return
+			}
+			fmt.Printf("%-12s %v\n", name+":", t.Format(time.RFC1123))
+		}
+
+		fmt.Printf("%-12s %v\n", "Job:", job.ID)
+		fmt.Printf("%-12s %v\n", "Status:", job.displayPhase())
+		if job.Queue != "" {
+			fmt.Printf("%-12s %v\n", "Queue:", job.Queue)
+		}
+		if job.SubmissionTag != "" {
+			fmt.Printf("%-12s %v\n", "Submission:", job.SubmissionTag)
+		}
+		fmt.Printf("%-12s %v\n", "Directory:", job.Directory)
+		if job.GitCommit != "" {
+			fmt.Printf("%-12s %v\n", "Commit:", job.GitCommit)
+		}
+		if len(job.Params) != 0 {
+			fmt.Printf("%-12s %v\n", "Params:", strings.Join(job.Params, " "))
+		}
+		if job.SourceDigest != "" {
+			fmt.Printf("%-12s %v\n", "Source:", job.SourceDigest)
+		}
+		if job.TraceID != "" {
+			fmt.Printf("%-12s %v\n", "Trace:", job.TraceID)
+		}
+		printTime("Created", job.CreatedAt)
+		printTime("Queued", job.QueuedAt)
+		printTime("Started", job.StartedAt)
+		printTime("Finished", job.FinishedAt)
+		if job.BuildURL != "" {
+			fmt.Printf("%-12s %v\n", "Build:", job.BuildURL)
+		}
+		if job.Error != "" {
+			fmt.Printf("%-12s %v\n", "Error:", job.Error)
+		}
+		return nil
+	},
+}
+
+func init() {
+	jobCmd.AddCommand(jobStatusCmd)
+}
@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Unknwon/com"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	log "github.com/rai-project/logger"
+	"gopkg.in/yaml.v2"
+)
+
+type jobPhase string
+
+const (
+	jobPhaseValidating jobPhase = "validating"
+	jobPhaseUploading  jobPhase = "uploading"
+	jobPhaseQueued     jobPhase = "queued"
+	jobPhaseRunning    jobPhase = "running"
+	jobPhaseFinished   jobPhase = "finished"
+	jobPhaseFailed     jobPhase = "failed"
+	jobPhaseCancelled  jobPhase = "cancelled"
+)
+
+// jobRecord is the local bookkeeping kept for every job submitted from
+// this machine. It is stored as yaml within the job store directory.
+type jobRecord struct {
+	ID            string    `yaml:"id"`
+	Queue         string    `yaml:"queue,omitempty"`
+	Directory     string    `yaml:"directory"`
+	BuildFile     string    `yaml:"build_file,omitempty"`
+	SubmissionTag string    `yaml:"submission_tag,omitempty"`
+	Phase         jobPhase  `yaml:"phase"`
+	Error         string    `yaml:"error,omitempty"`
+	PID           int       `yaml:"pid,omitempty"`
+	BuildURL      string    `yaml:"build_url,omitempty"`
+	CreatedAt     time.Time `yaml:"created_at"`
+	QueuedAt      time.Time `yaml:"queued_at,omitempty"`
+	StartedAt     time.Time `yaml:"started_at,omitempty"`
+	FinishedAt    time.Time `yaml:"finished_at,omitempty"`
+	// CommandsDigest identifies the build commands the job ran, so that
+	// runs of the same commands can be compared
+	CommandsDigest string `yaml:"commands_digest,omitempty"`
+	// SourceDigest identifies the content of the uploaded directory. It is
+	// the sha256 of the checksum file uploaded with the job.
+	SourceDigest string `yaml:"source_digest,omitempty"`
+	// TraceID is the OpenTelemetry trace of the submission, which the build
+	// commands are given in TRACEPARENT
+	TraceID string `yaml:"trace_id,omitempty"`
+	// GitCommit is the commit that was submitted with --from-git
+	GitCommit string `yaml:"git_commit,omitempty"`
+	// Params are the sorted name=value parameters of the build file
+	Params []string `yaml:"params,omitempty"`
+}
+
+// jobStoreDir returns the directory where the job records are kept
+func jobStoreDir() (string, error) {
+	dir, err := homedir.Expand("~/.rai_jobs")
+	if err != nil {
+		return "", errors.Wrap(err, "unable to locate the job store directory")
+	}
+	if !com.IsDir(dir) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", errors.Wrapf(err, "unable to create the job store directory %v", dir)
+		}
+	}
+	return dir, nil
+}
+
+func newRandomID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405")
+	}
+	return hex.EncodeToString(buf)
+}
+
+func newJobRecord() *jobRecord {
+	buildFile := buildFilePath
+	if buildFile != "" {
+		if absPath, err := filepath.Abs(buildFile); err == nil {
+			buildFile = absPath
+		}
+	}
+	return &jobRecord{
+		ID:            newRandomID(),
+		Queue:         jobQueueName,
+		Directory:     workingDir,
+		BuildFile:     buildFile,
+		SubmissionTag: submitionName,
+		Phase:         jobPhaseValidating,
+		PID:           os.Getpid(),
+		CreatedAt:     time.Now(),
+
+		CommandsDigest: buildCommandsDigest(),
+	}
+}
+
+func (j *jobRecord) path() (string, error) {
+	dir, err := jobStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, j.ID+".yml"), nil
+}
+
+func (j *jobRecord) logPath() (string, error) {
+	dir, err := jobStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, j.ID+".log"), nil
+}
+
+// lockPath is the location of the file that the rai process attached to
+// the job holds a lock on
+func (j *jobRecord) lockPath() (string, error) {
+	dir, err := jobStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, j.ID+".lock"), nil
+}
+
+// lock attaches the job to this process until the returned function is
+// called, so that other rai processes can tell that the recorded PID is
+// still the process that submitted the job
+func (j *jobRecord) lock() func() {
+	path, err := j.lockPath()
+	if err == nil {
+		var release func()
+		if release, err = lockJobFile(path); err == nil {
+			return release
+		}
+	}
+	log.WithError(err).Errorf("unable to lock job %v. It cannot be cancelled using `rai job cancel`.", j.ID)
+	return func() {}
+}
+
+// castPath is the location of the timed recording of the job output
+func (j *jobRecord) castPath() (string, error) {
+	dir, err := jobStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, j.ID+".cast"), nil
+}
+
+// submittedBuildFilePath is the location of the copy of the build file that
+// was submitted with the job
+func (j *jobRecord) submittedBuildFilePath() (string, error) {
+	dir, err := jobStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, j.ID+".rai_build"), nil
+}
+
+// saveBuildFile keeps a copy of the build file submitted with the job
+func (j *jobRecord) saveBuildFile(buf []byte) error {
+	path, err := j.submittedBuildFilePath()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0600)
+}
+
+// artifactsPath is the location of the build directory archive of an
+// imported job
+func (j *jobRecord) artifactsPath() (string, error) {
+	dir, err := jobStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, j.ID+".build.tar.gz"), nil
+}
+
+// createLog creates the file that the output of the job is captured in
+func (j *jobRecord) createLog() (*os.File, error) {
+	path, err := j.logPath()
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+}
+
+// buildURLPattern matches the location of the build directory archive
+// that the server announces at the end of a job
+var buildURLPattern = regexp.MustCompile(`https?://\S+\.tar\.gz`)
+
+// findBuildURL scans the output of the job for the location of the
+// uploaded build directory
+func (j *jobRecord) findBuildURL() string {
+	path, err := j.logPath()
+	if err != nil {
+		return ""
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	matches := buildURLPattern.FindAll(buf, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return string(matches[len(matches)-1])
+}
+
+// save persists the job record to the job store
+func (j *jobRecord) save() error {
+	path, err := j.path()
+	if err != nil {
+		return err
+	}
+	buf, err := yaml.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0600)
+}
+
+// setPhase records the transition of the job into the phase and persists it.
+// Failing to write the record never fails the submission itself.
+func (j *jobRecord) setPhase(phase jobPhase) {
+	now := time.Now()
+	switch phase {
+	case jobPhaseQueued:
+		j.QueuedAt = now
+	case jobPhaseRunning:
+		j.StartedAt = now
+	case jobPhaseFinished, jobPhaseFailed, jobPhaseCancelled:
+		j.FinishedAt = now
+	}
+	j.Phase = phase
+	j.save()
+	if hasRecordStreams() {
+		sendRecord(Record{Type: RecordStatus, JobID: j.ID, Phase: string(phase)})
+	}
+}
+
+// isDone returns true if the job has reached a terminal phase
+func (j *jobRecord) isDone() bool {
+	switch j.Phase {
+	case jobPhaseFinished, jobPhaseFailed, jobPhaseCancelled:
+		return true
+	}
+	return false
+}
+
+// displayPhase returns the phase of the job to show. The phase of a job
+// that did not finish and whose rai process is gone is not known.
+func (j *jobRecord) displayPhase() string {
+	if !j.isDone() && !j.isAttached() {
+		return "unknown (client detached)"
+	}
+	return string(j.Phase)
+}
+
+// isAttached returns true if the rai process that submitted the job is
+// still running and connected to the job. The process holds the lock of
+// the job, so a reused PID is not mistaken for it.
+func (j *jobRecord) isAttached() bool {
+	if j.isDone() || j.PID == 0 {
+		return false
+	}
+	path, err := j.lockPath()
+	if err != nil {
+		return false
+	}
+	return jobFileLocked(path)
+}
+
+// fail marks the job as failed, or cancelled, with the error that caused it
+func (j *jobRecord) fail(err error) error {
+	if err != nil {
+		j.Error = err.Error()
+	}
+	if ExitCode(err) == exitCodeCancelled {
+		j.setPhase(jobPhaseCancelled)
+		return err
+	}
+	j.setPhase(jobPhaseFailed)
+	return err
+}
+
+func readJobRecord(path string) (*jobRecord, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	job := &jobRecord{}
+	if err := yaml.Unmarshal(buf, job); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the job record %v", path)
+	}
+	if job.ID == "" {
+		job.ID = strings.TrimSuffix(filepath.Base(path), ".yml")
+	}
+	return job, nil
+}
+
+// listJobRecords returns all the job records within the job store
+// ordered from the most recent to the oldest
+func listJobRecords() ([]*jobRecord, error) {
+	dir, err := jobStoreDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	jobs := []*jobRecord{}
+	for _, path := range paths {
+		job, err := readJobRecord(path)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(ii, jj int) bool {
+		return jobs[ii].CreatedAt.After(jobs[jj].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// jobIDPattern matches the ids of the jobs. The ids are used in the paths
+// of the job store.
+var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// checkJobID fails if the id can not be the id of a job
+func checkJobID(id string) error {
+	if !jobIDPattern.MatchString(id) {
+		return errors.Errorf("invalid job id %v, expecting letters and digits", id)
+	}
+	return nil
+}
+
+// loadJobRecord reads the job record with the given id. A unique prefix of
+// the id is accepted as well.
+func loadJobRecord(id string) (*jobRecord, error) {
+	if err := checkJobID(id); err != nil {
+		return nil, err
+	}
+	dir, err := jobStoreDir()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, id+"*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, errors.Errorf("no job with id %v was found", id)
+	}
+	if len(matches) > 1 {
+		return nil, errors.Errorf("the job id %v is ambiguous, it matches %v jobs", id, len(matches))
+	}
+	return readJobRecord(matches[0])
+}
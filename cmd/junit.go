@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Unknwon/com"
+	"github.com/acarl005/stripansi"
+	"github.com/pkg/errors"
+	log "github.com/rai-project/logger"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// junitPath is where the JUnit report of the job is written. No report is
+// written when it is empty.
+var junitPath string
+
+// maxJUnitOutput is the number of lines of the output of a build command
+// kept in the JUnit report
+const maxJUnitOutput = 200
+
+// stepOutput is the output of a build command, as found in the log of the
+// job
+type stepOutput struct {
+	Command string
+	Lines   []string
+}
+
+// readStepOutputs splits the log of the job at the lines that the server
+// prints before running every build command. The lines before the first
+// command, and those read by the client rather than shown, are dropped.
+func readStepOutputs(r io.Reader) ([]stepOutput, error) {
+	steps := []stepOutput{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(stripansi.Strip(scanner.Text()), "\r")
+		if isHiddenLine([]byte(line), false) {
+			continue
+		}
+		if strings.HasPrefix(line, stepStartPrefix) {
+			steps = append(steps, stepOutput{Command: strings.TrimPrefix(line, stepStartPrefix)})
+			continue
+		}
+		if len(steps) != 0 {
+			steps[len(steps)-1].Lines = append(steps[len(steps)-1].Lines, line)
+		}
+	}
+	return steps, scanner.Err()
+}
+
+// jobStepOutputs reads the output of every build command from the log of
+// the job
+func jobStepOutputs(job *jobRecord) ([]stepOutput, error) {
+	path, err := job.logPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no output was captured for job %v", job.ID)
+	}
+	defer f.Close()
+	return readStepOutputs(f)
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// jobJUnitReport reports every build command of the job as a test case,
+// which fails if the command exited with a non-zero status. A job that
+// failed before any command ran is reported as a single failed test case.
+func jobJUnitReport(job *jobRecord, steps *stepRecorder, exitStatus *exitStatusRecorder, jobErr error) ([]byte, error) {
+	outputs, err := jobStepOutputs(job)
+	if err != nil {
+		return nil, err
+	}
+	end := job.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	recorded, durations := steps.durations(end)
+	suite := junitTestSuite{
+		Name:      "rai job " + job.ID,
+		Timestamp: job.CreatedAt.Format("2006-01-02T15:04:05"),
+	}
+	if !job.StartedAt.IsZero() {
+		suite.Time = junitSeconds(end.Sub(job.StartedAt))
+	}
+	for ii, output := range outputs {
+		testCase := junitTestCase{Name: output.Command, ClassName: "rai.build"}
+		if ii < len(durations) && recorded[ii].Command == output.Command {
+			testCase.Time = junitSeconds(durations[ii])
+		}
+		lines := output.Lines
+		if len(lines) > maxJUnitOutput {
+			lines = lines[len(lines)-maxJUnitOutput:]
+		}
+		testCase.SystemOut = strings.Join(lines, "\n")
+		if ii == len(outputs)-1 && jobErr != nil {
+			message := jobErr.Error()
+			if status := exitStatus.lastStatus(); status != 0 {
+				message = fmt.Sprintf("exited with status %v", status)
+			}
+			testCase.Failure = &junitFailure{Message: message, Text: testCase.SystemOut}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	if len(outputs) == 0 && jobErr != nil {
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			Name:      "submit",
+			ClassName: "rai.client",
+			Failure:   &junitFailure{Message: jobErr.Error()},
+		})
+		suite.Failures++
+	}
+	suite.Tests = len(suite.TestCases)
+	buf, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(buf, '\n')...), nil
+}
+
+// testResultsPath returns the test report that the build file declares
+// under test_results, relative to the build directory
+func testResultsPath(doc yaml.MapSlice) (string, error) {
+	value, ok := getMapSliceEntry(doc, "test_results")
+	if !ok {
+		return "", nil
+	}
+	p := path.Clean(cast.ToString(value))
+	if p == buildDirectory || strings.HasPrefix(p, buildDirectory+"/") {
+		p = strings.TrimPrefix(strings.TrimPrefix(p, buildDirectory), "/")
+	}
+	if p == "" || p == "." || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", errors.Errorf("the test results %v are outside the build directory. Only %v is kept after the job", value, buildDirectory)
+	}
+	return p, nil
+}
+
+// buildFileTestResults returns the test report declared by the build file
+func buildFileTestResults() (string, error) {
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return "", nil
+	}
+	_, doc, _, err := readBuildDocument(path)
+	if err != nil {
+		return "", err
+	}
+	return testResultsPath(doc)
+}
+
+// readTestResults reads the file, relative to the build directory, from
+// the build directory archive of the job
+func readTestResults(job *jobRecord, name string) ([]byte, error) {
+	body, err := openArtifacts(job)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the compressed archive")
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.Errorf("the test results %v do not exist in the build directory of job %v", name, job.ID)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the archive")
+		}
+		if strings.Trim(path.Clean("/"+hdr.Name), "/") == name && hdr.Typeflag != tar.TypeDir {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// writeJUnitReport writes the JUnit report of the job if --junit is set.
+// The test results declared by the build file are passed through, and
+// otherwise the build commands are reported as test cases.
+func writeJUnitReport(job *jobRecord, steps *stepRecorder, exitStatus *exitStatusRecorder, jobErr error) {
+	if junitPath == "" {
+		return
+	}
+	var report []byte
+	declared, err := buildFileTestResults()
+	if err == nil && declared != "" && job.Phase == jobPhaseFinished {
+		if report, err = readTestResults(job, declared); err != nil {
+			log.WithError(err).Error("unable to fetch the test results, reporting the build commands instead")
+		}
+	}
+	if report == nil {
+		if report, err = jobJUnitReport(job, steps, exitStatus, jobErr); err != nil {
+			log.WithError(err).Error("unable to create the JUnit report")
+			return
+		}
+	}
+	if err := ioutil.WriteFile(junitPath, report, 0644); err != nil {
+		log.WithError(err).Errorf("unable to write the JUnit report to %v", junitPath)
+	}
+}
+
+func init() {
+	// the test results are fetched by the client from the build directory
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		if _, err := testResultsPath(doc); err != nil {
+			return nil, false, err
+		}
+		if _, ok := getMapSliceEntry(doc, "test_results"); !ok {
+			return doc, false, nil
+		}
+		return deleteMapSliceEntry(doc, "test_results"), true, nil
+	})
+}
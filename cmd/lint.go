@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/buildfile"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var lintFix bool
+
+// lintIssue is a problem found within the build file
+type lintIssue struct {
+	Location string
+	Message  string
+	Fixable  bool
+}
+
+// lintRule checks the build specification for a class of mistakes
+type lintRule struct {
+	Name        string
+	Description string
+	Check       func(spec *buildSpecification) []lintIssue
+}
+
+var (
+	cdOnlyPattern       = regexp.MustCompile(`^\s*cd\s+[^;&|]+$`)
+	makeTargetPattern   = regexp.MustCompile(`^\s*make\s+(.*)$`)
+	localPathPattern    = regexp.MustCompile(`(^|\s)(/home/|/Users/|[A-Za-z]:\\)`)
+	makefileRulePattern = regexp.MustCompile(`(?m)^([A-Za-z0-9_.\-/ ]+):`)
+)
+
+const maxLintGPUCount = 1
+
+func commandLocation(ii int) string {
+	return fmt.Sprintf("commands.build[%d]", ii)
+}
+
+var lintRules = []lintRule{
+	{
+		Name:        "cd-without-effect",
+		Description: "every build command runs in its own shell, so a command that only changes the directory has no effect",
+		Check: func(spec *buildSpecification) []lintIssue {
+			issues := []lintIssue{}
+			for ii, command := range spec.Commands.Build {
+				if cdOnlyPattern.MatchString(command) {
+					issues = append(issues, lintIssue{
+						Location: commandLocation(ii),
+						Message:  fmt.Sprintf("`%v` does not affect the commands that follow it; use `%v && <command>` instead", strings.TrimSpace(command), strings.TrimSpace(command)),
+						Fixable:  ii+1 < len(spec.Commands.Build),
+					})
+				}
+			}
+			return issues
+		},
+	},
+	{
+		Name:        "missing-make-target",
+		Description: "make targets that are not defined in the project Makefile",
+		Check: func(spec *buildSpecification) []lintIssue {
+			issues := []lintIssue{}
+			buf, err := ioutil.ReadFile(filepath.Join(workingDir, "Makefile"))
+			if err != nil {
+				return issues
+			}
+			targets := map[string]bool{}
+			for _, match := range makefileRulePattern.FindAllStringSubmatch(string(buf), -1) {
+				for _, target := range strings.Fields(match[1]) {
+					targets[target] = true
+				}
+			}
+			for ii, command := range spec.Commands.Build {
+				match := makeTargetPattern.FindStringSubmatch(command)
+				if match == nil {
+					continue
+				}
+				for _, arg := range strings.Fields(match[1]) {
+					if strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+						continue
+					}
+					if !targets[arg] {
+						issues = append(issues, lintIssue{
+							Location: commandLocation(ii),
+							Message:  fmt.Sprintf("the make target %v is not defined in the project Makefile", arg),
+						})
+					}
+				}
+			}
+			return issues
+		},
+	},
+	{
+		Name:        "absolute-path",
+		Description: "paths on the local machine that do not exist on the server",
+		Check: func(spec *buildSpecification) []lintIssue {
+			issues := []lintIssue{}
+			for ii, command := range spec.Commands.Build {
+				if localPathPattern.MatchString(command) {
+					issues = append(issues, lintIssue{
+						Location: commandLocation(ii),
+						Message:  "the command refers to a path on your machine; the project is available under /src and the build directory is /build",
+					})
+				}
+			}
+			return issues
+		},
+	},
+	{
+		Name:        "large-resources",
+		Description: "resource requests that exceed what the queues provide",
+		Check: func(spec *buildSpecification) []lintIssue {
+			if spec.Resources.GPU.Count <= maxLintGPUCount {
+				return nil
+			}
+			return []lintIssue{{
+				Location: "resources.gpu.count",
+				Message:  fmt.Sprintf("%v GPUs were requested but jobs are given at most %v", spec.Resources.GPU.Count, maxLintGPUCount),
+			}}
+		},
+	},
+	{
+		Name:        "deprecated-image",
+		Description: "images without a pinned tag and deprecated build file versions",
+		Check: func(spec *buildSpecification) []lintIssue {
+			issues := []lintIssue{}
+			image := spec.RAI.Image
+			if image != "" {
+				name := image[strings.LastIndex(image, "/")+1:]
+				if !strings.Contains(name, ":") || strings.HasSuffix(name, ":latest") {
+					issues = append(issues, lintIssue{
+						Location: "rai.image",
+						Message:  fmt.Sprintf("the image %v is not pinned to a version tag and may change between jobs", image),
+					})
+				}
+			}
+			if spec.RAI.Version != "" && spec.RAI.Version < "0.2" {
+				issues = append(issues, lintIssue{
+					Location: "rai.version",
+					Message:  fmt.Sprintf("the build file version %v is deprecated; use version 0.2", spec.RAI.Version),
+				})
+			}
+			return issues
+		},
+	},
+}
+
+// lintConfig is read from the .railint.yml file in the project directory
+type lintConfig struct {
+	Rules map[string]bool `yaml:"rules"`
+}
+
+func readLintConfig() (*lintConfig, error) {
+	cfg := &lintConfig{Rules: map[string]bool{}}
+	path := filepath.Join(workingDir, ".railint.yml")
+	if !com.IsFile(path) {
+		return cfg, nil
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(buf, cfg); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %v", path)
+	}
+	for name := range cfg.Rules {
+		known := false
+		for _, rule := range lintRules {
+			known = known || rule.Name == name
+		}
+		if !known {
+			return nil, errors.Errorf("%v: unknown lint rule %v", path, name)
+		}
+	}
+	return cfg, nil
+}
+
+// isEnabled returns true unless the rule is disabled in the configuration
+func (c *lintConfig) isEnabled(rule string) bool {
+	enabled, ok := c.Rules[rule]
+	return !ok || enabled
+}
+
+// fixStandaloneCd merges the commands that only change the directory
+// into the command that follows them. The build file is edited as a
+// generic document so that keys unknown to the client are preserved.
+func fixStandaloneCd(path string) (int, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	doc := yaml.MapSlice{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return 0, err
+	}
+	fixed := 0
+	for ii, section := range doc {
+		if section.Key != "commands" {
+			continue
+		}
+		commands, ok := section.Value.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		for jj, entry := range commands {
+			if entry.Key != "build" {
+				continue
+			}
+			build, ok := entry.Value.([]interface{})
+			if !ok {
+				continue
+			}
+			merged := []interface{}{}
+			for kk := 0; kk < len(build); kk++ {
+				command, ok := build[kk].(string)
+				if ok && cdOnlyPattern.MatchString(command) && kk+1 < len(build) {
+					if next, ok := build[kk+1].(string); ok {
+						merged = append(merged, strings.TrimSpace(command)+" && "+next)
+						kk++
+						fixed++
+						continue
+					}
+				}
+				merged = append(merged, build[kk])
+			}
+			commands[jj].Value = merged
+		}
+		doc[ii].Value = commands
+	}
+	if fixed == 0 {
+		return 0, nil
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return 0, err
+	}
+	return fixed, ioutil.WriteFile(path, out, 0644)
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Checks the build file for common mistakes.",
+	Long: `Checks the build file for common mistakes. Rules can be disabled in a .railint.yml file ` +
+		`within the project directory, e.g.
+
+    rules:
+      absolute-path: false`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := readLintConfig()
+		if err != nil {
+			return err
+		}
+		path := buildFileLocation()
+
+		if lintFix && !buildfile.IsYAML(path) {
+			return errors.Errorf("fixes can only be applied to YAML build files, %v is not one", path)
+		}
+		if lintFix && cfg.isEnabled("cd-without-effect") {
+			fixed, err := fixStandaloneCd(path)
+			if err != nil {
+				return err
+			}
+			if fixed != 0 {
+				fmt.Printf("Fixed %v issues in %v (comments within the file are not preserved)\n", fixed, path)
+			}
+		}
+
+		spec, _, err := readBuildSpecification(path)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, rule := range lintRules {
+			if !cfg.isEnabled(rule.Name) {
+				continue
+			}
+			for _, issue := range rule.Check(spec) {
+				count++
+				themeColor(themeWarning).Printf("%v: ", issue.Location)
+				fmt.Printf("%v [%v]", issue.Message, rule.Name)
+				if issue.Fixable {
+					fmt.Print(" (fixable with --fix)")
+				}
+				fmt.Println()
+			}
+		}
+		if count != 0 {
+			return errors.Errorf("found %v issues in %v", count, path)
+		}
+		fmt.Printf("No issues found in %v\n", path)
+		return nil
+	},
+}
+
+func init() {
+	lintCmd.Flags().BoolVar(&lintFix, "fix", false, "Fix the issues that can be fixed mechanically.")
+	lintCmd.Flags().BoolVar(&strictBuildFile, "strict", false, "Treat unknown keys in the build file as errors.")
+	RootCmd.AddCommand(lintCmd)
+}
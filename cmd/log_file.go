@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/acarl005/stripansi"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// logFileJobID is replaced by the id of the job in the log file path
+const logFileJobID = "<jobid>"
+
+// logFilePath returns where the output of the job is written besides the
+// terminal, given using --log-file or client.log_file. It is empty if the
+// output is not written to a log file.
+func logFilePath(job *jobRecord) (string, error) {
+	path := viper.GetString("client.log_file")
+	if path == "" {
+		return "", nil
+	}
+	path = strings.Replace(path, logFileJobID, job.ID, -1)
+	return homedir.Expand(path)
+}
+
+// plainLogFile writes the output to a file with the ANSI escape codes
+// removed. Partial lines are held so that a code split across writes is
+// removed.
+type plainLogFile struct {
+	sync.Mutex
+	f       *os.File
+	pending []byte
+}
+
+// createLogFile opens the log file of the job, creating its directory
+func createLogFile(job *jobRecord) (*plainLogFile, error) {
+	path, err := logFilePath(job)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	return openPlainLogFile(path)
+}
+
+// openPlainLogFile creates the file at path, and its directory, for output
+// without the ANSI escape codes
+func openPlainLogFile(path string) (*plainLogFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrapf(err, "unable to create the directory of the log file %v", path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create the log file %v", path)
+	}
+	return &plainLogFile{f: f}, nil
+}
+
+func (l *plainLogFile) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	l.pending = append(l.pending, p...)
+	idx := bytes.LastIndexByte(l.pending, '\n')
+	if idx < 0 {
+		return len(p), nil
+	}
+	if _, err := io.WriteString(l.f, stripansi.Strip(string(l.pending[:idx+1]))); err != nil {
+		return 0, err
+	}
+	l.pending = append([]byte{}, l.pending[idx+1:]...)
+	return len(p), nil
+}
+
+// Close writes the partial line that is held and closes the file
+func (l *plainLogFile) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	if len(l.pending) != 0 {
+		io.WriteString(l.f, stripansi.Strip(string(l.pending)))
+		l.pending = nil
+	}
+	return l.f.Close()
+}
+
+// withLogFile adds the log file, if there is one, to the output
+func withLogFile(w io.Writer, logFile *plainLogFile) io.Writer {
+	if logFile == nil {
+		return w
+	}
+	return io.MultiWriter(w, logFile)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
+)
+
+// profileFields are the entries of the profile in the order they are
+// prompted for
+var profileFields = []struct {
+	key    string
+	prompt string
+	secret bool
+}{
+	{"firstname", "First name", false},
+	{"lastname", "Last name", false},
+	{"username", "Username", false},
+	{"email", "Email", false},
+	{"access_key", "Access key", false},
+	{"secret_key", "Secret key", true},
+}
+
+func prompt(reader *bufio.Reader, label string, secret bool) (string, error) {
+	fmt.Printf("%v: ", label)
+	if secret && terminal.IsTerminal(int(os.Stdin.Fd())) {
+		buf, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		return strings.TrimSpace(string(buf)), err
+	}
+	line, err := reader.ReadString('\n')
+	return strings.TrimSpace(line), err
+}
+
+var loginCmd = &cobra.Command{
+	Use:          "login",
+	Short:        "Creates the user profile from the credentials given by the course staff.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := profilePath()
+		if err != nil {
+			return err
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+		// the entries that are not prompted for, such as the team, are kept
+		profile, err := readProfileEntries()
+		if err != nil {
+			profile = yaml.MapSlice{}
+		}
+		for _, field := range profileFields {
+			value, err := prompt(reader, field.prompt, field.secret)
+			if err != nil {
+				return err
+			}
+			if value == "" {
+				return errors.Errorf("the %v must not be empty", strings.ToLower(field.prompt))
+			}
+			profile = setMapSliceEntry(profile, field.key, value)
+		}
+
+		// keep the current profile around until the new one is verified
+		var previous []byte
+		if com.IsFile(path) {
+			if previous, err = ioutil.ReadFile(path); err != nil {
+				return err
+			}
+		}
+		if err := writeProfileEntries(profile); err != nil {
+			return err
+		}
+		if _, err := loadProfile(); err != nil {
+			if previous != nil {
+				ioutil.WriteFile(path, previous, 0600)
+			} else {
+				os.Remove(path)
+			}
+			return errors.Wrap(err, "the credentials were rejected")
+		}
+
+		fmt.Printf("Logged in. The profile was written to %v\n", path)
+		return nil
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:          "logout",
+	Short:        "Removes the user profile and the credentials stored in it.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := profilePath()
+		if err != nil {
+			return err
+		}
+		if !com.IsFile(path) {
+			fmt.Println("Not logged in.")
+			return nil
+		}
+		// overwrite the credentials before removing the file
+		if info, err := os.Stat(path); err == nil {
+			ioutil.WriteFile(path, make([]byte, info.Size()), 0600)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		fmt.Printf("Logged out. The profile %v was removed.\n", path)
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(loginCmd)
+	RootCmd.AddCommand(logoutCmd)
+}
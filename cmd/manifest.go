@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// uploadManifest maps the path of every uploaded file to the digest of its
+// mode and content
+type uploadManifest map[string]string
+
+// computeUploadManifest hashes the files that are uploaded from the
+// directory
+func computeUploadManifest(dir string) (uploadManifest, error) {
+	files, _, err := collectUploadFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	manifest := uploadManifest{}
+	for _, file := range files {
+		f, err := os.Open(file.Source)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "%o\n", file.Mode.Perm())
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		manifest[file.Path] = hex.EncodeToString(h.Sum(nil))
+	}
+	return manifest, nil
+}
+
+func (m uploadManifest) paths() []string {
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// manifestChanges lists the files that differ between two manifests
+type manifestChanges struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+func (m uploadManifest) changesSince(prev uploadManifest) manifestChanges {
+	changes := manifestChanges{}
+	for _, path := range m.paths() {
+		digest, ok := prev[path]
+		if !ok {
+			changes.Added = append(changes.Added, path)
+		} else if digest != m[path] {
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	for _, path := range prev.paths() {
+		if _, ok := m[path]; !ok {
+			changes.Removed = append(changes.Removed, path)
+		}
+	}
+	return changes
+}
+
+func (c manifestChanges) String() string {
+	total := len(c.Added) + len(c.Modified) + len(c.Removed)
+	if total == 0 {
+		return "No files changed"
+	}
+	return fmt.Sprintf("%v files changed (%v modified, %v added, %v removed)",
+		total, len(c.Modified), len(c.Added), len(c.Removed))
+}
+
+// manifestPath is the location of the manifest of the files uploaded with
+// the job
+func (j *jobRecord) manifestPath() (string, error) {
+	dir, err := jobStoreDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, j.ID+".manifest.json"), nil
+}
+
+func (j *jobRecord) saveManifest(manifest uploadManifest) error {
+	path, err := j.manifestPath()
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0600)
+}
+
+func (j *jobRecord) readManifest() (uploadManifest, error) {
+	path, err := j.manifestPath()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := uploadManifest{}
+	if err := json.Unmarshal(buf, &manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// previousManifest returns the most recent other job submitted from the
+// same directory that has a manifest
+func previousManifest(job *jobRecord) (*jobRecord, uploadManifest) {
+	jobs, err := listJobRecords()
+	if err != nil {
+		return nil, nil
+	}
+	for _, other := range jobs {
+		if other.ID == job.ID || other.Directory != job.Directory {
+			continue
+		}
+		if manifest, err := other.readManifest(); err == nil {
+			return other, manifest
+		}
+	}
+	return nil, nil
+}
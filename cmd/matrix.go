@@ -0,0 +1,482 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Unknwon/com"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// matrixFlags are the --matrix NAME=v1,v2 axes
+	matrixFlags []string
+	// matrixConcurrency bounds the number of jobs of a matrix, or of the
+	// queues given using --queues, that are submitted at the same time
+	matrixConcurrency int
+	// fanoutQueues are the queues that the job is submitted to at the same
+	// time
+	fanoutQueues []string
+)
+
+// matrixAxis is a parameter of the build file and the values it takes
+type matrixAxis struct {
+	Name   string
+	Values []string
+}
+
+// matrixAxes returns the axes given using --matrix followed by the ones in
+// the matrix section of the build file. Parameters that are set using
+// --param are not expanded, which is how the jobs of a matrix are
+// submitted.
+func matrixAxes() ([]matrixAxis, error) {
+	params, err := parseBuildParams(buildParams)
+	if err != nil {
+		return nil, err
+	}
+	axes := []matrixAxis{}
+	seen := map[string]bool{}
+	add := func(name string, values []string) error {
+		if _, ok := params[name]; ok || seen[name] {
+			return nil
+		}
+		if len(values) == 0 {
+			return errors.Errorf("the matrix parameter %v has no values", name)
+		}
+		seen[name] = true
+		axes = append(axes, matrixAxis{Name: name, Values: values})
+		return nil
+	}
+	for _, flag := range matrixFlags {
+		idx := strings.Index(flag, "=")
+		if idx <= 0 {
+			return nil, errors.Errorf("invalid matrix %v, expecting NAME=value,value", flag)
+		}
+		if err := add(flag[:idx], strings.Split(flag[idx+1:], ",")); err != nil {
+			return nil, err
+		}
+	}
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return axes, nil
+	}
+	_, doc, _, err := readBuildDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	matrix, _ := getMapSliceEntry(doc, "matrix")
+	section, _ := matrix.(yaml.MapSlice)
+	for _, item := range section {
+		if err := add(cast.ToString(item.Key), cast.ToStringSlice(item.Value)); err != nil {
+			return nil, err
+		}
+	}
+	return axes, nil
+}
+
+// matrixCombinations returns every combination of the values of the axes
+// as name=value parameters
+func matrixCombinations(axes []matrixAxis) [][]string {
+	if len(axes) == 0 {
+		return nil
+	}
+	combinations := [][]string{{}}
+	for _, axis := range axes {
+		next := [][]string{}
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				params := append(append([]string{}, combination...), axis.Name+"="+value)
+				next = append(next, params)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// flagTakesValue returns true if the flag is given a value
+func flagTakesValue(flag *pflag.Flag) bool {
+	return flag != nil && flag.NoOptDefVal == ""
+}
+
+// withoutMatrixFlags removes the matrix flags, and the queue flags when
+// the jobs are submitted to several queues, from the command line
+// arguments. The arguments are read as the flags of the command parse
+// them, so that the values of the other flags and the positional
+// arguments are kept as they are.
+func withoutMatrixFlags(flags *pflag.FlagSet, args []string) []string {
+	removed := map[string]bool{"matrix": true, "matrix-concurrency": true, "queues": true}
+	if len(fanoutQueues) != 0 {
+		removed["queue"] = true
+	}
+	out := []string{}
+	for ii := 0; ii < len(args); ii++ {
+		arg := args[ii]
+		if arg == "--" {
+			return append(out, args[ii:]...)
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			out = append(out, arg)
+			continue
+		}
+		// the flag that takes the value, if any, and whether the value is
+		// part of the argument. A group of shorthands ends with the first
+		// one that takes a value, the rest of the argument is its value.
+		var flag *pflag.Flag
+		kept, attached := "", false
+		if strings.HasPrefix(arg, "--") {
+			name := arg[2:]
+			if idx := strings.Index(name, "="); idx >= 0 {
+				name, attached = name[:idx], true
+			}
+			flag = flags.Lookup(name)
+		} else {
+			for jj := 1; jj < len(arg); jj++ {
+				shorthand := flags.ShorthandLookup(arg[jj : jj+1])
+				if flagTakesValue(shorthand) {
+					flag, kept = shorthand, arg[:jj]
+					attached = jj+1 < len(arg)
+					break
+				}
+			}
+		}
+		count := 1
+		if flagTakesValue(flag) && !attached && ii+1 < len(args) {
+			count = 2
+		}
+		switch {
+		case flag == nil || !removed[flag.Name]:
+			out = append(out, args[ii:ii+count]...)
+		case kept != "" && kept != "-":
+			// the shorthands before the removed one are kept
+			out = append(out, kept)
+		}
+		ii += count - 1
+	}
+	return out
+}
+
+// prefixWriter prefixes every line written to it
+type prefixWriter struct {
+	sync.Mutex
+	prefix string
+	w      io.Writer
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		idx := bytes.IndexByte(p.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(p.w, "%v%s", p.prefix, p.buf[:idx+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[idx+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes the last line if it is not terminated
+func (p *prefixWriter) Flush() {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.buf) != 0 {
+		fmt.Fprintf(p.w, "%v%s\n", p.prefix, p.buf)
+		p.buf = nil
+	}
+}
+
+// matrixQuota hands out the submissions of a matrix, waiting for the
+// quota window to free a submission when none remain
+type matrixQuota struct {
+	sync.Mutex
+	queue    string
+	launched int
+}
+
+func (q *matrixQuota) wait() {
+	q.Lock()
+	defer q.Unlock()
+	for {
+		usages, err := currentQuotaUsages()
+		if err != nil {
+			return
+		}
+		var blocked *quotaUsage
+		for ii, usage := range usages {
+			if usage.Name != "" && usage.Name != q.queue {
+				continue
+			}
+			// the jobs that were launched may not have been queued yet
+			if usage.remaining()-q.launched <= 0 && !usage.Reset.IsZero() {
+				blocked = &usages[ii]
+			}
+		}
+		if blocked == nil {
+			q.launched++
+			return
+		}
+		fmt.Printf("No submissions remain within the quota, waiting until %v\n", blocked.Reset.Format("15:04:05"))
+		time.Sleep(time.Until(blocked.Reset) + time.Second)
+		q.launched = 0
+	}
+}
+
+// concurrentJob is a job submitted along with others from this process:
+// the job of a combination of the matrix on a queue
+type concurrentJob struct {
+	// Params are the name=value parameters of the combination
+	Params []string
+	// Queue is the queue that the job is submitted to with --queues
+	Queue string
+}
+
+// label identifies the job in the output and the summary
+func (j concurrentJob) label() string {
+	parts := append([]string{}, j.Params...)
+	if j.Queue != "" {
+		parts = append(parts, j.Queue)
+	}
+	return strings.Join(parts, " ")
+}
+
+// concurrentJobs returns a job for every combination of the matrix on
+// every queue given using --queues
+func concurrentJobs(combinations [][]string) []concurrentJob {
+	if len(combinations) == 0 {
+		combinations = [][]string{nil}
+	}
+	queues := fanoutQueues
+	if len(queues) == 0 {
+		queues = []string{""}
+	}
+	jobs := []concurrentJob{}
+	for _, combination := range combinations {
+		for _, queue := range queues {
+			jobs = append(jobs, concurrentJob{Params: combination, Queue: queue})
+		}
+	}
+	return jobs
+}
+
+// submitConcurrently submits the jobs, concurrency at a time, and prints a
+// summary of the jobs once they finished. Every job is submitted by
+// running the client again, which keeps the state of the jobs and their
+// output apart, while the configuration and the profile were checked once
+// by this process. The progress of the jobs is printed as they change
+// phase.
+func submitConcurrently(flags *pflag.FlagSet, jobs []concurrentJob) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	concurrency := matrixConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	defaultQueue := jobQueueName
+	if defaultQueue == "" {
+		defaultQueue = defaultQueueName()
+	}
+	fmt.Printf("Submitting %v jobs, %v at a time\n", len(jobs), concurrency)
+
+	start := time.Now()
+	args := withoutMatrixFlags(flags, os.Args[1:])
+	quotas := map[string]*matrixQuota{}
+	failures := make([]error, len(jobs))
+	slots := make(chan struct{}, concurrency)
+	stopProgress := watchConcurrentJobs(start, len(jobs))
+	var wg sync.WaitGroup
+	for ii, job := range jobs {
+		queue := job.Queue
+		if queue == "" {
+			queue = defaultQueue
+		}
+		if quotas[queue] == nil {
+			quotas[queue] = &matrixQuota{queue: queue}
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		quotas[queue].wait()
+		go func(ii int, job concurrentJob) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			cmdArgs := append([]string{}, args...)
+			for _, param := range job.Params {
+				cmdArgs = append(cmdArgs, "--param", param)
+			}
+			if job.Queue != "" {
+				cmdArgs = append(cmdArgs, "--queue", job.Queue)
+			}
+			prefix := "[" + job.label() + "] "
+			stdout := &prefixWriter{prefix: prefix, w: os.Stdout}
+			stderr := &prefixWriter{prefix: prefix, w: os.Stderr}
+			// the children read from the null device rather than the
+			// terminal, so Ctrl-C stops their jobs without each of them
+			// asking for confirmation on the same input
+			cmdArgs = append(cmdArgs, "--cancel-on-interrupt")
+			cmd := exec.Command(exe, cmdArgs...)
+			cmd.Stdin = nil
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+			failures[ii] = cmd.Run()
+			stdout.Flush()
+			stderr.Flush()
+		}(ii, job)
+	}
+	wg.Wait()
+	stopProgress()
+
+	printMatrixSummary(start, jobs, failures)
+	for _, err := range failures {
+		if err != nil {
+			return errors.New("some of the jobs failed")
+		}
+	}
+	return nil
+}
+
+// concurrentJobRecords returns the jobs submitted from the directory since
+// the concurrent jobs started, most recent first
+func concurrentJobRecords(start time.Time) []*jobRecord {
+	jobs, err := listJobRecords()
+	if err != nil {
+		return nil
+	}
+	records := []*jobRecord{}
+	for _, job := range jobs {
+		if job.CreatedAt.Before(start) {
+			break
+		}
+		if job.Directory == workingDir {
+			records = append(records, job)
+		}
+	}
+	return records
+}
+
+// concurrentProgress counts the jobs in every phase, such as
+// "2 queued, 1 running, 1 finished"
+func concurrentProgress(jobs []*jobRecord, total int) string {
+	counts := map[jobPhase]int{}
+	for _, job := range jobs {
+		counts[job.Phase]++
+	}
+	parts := []string{}
+	if pending := total - len(jobs); pending > 0 {
+		parts = append(parts, fmt.Sprintf("%v pending", pending))
+	}
+	for _, phase := range []jobPhase{jobPhaseUploading, jobPhaseQueued, jobPhaseRunning, jobPhaseFinished, jobPhaseFailed, jobPhaseCancelled} {
+		if counts[phase] != 0 {
+			parts = append(parts, fmt.Sprintf("%v %v", counts[phase], phase))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// watchConcurrentJobs prints the number of jobs in every phase whenever it
+// changes, until the returned function is called
+func watchConcurrentJobs(start time.Time, total int) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		last := ""
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			progress := concurrentProgress(concurrentJobRecords(start), total)
+			if progress != last {
+				fmt.Printf("Jobs: %v\n", progress)
+				last = progress
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// matrixJob returns the most recent job submitted from the directory with
+// the parameters, on the queue if it is given, since the jobs started
+func matrixJob(jobs []*jobRecord, start time.Time, params []string, queue string) *jobRecord {
+	key := strings.Join(params, " ")
+	for _, job := range jobs {
+		if job.CreatedAt.Before(start) {
+			break
+		}
+		if job.Directory == workingDir && strings.Join(job.Params, " ") == key && (queue == "" || job.Queue == queue) {
+			return job
+		}
+	}
+	return nil
+}
+
+// printMatrixSummary prints the record and the result of every job
+func printMatrixSummary(start time.Time, concurrent []concurrentJob, failures []error) {
+	jobs, err := listJobRecords()
+	if err != nil {
+		jobs = nil
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	header := []string{"Parameters", "Job", "Status", "Run Time"}
+	if len(fanoutQueues) != 0 {
+		header = []string{"Parameters", "Queue", "Job", "Status", "Run Time"}
+	}
+	table.SetHeader(header)
+	for ii, concurrentJob := range concurrent {
+		// the jobs also carry the parameters given using --param
+		params := append(append([]string{}, buildParams...), concurrentJob.Params...)
+		sort.Strings(params)
+		row := []string{strings.Join(concurrentJob.Params, " "), "", "failed", ""}
+		if job := matrixJob(jobs, start, params, concurrentJob.Queue); job != nil {
+			row[1] = job.ID
+			row[2] = string(job.Phase)
+			if !job.StartedAt.IsZero() && !job.FinishedAt.IsZero() {
+				row[3] = job.FinishedAt.Sub(job.StartedAt).Round(time.Second).String()
+			}
+		} else if failures[ii] == nil {
+			row[2] = "unknown"
+		}
+		if len(fanoutQueues) != 0 {
+			row = append([]string{row[0], concurrentJob.Queue}, row[1:]...)
+		}
+		table.Append(row)
+	}
+	fmt.Println()
+	table.Render()
+}
+
+func init() {
+	// the matrix is expanded by the client, so it is not part of the build
+	// file that is submitted
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		if _, ok := getMapSliceEntry(doc, "matrix"); !ok {
+			return doc, false, nil
+		}
+		return deleteMapSliceEntry(doc, "matrix"), true, nil
+	})
+}
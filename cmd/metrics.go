@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/rai-project/logger"
+)
+
+// metricsAddr is the address that the metrics of the client are served on
+// in the Prometheus text format by the long-lived commands. They are not
+// served when it is empty.
+var metricsAddr string
+
+// the buckets of the histograms, in seconds
+var (
+	queueWaitBuckets   = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+	jobDurationBuckets = []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200}
+)
+
+// histogram counts observations in cumulative buckets
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(value float64) {
+	for ii, bound := range h.buckets {
+		if value <= bound {
+			h.counts[ii]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v histogram\n", name, help, name)
+	for ii, bound := range h.buckets {
+		fmt.Fprintf(w, "%v_bucket{le=\"%v\"} %v\n", name, bound, h.counts[ii])
+	}
+	fmt.Fprintf(w, "%v_bucket{le=\"+Inf\"} %v\n", name, h.count)
+	fmt.Fprintf(w, "%v_sum %v\n%v_count %v\n", name, h.sum, name, h.count)
+}
+
+// clientMetrics are the counters and histograms of the jobs submitted by
+// this process
+type clientMetrics struct {
+	sync.Mutex
+	submissions uint64
+	uploadBytes uint64
+	inProgress  int
+	// failures are counted by the code of the error
+	failures    map[string]uint64
+	queueWait   *histogram
+	jobDuration *histogram
+}
+
+var metrics = &clientMetrics{
+	failures:    map[string]uint64{},
+	queueWait:   newHistogram(queueWaitBuckets),
+	jobDuration: newHistogram(jobDurationBuckets),
+}
+
+// jobSubmitted counts a submission
+func (m *clientMetrics) jobSubmitted() {
+	m.Lock()
+	defer m.Unlock()
+	m.submissions++
+	m.inProgress++
+}
+
+// uploaded counts the bytes of an upload that succeeded
+func (m *clientMetrics) uploaded(size int64) {
+	m.Lock()
+	defer m.Unlock()
+	m.uploadBytes += uint64(size)
+}
+
+// jobEnded records how long the job waited in the queue and ran, and the
+// reason it failed
+func (m *clientMetrics) jobEnded(job *jobRecord, err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.inProgress--
+	if details := errorDetails(err); details != nil {
+		m.failures[details.Code]++
+	}
+	if !job.QueuedAt.IsZero() && !job.StartedAt.IsZero() {
+		m.queueWait.observe(job.StartedAt.Sub(job.QueuedAt).Seconds())
+	}
+	if !job.StartedAt.IsZero() && !job.FinishedAt.IsZero() {
+		m.jobDuration.observe(job.FinishedAt.Sub(job.StartedAt).Seconds())
+	}
+}
+
+// write writes the metrics in the Prometheus text format
+func (m *clientMetrics) write(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+	fmt.Fprintf(w, "# HELP rai_submissions_total Jobs submitted.\n# TYPE rai_submissions_total counter\nrai_submissions_total %v\n", m.submissions)
+	fmt.Fprintf(w, "# HELP rai_upload_bytes_total Bytes of the directories uploaded.\n# TYPE rai_upload_bytes_total counter\nrai_upload_bytes_total %v\n", m.uploadBytes)
+	fmt.Fprintf(w, "# HELP rai_jobs_in_progress Jobs that were submitted and have not ended.\n# TYPE rai_jobs_in_progress gauge\nrai_jobs_in_progress %v\n", m.inProgress)
+	fmt.Fprintf(w, "# HELP rai_failures_total Jobs that failed, by reason.\n# TYPE rai_failures_total counter\n")
+	reasons := []string{}
+	for reason := range m.failures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "rai_failures_total{reason=%q} %v\n", reason, m.failures[reason])
+	}
+	m.queueWait.write(w, "rai_queue_wait_seconds", "Time the jobs waited in the queue.")
+	m.jobDuration.write(w, "rai_job_duration_seconds", "Time the jobs ran on the workers.")
+}
+
+// serveMetrics serves the metrics on /metrics at metricsAddr in the
+// background
+func serveMetrics() error {
+	if metricsAddr == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", metricsAddr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.write(w)
+	})
+	server := &http.Server{Handler: mux, ReadTimeout: 10 * time.Second, WriteTimeout: 10 * time.Second}
+	go func() {
+		log.WithError(server.Serve(listener)).Error("the metrics server stopped")
+	}()
+	fmt.Printf("Serving metrics on http://%v/metrics\n", listener.Addr())
+	return nil
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/buildfile"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// currentBuildFileVersion is the version that build files are migrated to
+const currentBuildFileVersion = "0.2"
+
+// legacyBuildFileNames are the names that build files used to have
+var legacyBuildFileNames = []string{"rai-build.yml", ".rai-build.yml"}
+
+var migrateDryRun bool
+
+// buildFileMigration records what migrating a build file changed and what
+// needs the attention of the user
+type buildFileMigration struct {
+	Changes []string
+	Notes   []string
+}
+
+func (m *buildFileMigration) change(format string, args ...interface{}) {
+	m.Changes = append(m.Changes, fmt.Sprintf(format, args...))
+}
+
+func (m *buildFileMigration) note(format string, args ...interface{}) {
+	m.Notes = append(m.Notes, fmt.Sprintf(format, args...))
+}
+
+// migrateBuildDocument rewrites the keys of older build files into the
+// ones of the current version
+func migrateBuildDocument(doc yaml.MapSlice) (yaml.MapSlice, *buildFileMigration) {
+	m := &buildFileMigration{}
+	value, hasRAI := getMapSliceEntry(doc, "rai")
+	rai, _ := value.(yaml.MapSlice)
+
+	// version 0.1 files had the image and version at the top level
+	for _, key := range []string{"version", "image"} {
+		value, ok := getMapSliceEntry(doc, key)
+		if !ok {
+			continue
+		}
+		doc = deleteMapSliceEntry(doc, key)
+		if _, exists := getMapSliceEntry(rai, key); exists {
+			m.note("the top level %v was dropped since rai.%v is set", key, key)
+			continue
+		}
+		rai = setMapSliceEntry(rai, key, value)
+		m.change("moved %v to rai.%v", key, key)
+	}
+	version, _ := getMapSliceEntry(rai, "version")
+	switch v := cast.ToString(version); {
+	case v == "":
+		m.change("set rai.version to %v", currentBuildFileVersion)
+	case v == "0.1":
+		m.change("upgraded rai.version from 0.1 to %v", currentBuildFileVersion)
+	case v != currentBuildFileVersion:
+		m.note("rai.version %v is not known to this client, it was set to %v", v, currentBuildFileVersion)
+	}
+	// the version is a string so that 0.2 is not read as a number
+	rai = setMapSliceEntry(rai, "version", currentBuildFileVersion)
+	image, hasImage := getMapSliceEntry(rai, "image")
+	if !hasImage {
+		m.note("rai.image is not set")
+	} else if name := cast.ToString(image); !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		m.note("the image %v has no tag, so the latest image is used and may change between jobs", name)
+	}
+	if hasRAI {
+		doc = setMapSliceEntry(doc, "rai", rai)
+	} else {
+		doc = append(yaml.MapSlice{{Key: "rai", Value: rai}}, doc...)
+	}
+
+	// version 0.1 files listed the build commands directly under commands
+	commands, _ := getMapSliceEntry(doc, "commands")
+	if list, ok := commands.([]interface{}); ok {
+		commands = yaml.MapSlice{{Key: "build", Value: list}}
+		m.change("moved the commands list to commands.build")
+	}
+	section, _ := commands.(yaml.MapSlice)
+	if value, ok := getMapSliceEntry(section, "build_image"); ok {
+		buildImage, _ := value.(yaml.MapSlice)
+		if nocache, ok := getMapSliceEntry(buildImage, "nocache"); ok {
+			buildImage = setMapSliceEntry(deleteMapSliceEntry(buildImage, "nocache"), "no_cache", nocache)
+			m.change("renamed commands.build_image.nocache to no_cache")
+		}
+		if push, ok := getMapSliceEntry(buildImage, "push"); ok {
+			if _, isSection := push.(yaml.MapSlice); !isSection {
+				buildImage = setMapSliceEntry(buildImage, "push", yaml.MapSlice{{Key: "push", Value: push}})
+				m.change("moved commands.build_image.push to commands.build_image.push.push")
+			}
+		}
+		if _, ok := getMapSliceEntry(buildImage, "image_name"); !ok {
+			m.note("commands.build_image has no image_name, check its indentation")
+		}
+		section = setMapSliceEntry(section, "build_image", buildImage)
+	}
+	if commands != nil {
+		doc = setMapSliceEntry(doc, "commands", section)
+	}
+
+	value, _ = getMapSliceEntry(doc, "resources")
+	resources, _ := value.(yaml.MapSlice)
+	if network, ok := getMapSliceEntry(resources, "network"); ok && cast.ToBool(network) {
+		m.note("resources.network is true, but networking is disabled on the workers of most queues")
+	}
+
+	// what the schema does not know about needs to be looked at by hand
+	if schema, err := buildFileSchema(currentBuildFileVersion); err == nil {
+		for _, violation := range validateSchema(schema, schema, doc, nil) {
+			m.note("%v", violation)
+		}
+	}
+	return doc, m
+}
+
+// legacyBuildFile returns the build file of the project under one of the
+// names that build files used to have
+func legacyBuildFile() string {
+	for _, name := range legacyBuildFileNames {
+		path := filepath.Join(workingDir, name)
+		if com.IsFile(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// renderMigratedBuildFile marshals the document, preceded by a comment
+// listing the notes
+func renderMigratedBuildFile(doc yaml.MapSlice, m *buildFileMigration) ([]byte, error) {
+	body, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if len(m.Notes) != 0 {
+		fmt.Fprintln(buf, "# rai migrate-buildfile: the following need manual attention")
+		for _, note := range m.Notes {
+			fmt.Fprintf(buf, "#   - %v\n", note)
+		}
+	}
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+var migrateBuildFileCmd = &cobra.Command{
+	Use:   "migrate-buildfile",
+	Short: "Upgrades the build file to the current version.",
+	Long: `Rewrites an older build file, such as a version 0.1 or rai-build.yml file, into the ` +
+		`current format in place. The original is kept with a .bak suffix. Anything that could not ` +
+		`be migrated is listed in a comment at the top of the file. Comments of the original file are not kept.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := buildFileLocation()
+		source := target
+		if !com.IsFile(source) {
+			if source = legacyBuildFile(); source == "" {
+				return errors.Errorf("%v does not exist", target)
+			}
+		}
+		if !buildfile.IsYAML(source) {
+			return errors.Errorf("only YAML build files can be migrated, %v is not one", source)
+		}
+		buf, err := ioutil.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		var doc yaml.MapSlice
+		if err := yaml.Unmarshal(buf, &doc); err != nil {
+			return errors.Wrapf(err, "unable to parse %v", source)
+		}
+		doc, migration := migrateBuildDocument(doc)
+		if source != target {
+			migration.change("renamed %v to %v", filepath.Base(source), filepath.Base(target))
+		}
+		for _, change := range migration.Changes {
+			fmt.Printf("  %v\n", change)
+		}
+		for _, note := range migration.Notes {
+			fmt.Printf("  needs attention: %v\n", note)
+		}
+		if len(migration.Changes) == 0 {
+			fmt.Printf("%v is already up to date\n", source)
+			return nil
+		}
+		if migrateDryRun {
+			return nil
+		}
+		out, err := renderMigratedBuildFile(doc, migration)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(source+".bak", buf, 0644); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, out, 0644); err != nil {
+			return err
+		}
+		if source != target {
+			if err := os.Remove(source); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Migrated %v, the original was kept in %v.bak\n", target, source)
+		return nil
+	},
+}
+
+func init() {
+	migrateBuildFileCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show the changes without writing the build file.")
+	RootCmd.AddCommand(migrateBuildFileCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/rai-project/logger"
+	"github.com/spf13/viper"
+)
+
+// notifyFlag shows a desktop notification once the job ends
+var notifyFlag bool
+
+// notificationMessage describes the outcome and the duration of the job
+func notificationMessage(job *jobRecord, jobErr error) (string, string) {
+	title := fmt.Sprintf("rai job %v %v", job.ID, job.Phase)
+	end := job.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	message := fmt.Sprintf("Took %v", end.Sub(job.CreatedAt).Round(time.Second))
+	if jobErr != nil {
+		message += ": " + jobErr.Error()
+	}
+	return title, message
+}
+
+// notificationCommand returns the command that shows a notification on
+// this platform
+func notificationCommand(title, message string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		quote := func(s string) string {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+		}
+		return exec.Command("osascript", "-e", "display notification "+quote(message)+" with title "+quote(title))
+	case "windows":
+		quote := func(s string) string {
+			return "'" + strings.Replace(s, "'", "''", -1) + "'"
+		}
+		script := "[reflection.assembly]::loadwithpartialname('System.Windows.Forms') | Out-Null;" +
+			"$n = New-Object System.Windows.Forms.NotifyIcon;" +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information;" +
+			"$n.Visible = $true;" +
+			"$n.ShowBalloonTip(10000, " + quote(title) + ", " + quote(message) + ", 'Info');" +
+			"Start-Sleep -Seconds 10; $n.Dispose()"
+		return exec.Command("powershell", "-NoProfile", "-Command", script)
+	default:
+		return exec.Command("notify-send", "--app-name=rai", title, message)
+	}
+}
+
+// notifyCompletion shows a desktop notification of the outcome of the job
+// if --notify is set. The terminal bell is rung when no notification can
+// be shown.
+func notifyCompletion(job *jobRecord, jobErr error) {
+	if !notifyFlag {
+		return
+	}
+	title, message := notificationMessage(job, jobErr)
+	if err := notificationCommand(title, message).Start(); err != nil {
+		log.WithError(err).Debug("unable to show a desktop notification")
+		fmt.Print("\a")
+	}
+}
+
+// webhookClient posts the outcome of the job to the webhook
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookPayload is the body posted to the webhook given using
+// --notify-url or client.notify_url. Slack incoming webhooks are sent a
+// message instead.
+func webhookPayload(endpoint string, result *jobResult, job *jobRecord, jobErr error) ([]byte, error) {
+	if u, err := url.Parse(endpoint); err == nil && u.Host == "hooks.slack.com" {
+		title, message := notificationMessage(job, jobErr)
+		return json.Marshal(map[string]string{"text": fmt.Sprintf("*%v*\n%v", title, message)})
+	}
+	return json.Marshal(map[string]interface{}{
+		"event": "job_finished",
+		"job":   result,
+	})
+}
+
+// notifyWebhook posts the outcome of the job to the webhook, if one is set
+func notifyWebhook(job *jobRecord, steps *stepRecorder, exitStatus *exitStatusRecorder, jobErr error) {
+	endpoint := viper.GetString("client.notify_url")
+	if endpoint == "" {
+		return
+	}
+	body, err := webhookPayload(endpoint, newJobResult(job, steps, exitStatus, jobErr), job, jobErr)
+	if err != nil {
+		log.WithError(err).Error("unable to encode the webhook notification")
+		return
+	}
+	resp, err := webhookClient.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			err = errors.Errorf("the webhook responded with %v", resp.Status)
+		}
+	}
+	if err != nil {
+		log.WithError(err).Errorf("unable to notify %v", endpoint)
+	}
+}
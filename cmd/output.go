@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/rai-project/client"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// jobOutput holds the writers that the output of a job is streamed to
+type jobOutput struct {
+	stdout io.Writer
+	stderr io.Writer
+	// terminal are the writers of the streams to the terminal
+	terminal []io.Writer
+	log      *os.File
+	cast     *castRecorder
+	// steps notes when the build commands start, for the summary and the
+	// result file
+	steps *stepRecorder
+	// logFile is set when the output is also written to --log-file
+	logFile *plainLogFile
+	// exitStatus notes the exit status of the build command that failed
+	exitStatus *exitStatusRecorder
+	// stdoutFile and stderrFile are set when the streams are split
+	stdoutFile *plainLogFile
+	stderrFile *plainLogFile
+}
+
+// newJobOutput streams the job output to the terminal while capturing
+// it in the job's log file and timed recording. The values of the secrets
+// of the build file are masked, and stderr is labelled and the lines are
+// optionally timestamped on the terminal.
+func newJobOutput(job *jobRecord) (*jobOutput, error) {
+	secrets, err := buildFileSecrets()
+	if err != nil {
+		return nil, err
+	}
+	log, err := job.createLog()
+	if err != nil {
+		return nil, err
+	}
+	castPath, err := job.castPath()
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	cast, err := newCastRecorder(castPath, "rai job "+job.ID)
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	logFile, err := createLogFile(job)
+	if err != nil {
+		log.Close()
+		cast.Close()
+		return nil, err
+	}
+	stamper, err := newLineStamper(timestampsFlag, job)
+	if err != nil {
+		log.Close()
+		cast.Close()
+		if logFile != nil {
+			logFile.Close()
+		}
+		return nil, err
+	}
+	filter, err := newLineFilter()
+	if err != nil {
+		log.Close()
+		cast.Close()
+		if logFile != nil {
+			logFile.Close()
+		}
+		return nil, err
+	}
+	stdoutFile, stderrFile, err := createSplitStreams(job)
+	if err != nil {
+		log.Close()
+		cast.Close()
+		if logFile != nil {
+			logFile.Close()
+		}
+		return nil, err
+	}
+	steps := &stepRecorder{}
+	// what is shown on the terminal is filtered, labelled and colored
+	stdoutTerminal := withLineFilter(withTheme(withTimestamps(withoutColors(os.Stdout), stamper)), filter)
+	stderrTerminal := withLineFilter(withTimestamps(withStderrLabel(withoutColors(os.Stderr)), stamper), filter)
+	// the exit status lines are consumed before the output is shown or
+	// recorded anywhere
+	exitStatus := &exitStatusRecorder{
+		w: withRecords(withHooks(withStepRecorder(withEvents(withLogFile(withLogFile(withDashboard(io.MultiWriter(withoutStats(stdoutTerminal), log, withoutStats(cast))), logFile), stdoutFile), job, "stdout"), steps), "stdout"), job, RecordStdout),
+	}
+	return &jobOutput{
+		stdout:     withoutSecrets(exitStatus, secrets),
+		stderr:     withoutSecrets(withRecords(withHooks(withEvents(withLogFile(withLogFile(io.MultiWriter(withoutStats(stderrTerminal), log, withoutStats(cast)), logFile), stderrFile), job, "stderr"), "stderr"), job, RecordStderr), secrets),
+		terminal:   []io.Writer{stdoutTerminal, stderrTerminal},
+		log:        log,
+		cast:       cast,
+		steps:      steps,
+		exitStatus: exitStatus,
+		logFile:    logFile,
+		stdoutFile: stdoutFile,
+		stderrFile: stderrFile,
+	}, nil
+}
+
+func (o *jobOutput) clientOptions() []client.Option {
+	return []client.Option{
+		client.Stdout(nopWriteCloser{o.stdout}),
+		client.Stderr(nopWriteCloser{o.stderr}),
+	}
+}
+
+func (o *jobOutput) Close() error {
+	for _, w := range []io.Writer{o.stdout, o.stderr} {
+		if filter, ok := w.(*secretsFilter); ok {
+			filter.Flush()
+		}
+	}
+	o.exitStatus.Flush()
+	for _, w := range o.terminal {
+		if filter, ok := w.(*filteredLines); ok {
+			filter.Flush()
+		}
+	}
+	o.cast.Close()
+	for _, f := range []*plainLogFile{o.logFile, o.stdoutFile, o.stderrFile} {
+		if f != nil {
+			f.Close()
+		}
+	}
+	return o.log.Close()
+}
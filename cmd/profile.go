@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"io/ioutil"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/rai-project/auth/provider"
+	"gopkg.in/yaml.v2"
+)
+
+// profilePath returns the location of the user profile
+func profilePath() (string, error) {
+	return homedir.Expand("~/.rai_profile")
+}
+
+// loadProfile reads the user profile (e.g. ~/.rai_profile) and verifies
+// the credentials within it
+func loadProfile() (provider.Profile, error) {
+	prof, err := provider.New()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := prof.Verify()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.Errorf("cannot authenticate using the credentials in %v", prof.Options().ProfilePath)
+	}
+	return prof, nil
+}
+
+// profileInfo returns the user information of the profile keyed by the
+// names used within the profile file (e.g. access_key)
+func profileInfo(prof provider.Profile) (map[string]interface{}, error) {
+	buf, err := yaml.Marshal(prof.Info())
+	if err != nil {
+		return nil, err
+	}
+	info := map[string]interface{}{}
+	if err := yaml.Unmarshal(buf, &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// readProfileEntries reads the entries within the profile section of the
+// profile file, preserving their order
+func readProfileEntries() (yaml.MapSlice, error) {
+	path, err := profilePath()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the profile. Use `rai login` to create one")
+	}
+	var content struct {
+		Profile yaml.MapSlice `yaml:"profile"`
+	}
+	if err := yaml.Unmarshal(buf, &content); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the profile %v", path)
+	}
+	return content.Profile, nil
+}
+
+// writeProfileEntries writes the entries as the profile section of the
+// profile file
+func writeProfileEntries(entries yaml.MapSlice) error {
+	path, err := profilePath()
+	if err != nil {
+		return err
+	}
+	buf, err := yaml.Marshal(yaml.MapSlice{{Key: "profile", Value: entries}})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0600)
+}
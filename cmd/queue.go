@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rai-project/rai/rai"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// queueInfo describes a job queue that is listed in the client.queues
+// section of the configuration
+type queueInfo struct {
+	Name         string `mapstructure:"name"`
+	Architecture string `mapstructure:"architecture"`
+	GPU          string `mapstructure:"gpu"`
+	// DisableExec turns off rai exec for the queue. It is read from the
+	// client configuration, which the user can change, so it is advisory
+	// and not enforced by the server.
+	DisableExec bool `mapstructure:"disable_exec"`
+	// Quota is the number of jobs that can be submitted to the queue
+	// within the client.quota.window
+	Quota int `mapstructure:"quota"`
+	// MaxUploadSize is the largest directory that can be submitted to
+	// the queue, such as 500MB
+	MaxUploadSize string `mapstructure:"max_upload_size"`
+	// MaxGPUs, MaxCPUs and MaxMemory bound the resources a job on the
+	// queue can request. Zero or empty means no limit.
+	MaxGPUs   int    `mapstructure:"max_gpus"`
+	MaxCPUs   int    `mapstructure:"max_cpus"`
+	MaxMemory string `mapstructure:"max_memory"`
+}
+
+// queueCmd groups the commands that describe the job queues
+var queueCmd = &cobra.Command{
+	Use:          "queue",
+	Short:        "Describe the job queues that jobs can be submitted to.",
+	SilenceUsage: true,
+}
+
+func configuredQueues() ([]queueInfo, error) {
+	var queues []queueInfo
+	if err := viper.UnmarshalKey("client.queues", &queues); err != nil {
+		return nil, err
+	}
+	return queues, nil
+}
+
+// findQueue returns the configured queue with the name, or nil if the
+// queue is not listed in the configuration
+func findQueue(name string) (*queueInfo, error) {
+	queues, err := configuredQueues()
+	if err != nil {
+		return nil, err
+	}
+	for ii := range queues {
+		if queues[ii].Name == name {
+			return &queues[ii], nil
+		}
+	}
+	return nil, nil
+}
+
+// checkQueue fails if the configuration lists the queues and the queue is
+// not one of them
+func checkQueue(name string) error {
+	queues, err := configuredQueues()
+	if err != nil || len(queues) == 0 || name == "" {
+		return err
+	}
+	if queue, err := findQueue(name); err != nil || queue != nil {
+		return err
+	}
+	return rai.ErrQueueNotFound.Wrap(fmt.Errorf("the queue %v does not exist", name))
+}
+
+// defaultQueueName returns the queue that jobs are submitted to when no
+// queue is specified
+func defaultQueueName() string {
+	if jobQueueName != "" {
+		return jobQueueName
+	}
+	return viper.GetString("client.job_queue_name")
+}
+
+func init() {
+	RootCmd.AddCommand(queueCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var queueListCmd = &cobra.Command{
+	Use:          "list",
+	Aliases:      []string{"ls"},
+	Short:        "Lists the job queues that jobs can be submitted to.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		queues, err := configuredQueues()
+		if err != nil {
+			return err
+		}
+		if len(queues) == 0 {
+			fmt.Println("No job queues are configured.")
+			return nil
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Default", "Name", "Architecture", "GPU"})
+		defaultQueue := defaultQueueName()
+		for _, queue := range queues {
+			isDefault := ""
+			if queue.Name == defaultQueue {
+				isDefault = "*"
+			}
+			table.Append([]string{isDefault, queue.Name, queue.Architecture, queue.GPU})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+func init() {
+	queueCmd.AddCommand(queueListCmd)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// queueStats summarizes the jobs that were submitted to a queue from this
+// machine, as recorded in the local job store. The server does not report
+// the state of its queues, so these are local history rather than the
+// current load of the queue.
+type queueStats struct {
+	Name   string
+	Jobs   int
+	Active int
+	// AverageWait is measured from when the job was published to when rai
+	// connected to it, which approximates the wait in the queue
+	AverageWait time.Duration
+	// WaitJobs are the jobs that AverageWait is computed from
+	WaitJobs        int
+	AverageDuration time.Duration
+}
+
+func computeQueueStats(name string, jobs []*jobRecord) queueStats {
+	stats := queueStats{Name: name}
+	var totalWait, totalDuration time.Duration
+	var waitCount, durationCount int
+	for _, job := range jobs {
+		queue := job.Queue
+		if queue == "" {
+			queue = defaultQueueName()
+		}
+		if queue != name {
+			continue
+		}
+		stats.Jobs++
+		if job.isAttached() {
+			stats.Active++
+		}
+		if !job.QueuedAt.IsZero() && !job.StartedAt.IsZero() {
+			totalWait += job.StartedAt.Sub(job.QueuedAt)
+			waitCount++
+		}
+		if job.Phase == jobPhaseFinished && !job.StartedAt.IsZero() {
+			totalDuration += job.FinishedAt.Sub(job.StartedAt)
+			durationCount++
+		}
+	}
+	if waitCount > 0 {
+		stats.AverageWait = totalWait / time.Duration(waitCount)
+		stats.WaitJobs = waitCount
+	}
+	if durationCount > 0 {
+		stats.AverageDuration = totalDuration / time.Duration(durationCount)
+	}
+	return stats
+}
+
+// estimate returns a one line estimate of how long a new job waits in
+// the queue before it starts, from the local history of the queue
+func (s queueStats) estimate() string {
+	if s.AverageWait == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Jobs submitted to %v from this machine started %v after being queued on average (based on %v earlier jobs).",
+		s.Name, s.AverageWait.Round(time.Second), s.WaitJobs)
+}
+
+var queueStatsCmd = &cobra.Command{
+	Use:   "stats [name]",
+	Short: "Prints the wait and run times of the jobs submitted to a queue from this machine.",
+	Long: `Prints the wait and run times of a job queue computed from the local history of the jobs ` +
+		`submitted from this machine. The server does not report the state of its queues, so the ` +
+		`jobs of other users are not counted. The wait is measured from publishing the job to ` +
+		`connecting to it.`,
+	SilenceUsage: true,
+	Args:         cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := defaultQueueName()
+		if len(args) == 1 {
+			name = args[0]
+		}
+		jobs, err := listJobRecords()
+		if err != nil {
+			return err
+		}
+		stats := computeQueueStats(name, jobs)
+		if stats.Jobs == 0 {
+			fmt.Printf("No jobs were submitted to %v from this machine.\n", name)
+			return nil
+		}
+
+		fmt.Println("Local history of the jobs submitted from this machine")
+		fmt.Printf("%-18s %v\n", "Queue:", stats.Name)
+		fmt.Printf("%-18s %v\n", "Jobs:", stats.Jobs)
+		fmt.Printf("%-18s %v\n", "Active jobs:", stats.Active)
+		fmt.Printf("%-18s %v\n", "Average wait:", stats.AverageWait.Round(time.Second))
+		fmt.Printf("%-18s %v\n", "Average duration:", stats.AverageDuration.Round(time.Second))
+		fmt.Printf("%-18s %v\n", "Estimated start:", (stats.AverageWait + time.Duration(stats.Active)*stats.AverageDuration).Round(time.Second))
+		return nil
+	},
+}
+
+func init() {
+	queueCmd.AddCommand(queueStatsCmd)
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// quotaUsage is the number of submissions used within the quota window
+type quotaUsage struct {
+	// Name is the queue the quota applies to, or empty for the quota that
+	// applies to all queues
+	Name  string
+	Limit int
+	Used  int
+	// Reset is when the oldest submission leaves the window
+	Reset time.Time
+}
+
+func (u quotaUsage) remaining() int {
+	if u.Used >= u.Limit {
+		return 0
+	}
+	return u.Limit - u.Used
+}
+
+func (u quotaUsage) String() string {
+	scope := "submissions remaining"
+	if u.Name != "" {
+		scope += " on " + u.Name
+	}
+	s := fmt.Sprintf("%v of %v %v", u.remaining(), u.Limit, scope)
+	if !u.Reset.IsZero() {
+		s += fmt.Sprintf(" (next one is freed at %v)", u.Reset.Format("15:04"))
+	}
+	return s
+}
+
+// quotaWindow is the duration that the submission quotas apply to
+func quotaWindow() time.Duration {
+	return viper.GetDuration("client.quota.window")
+}
+
+// computeQuotaUsage counts the jobs that reached the queue within the
+// window. Only the jobs for which match returns true are counted.
+func computeQuotaUsage(name string, limit int, jobs []*jobRecord, match func(*jobRecord) bool) quotaUsage {
+	usage := quotaUsage{Name: name, Limit: limit}
+	window := quotaWindow()
+	for _, job := range jobs {
+		if job.QueuedAt.IsZero() || time.Since(job.QueuedAt) > window || !match(job) {
+			continue
+		}
+		usage.Used++
+		if reset := job.QueuedAt.Add(window); usage.Reset.IsZero() || reset.Before(usage.Reset) {
+			usage.Reset = reset
+		}
+	}
+	return usage
+}
+
+// currentQuotaUsages returns the usage of the quota configured under
+// client.quota and of the queues that have a quota
+func currentQuotaUsages() ([]quotaUsage, error) {
+	if quotaWindow() <= 0 {
+		return nil, nil
+	}
+	jobs, err := listJobRecords()
+	if err != nil {
+		return nil, err
+	}
+	queues, err := configuredQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	usages := []quotaUsage{}
+	if limit := viper.GetInt("client.quota.submissions"); limit > 0 {
+		usages = append(usages, computeQuotaUsage("", limit, jobs, func(*jobRecord) bool {
+			return true
+		}))
+	}
+	for _, queue := range queues {
+		if queue.Quota <= 0 {
+			continue
+		}
+		name := queue.Name
+		usages = append(usages, computeQuotaUsage(name, queue.Quota, jobs, func(job *jobRecord) bool {
+			q := job.Queue
+			if q == "" {
+				q = defaultQueueName()
+			}
+			return q == name
+		}))
+	}
+	return usages, nil
+}
+
+// printQuotaFooter prints the remaining quota that applies to the queue
+func printQuotaFooter(queue string) {
+	usages, err := currentQuotaUsages()
+	if err != nil {
+		return
+	}
+	for _, usage := range usages {
+		if usage.Name == "" || usage.Name == queue {
+			fmt.Println(usage)
+		}
+	}
+}
+
+var quotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Shows how many submissions remain within the quota window.",
+	Long: `Shows the submissions remaining within the quota window, when the next submission is ` +
+		`freed, and the quota of each queue. The usage is counted from the jobs submitted from ` +
+		`this machine.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		usages, err := currentQuotaUsages()
+		if err != nil {
+			return err
+		}
+		if len(usages) == 0 {
+			fmt.Println("No submission quota is configured.")
+			return nil
+		}
+
+		fmt.Printf("Quota window: %v\n\n", quotaWindow())
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Queue", "Used", "Limit", "Remaining", "Next Freed"})
+		for _, usage := range usages {
+			name := usage.Name
+			if name == "" {
+				name = "(all)"
+			}
+			reset := "-"
+			if !usage.Reset.IsZero() {
+				reset = usage.Reset.Format("2006-01-02 15:04")
+			}
+			table.Append([]string{
+				name,
+				fmt.Sprint(usage.Used),
+				fmt.Sprint(usage.Limit),
+				fmt.Sprint(usage.remaining()),
+				reset,
+			})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(quotaCmd)
+}
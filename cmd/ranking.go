@@ -8,6 +8,7 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
@@ -21,12 +22,36 @@ import (
 	upper "upper.io/db.v3"
 )
 
-var numResults int
+var (
+	numResults       int
+	anonymizeRanking bool
+	rankingQueue     string
+)
 
 const (
 	maxResults = 100
 )
 
+// rankingStaffRoles are the roles of the profile that may see the names of
+// the teams in the ranking
+var rankingStaffRoles = []string{"admin", "instructor", "ta", "staff"}
+
+// isStaffProfile returns true if the role of the verified profile is one
+// of the staff roles
+func isStaffProfile(prof provider.Profile) bool {
+	info, err := profileInfo(prof)
+	if err != nil {
+		return false
+	}
+	role := strings.ToLower(cast.ToString(info["role"]))
+	for _, staff := range rankingStaffRoles {
+		if role == staff {
+			return true
+		}
+	}
+	return false
+}
+
 // rankingCmd represents the ranking command
 var rankingCmd = &cobra.Command{}
 
@@ -36,9 +61,10 @@ func init() {
 	}
 	// rankingCmd represents the ranking command
 	rankingCmd = &cobra.Command{
-		Use:   "ranking",
-		Short: "View anonymous rankings.",
-		Long:  `View anonymized convolution performance. Only the fastest result for each team is reported.`,
+		Use:     "ranking",
+		Aliases: []string{"leaderboard"},
+		Short:   "View anonymous rankings.",
+		Long:    `View anonymized convolution performance. Only the fastest result for each team is reported.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			min := func(a, b int) int {
@@ -60,13 +86,36 @@ func init() {
 			}
 			defer col.Close()
 
+			// Get current user details
+			prof, err := provider.New()
+			if err != nil {
+				return err
+			}
+
+			ok, err := prof.Verify()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.Errorf("cannot authenticate using the credentials in %v", prof.Options().ProfilePath)
+			}
+
+			// only the staff may see the names of the other teams
+			if !anonymizeRanking && !isStaffProfile(prof) {
+				return errors.New("only the course staff can show the team names. Run the command without --anonymize=false")
+			}
+
 			// Get submissions
+			valid := upper.Cond{
+				"ranking_valid":            true,
+				"inferences.0.correctness": 0.8171,
+			}
+			if rankingQueue != "" {
+				valid["queue"] = rankingQueue
+			}
 			cond := upper.And(
 				//condInferencesExist,
-				upper.Cond{
-					"ranking_valid":            true,
-					"inferences.0.correctness": 0.8171,
-				},
+				valid,
 			)
 
 			var jobs client.Ece408JobResponseBodys
@@ -91,20 +140,6 @@ func init() {
 
 			numResults = min(numResults, len(jobs))
 
-			// Get current user details
-			prof, err := provider.New()
-			if err != nil {
-				return err
-			}
-
-			ok, err := prof.Verify()
-			if err != nil {
-				return err
-			}
-			if !ok {
-				return errors.Errorf("cannot authenticate using the credentials in %v", prof.Options().ProfilePath)
-			}
-
 			tname, err := client.FindTeamName(prof.Info().Username)
 			if err != nil {
 				return err
@@ -116,22 +151,29 @@ func init() {
 
 			// Create table of ranking
 			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"You", "Rank", "Anonymized Team", "Fastest (ms)"})
+			teamHeader := "Team"
+			if anonymizeRanking {
+				teamHeader = "Anonymized Team"
+			}
+			table.SetHeader([]string{"You", "Rank", teamHeader, "Fastest (ms)"})
 
 			currentRank := 1
 			currentMinOpRunTime := time.Duration(0)
 
-			for ii, job := range jobs {
+			for ii, job := range jobs[:numResults] {
 				if currentMinOpRunTime != job.MinOpRuntime() {
 					currentMinOpRunTime = job.MinOpRuntime()
-					currentRank = ii
+					currentRank = ii + 1
 				}
 
 				srank := cast.ToString(currentRank)
 				sMinOpTime := fmt.Sprintf("%v", currentMinOpRunTime)
-				anonymizedTeamName := job.Anonymize().Teamname
+				teamName := job.Teamname
+				if anonymizeRanking {
+					teamName = job.Anonymize().Teamname
+				}
 
-				row := []string{tname + " -->", srank, anonymizedTeamName, sMinOpTime}
+				row := []string{tname + " -->", srank, teamName, sMinOpTime}
 
 				if tname != job.Teamname {
 					row[0] = ""
@@ -144,5 +186,7 @@ func init() {
 		},
 	}
 	rankingCmd.Flags().IntVarP(&numResults, "num-results", "n", 10, "Number of results to show (<"+strconv.Itoa(maxResults)+")")
+	rankingCmd.Flags().BoolVar(&anonymizeRanking, "anonymize", true, "Show anonymized team names. Only the course staff can turn this off.")
+	rankingCmd.Flags().StringVar(&rankingQueue, "queue", "", "Only rank the results of jobs run on this queue.")
 	RootCmd.AddCommand(rankingCmd)
 }
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/rai-project/rai/rai"
+	"github.com/spf13/viper"
+)
+
+// waitWithReconnect waits for the job to end. When the connection to the
+// job drops with a transient error, the client subscribes to the output of
+// the job and connects to it again, up to client.reconnect_attempts times,
+// waiting between the attempts as the retry policy does.
+func waitWithReconnect(ctx context.Context, client rai.Client, job *jobRecord, retry retryPolicy) error {
+	policy := retry
+	policy.MaxAttempts = viper.GetInt("client.reconnect_attempts") + 1
+	attempt := 0
+	var waitErr error
+	backoff.RetryNotify(func() error {
+		attempt++
+		waitErr = nil
+		if attempt > 1 {
+			fmt.Printf("Reconnecting to job %v (attempt %v of %v)\n", job.ID, attempt-1, policy.MaxAttempts-1)
+			events.emit(jobEvent{Type: "reconnecting", JobID: job.ID, Data: map[string]interface{}{"attempt": attempt - 1}})
+			if waitErr = clientStep(ctx, "Subscribe", client.Subscribe)(); waitErr == nil {
+				waitErr = clientStep(ctx, "Connect", client.Connect)()
+			}
+			if waitErr == nil {
+				fmt.Println("Reconnected. The output sent while disconnected may be missing.")
+			}
+		}
+		if waitErr == nil {
+			waitErr = clientStep(ctx, "Wait", client.Wait)()
+		}
+		if waitErr == nil || !isRetryable(waitErr) {
+			return nil
+		}
+		return waitErr
+	}, policy.backOff(), func(err error, wait time.Duration) {
+		fmt.Printf("The connection to job %v was lost: %v. Reconnecting in %v\n", job.ID, err, wait.Round(time.Second/10))
+	})
+	return waitErr
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// castHeader is the first line of a recording in the asciicast v2 format,
+// which can also be played with asciinema
+type castHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// castEvent is a chunk of output and the time it was written at, relative
+// to the start of the recording
+type castEvent struct {
+	Time float64
+	Data string
+}
+
+func (e castEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, "o", e.Data})
+}
+
+func (e *castEvent) UnmarshalJSON(buf []byte) error {
+	var fields []interface{}
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return errors.Errorf("expecting 3 fields in the recording event, but got %v", len(fields))
+	}
+	t, ok := fields[0].(float64)
+	data, ok2 := fields[2].(string)
+	if !ok || !ok2 {
+		return errors.New("invalid recording event")
+	}
+	e.Time, e.Data = t, data
+	return nil
+}
+
+// castRecorder records the output of a job along with its timing
+type castRecorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	enc   *json.Encoder
+	start time.Time
+}
+
+func newCastRecorder(path, title string) (*castRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := terminal.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width == 0 {
+		width, height = 80, 24
+	}
+	r := &castRecorder{
+		f:     f,
+		enc:   json.NewEncoder(f),
+		start: time.Now(),
+	}
+	if err := r.enc.Encode(castHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Title:     title,
+	}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *castRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event := castEvent{
+		Time: time.Since(r.start).Seconds(),
+		Data: string(p),
+	}
+	if err := r.enc.Encode(event); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (r *castRecorder) Close() error {
+	return r.f.Close()
+}
+
+// readCastEvents reads a recording, calling fn for every event
+func readCastEvents(r io.Reader, fn func(castEvent) error) (*castHeader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("the recording is empty")
+	}
+	header := &castHeader{}
+	if err := json.Unmarshal(scanner.Bytes(), header); err != nil {
+		return nil, errors.Wrap(err, "invalid recording header")
+	}
+	for scanner.Scan() {
+		var event castEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, err
+		}
+		if err := fn(event); err != nil {
+			return nil, err
+		}
+	}
+	return header, scanner.Err()
+}
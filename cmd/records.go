@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acarl005/stripansi"
+)
+
+// RecordType is the kind of a Record
+type RecordType string
+
+// the kinds of records of a job
+const (
+	// RecordStdout is a line that a build command wrote to stdout
+	RecordStdout RecordType = "stdout"
+	// RecordStderr is a line that a build command wrote to stderr
+	RecordStderr RecordType = "stderr"
+	// RecordStatus is a change of the phase of the job
+	RecordStatus RecordType = "status"
+	// RecordSample is a resource usage sample, taken when the job is
+	// submitted with --stats-interval
+	RecordSample RecordType = "sample"
+)
+
+// ResourceSample is the resource usage of a job at a point in time
+type ResourceSample struct {
+	// CPUTime is the total cpu time consumed by the job
+	CPUTime time.Duration
+	// Memory is the memory used by the job in bytes
+	Memory uint64
+	// GPUUtilization is the utilization of the GPU in percent, or -1 if
+	// the job has no GPU
+	GPUUtilization int
+	// GPUMemory is the memory used on the GPU in bytes
+	GPUMemory uint64
+}
+
+// Record is an item of the output of a job
+type Record struct {
+	Type  RecordType
+	JobID string
+	Time  time.Time
+	// Line is the line of stdout and stderr records, without colors and
+	// with the secrets masked
+	Line string
+	// Phase is the phase of status records: uploading, queued, running,
+	// finished, failed or cancelled
+	Phase string
+	// Sample is the resource usage of sample records
+	Sample *ResourceSample
+}
+
+// recordStreamSize is the number of records that a stream holds before the
+// job waits for them to be read
+const recordStreamSize = 1024
+
+var (
+	recordStreamsMu sync.Mutex
+	recordStreams   []chan Record
+)
+
+// Stream returns a channel that receives the records of the jobs that are
+// run by Execute, and that is closed when Execute returns. It must be
+// called before Execute, and the channel must be read while the jobs run:
+// the output of the job waits for records that are not read.
+func Stream() <-chan Record {
+	recordStreamsMu.Lock()
+	defer recordStreamsMu.Unlock()
+	records := make(chan Record, recordStreamSize)
+	recordStreams = append(recordStreams, records)
+	return records
+}
+
+// hasRecordStreams returns true if Stream was called
+func hasRecordStreams() bool {
+	recordStreamsMu.Lock()
+	defer recordStreamsMu.Unlock()
+	return len(recordStreams) != 0
+}
+
+// sendRecord sends the record to the streams, filling in its time
+func sendRecord(record Record) {
+	recordStreamsMu.Lock()
+	defer recordStreamsMu.Unlock()
+	record.Time = time.Now()
+	for _, records := range recordStreams {
+		records <- record
+	}
+}
+
+// closeRecordStreams closes the streams once Execute returns
+func closeRecordStreams() {
+	recordStreamsMu.Lock()
+	defer recordStreamsMu.Unlock()
+	for _, records := range recordStreams {
+		close(records)
+	}
+	recordStreams = nil
+}
+
+// recordLines sends the lines of a stream of the job, and the resource
+// usage samples found in them, as records
+type recordLines struct {
+	sync.Mutex
+	job     *jobRecord
+	typ     RecordType
+	pending []byte
+}
+
+func (l *recordLines) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	l.pending = append(l.pending, p...)
+	for {
+		idx := bytes.IndexByte(l.pending, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimRight(stripansi.Strip(string(l.pending[:idx])), "\r")
+		l.pending = l.pending[idx+1:]
+		if sample, ok := parseStatsSample(strings.TrimSpace(line)); ok {
+			sendRecord(Record{Type: RecordSample, JobID: l.job.ID, Sample: &ResourceSample{
+				CPUTime:        sample.CPUTime,
+				Memory:         sample.Memory,
+				GPUUtilization: sample.GPUUtilization,
+				GPUMemory:      sample.GPUMemory,
+			}})
+			continue
+		}
+		if isHiddenLine([]byte(line), false) {
+			continue
+		}
+		sendRecord(Record{Type: l.typ, JobID: l.job.ID, Line: line})
+	}
+}
+
+// withRecords sends the output written to w as records of the job if
+// Stream was called
+func withRecords(w io.Writer, job *jobRecord, typ RecordType) io.Writer {
+	if !hasRecordStreams() {
+		return w
+	}
+	return io.MultiWriter(w, &recordLines{job: job, typ: typ})
+}
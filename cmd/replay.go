@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Unknwon/com"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replaySpeed     float64
+	replayIdleLimit time.Duration
+	replayExport    string
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <id>",
+	Short: "Replays the recorded output of a job with its original timing.",
+	Long: `Replays the output of a job as it was streamed, with its original timing. Pauses are ` +
+		`shortened to --idle-limit. With --export the recording is written to a file in the ` +
+		`asciicast format, which can also be played with asciinema.`,
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+		path, err := job.castPath()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrapf(err, "no recording exists for job %v", job.ID)
+		}
+		defer f.Close()
+
+		if replayExport != "" {
+			if com.IsFile(replayExport) && !forceOutput {
+				return errors.Errorf("%v already exists. Use --force to overwrite it", replayExport)
+			}
+			out, err := os.Create(replayExport)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, f); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+			fmt.Printf("The recording of job %v was written to %v\n", job.ID, replayExport)
+			return nil
+		}
+
+		if replaySpeed <= 0 {
+			return errors.New("the replay speed must be positive")
+		}
+		last := 0.0
+		_, err = readCastEvents(f, func(event castEvent) error {
+			delay := time.Duration((event.Time - last) * float64(time.Second))
+			if replayIdleLimit > 0 && delay > replayIdleLimit {
+				delay = replayIdleLimit
+			}
+			time.Sleep(time.Duration(float64(delay) / replaySpeed))
+			last = event.Time
+			_, err := io.WriteString(os.Stdout, event.Data)
+			return err
+		})
+		return err
+	},
+}
+
+func init() {
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Playback speed multiplier.")
+	replayCmd.Flags().DurationVar(&replayIdleLimit, "idle-limit", 2*time.Second, "Shorten pauses to at most this duration (0 keeps the original pauses).")
+	replayCmd.Flags().StringVar(&replayExport, "export", "", "Write the recording to the file instead of playing it.")
+	RootCmd.AddCommand(replayCmd)
+}
@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Unknwon/com"
+	"github.com/acarl005/stripansi"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// the formats of the run reports
+const (
+	reportFormatMarkdown = "markdown"
+	reportFormatHTML     = "html"
+)
+
+// maxReportLines is the number of lines of the output of a build command
+// kept in the report
+const maxReportLines = 500
+
+var reportFormat string
+
+// reportStep is a build command in the report
+type reportStep struct {
+	Command  string
+	Duration string
+	Lines    []string
+	// Omitted is the number of lines of output left out of the report
+	Omitted int
+}
+
+// runReport is what the report of a job shows
+type runReport struct {
+	Job       *jobRecord
+	Generated string
+	Duration  string
+	BuildFile string
+	Steps     []reportStep
+	BuildURL  string
+	Artifacts []string
+}
+
+// readReportSteps splits the recording of the job into the build commands,
+// timing each of them from the time the server started it to the start of
+// the next one or the end of the recording. Without a recording, the log
+// of the job is used and the commands are not timed.
+func readReportSteps(job *jobRecord) ([]reportStep, error) {
+	castPath, err := job.castPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(castPath)
+	if err != nil {
+		outputs, err := jobStepOutputs(job)
+		if err != nil {
+			return nil, err
+		}
+		steps := make([]reportStep, len(outputs))
+		for ii, output := range outputs {
+			steps[ii] = reportStep{Command: output.Command, Lines: output.Lines}
+		}
+		return steps, nil
+	}
+	defer f.Close()
+	steps := []reportStep{}
+	starts := []float64{}
+	pending, last := "", 0.0
+	addLine := func(line string, at float64) {
+		line = strings.TrimRight(stripansi.Strip(line), "\r")
+		if isHiddenLine([]byte(line), false) {
+			return
+		}
+		if strings.HasPrefix(line, stepStartPrefix) {
+			steps = append(steps, reportStep{Command: strings.TrimPrefix(line, stepStartPrefix)})
+			starts = append(starts, at)
+			return
+		}
+		if len(steps) != 0 {
+			steps[len(steps)-1].Lines = append(steps[len(steps)-1].Lines, line)
+		}
+	}
+	_, err = readCastEvents(f, func(event castEvent) error {
+		pending += event.Data
+		last = event.Time
+		for {
+			idx := strings.IndexByte(pending, '\n')
+			if idx < 0 {
+				return nil
+			}
+			addLine(pending[:idx], event.Time)
+			pending = pending[idx+1:]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pending != "" {
+		addLine(pending, last)
+	}
+	for ii := range steps {
+		end := last
+		if ii+1 < len(starts) {
+			end = starts[ii+1]
+		}
+		steps[ii].Duration = (time.Duration((end - starts[ii]) * float64(time.Second))).Round(100 * time.Millisecond).String()
+	}
+	return steps, nil
+}
+
+// newRunReport gathers the build file, the output of every build command
+// and the artifacts of the job
+func newRunReport(job *jobRecord) (*runReport, error) {
+	report := &runReport{
+		Job:       job,
+		Generated: time.Now().Format(time.RFC1123),
+		BuildURL:  job.BuildURL,
+	}
+	if !job.StartedAt.IsZero() && !job.FinishedAt.IsZero() {
+		report.Duration = job.FinishedAt.Sub(job.StartedAt).Round(time.Second).String()
+	}
+	buf, err := readJobStoreFile(job.submittedBuildFilePath)
+	if err != nil {
+		return nil, err
+	}
+	report.BuildFile = string(buf)
+	var doc yaml.MapSlice
+	if yaml.Unmarshal(buf, &doc) == nil {
+		report.Artifacts, _ = artifactPatterns(doc)
+	}
+	steps, err := readReportSteps(job)
+	if err != nil {
+		return nil, err
+	}
+	for ii := range steps {
+		if omitted := len(steps[ii].Lines) - maxReportLines; omitted > 0 {
+			steps[ii].Lines = steps[ii].Lines[omitted:]
+			steps[ii].Omitted = omitted
+		}
+	}
+	report.Steps = steps
+	return report, nil
+}
+
+// markdownFence returns a code fence that is longer than any run of
+// backticks in the text
+func markdownFence(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence
+}
+
+// renderMarkdown renders the report as Markdown
+func (r *runReport) renderMarkdown() []byte {
+	buf := new(bytes.Buffer)
+	job := r.Job
+	fmt.Fprintf(buf, "# rai job %v\n\n", job.ID)
+	fmt.Fprintf(buf, "| | |\n|---|---|\n")
+	fmt.Fprintf(buf, "| Phase | %v |\n", job.Phase)
+	if job.Queue != "" {
+		fmt.Fprintf(buf, "| Queue | %v |\n", job.Queue)
+	}
+	fmt.Fprintf(buf, "| Submitted | %v |\n", job.CreatedAt.Format(time.RFC1123))
+	if r.Duration != "" {
+		fmt.Fprintf(buf, "| Duration | %v |\n", r.Duration)
+	}
+	if job.SourceDigest != "" {
+		fmt.Fprintf(buf, "| Content hash | `%v` |\n", job.SourceDigest)
+	}
+	if job.Error != "" {
+		fmt.Fprintf(buf, "| Error | %v |\n", strings.Replace(job.Error, "|", "\\|", -1))
+	}
+	if r.BuildFile != "" {
+		fence := markdownFence(r.BuildFile)
+		fmt.Fprintf(buf, "\n## Build File\n\n%vyaml\n%v\n%v\n", fence, strings.TrimRight(r.BuildFile, "\n"), fence)
+	}
+	if len(r.Steps) != 0 {
+		fmt.Fprintf(buf, "\n## Timings\n\n| Step | Command | Duration |\n|---|---|---|\n")
+		for ii, step := range r.Steps {
+			fmt.Fprintf(buf, "| %v | `%v` | %v |\n", ii+1, strings.Replace(step.Command, "|", "\\|", -1), step.Duration)
+		}
+		fmt.Fprintf(buf, "\n## Output\n")
+		for ii, step := range r.Steps {
+			output := strings.Join(step.Lines, "\n")
+			fence := markdownFence(output)
+			fmt.Fprintf(buf, "\n### %v. `%v`\n\n", ii+1, step.Command)
+			if step.Omitted != 0 {
+				fmt.Fprintf(buf, "The first %v lines are omitted.\n\n", step.Omitted)
+			}
+			fmt.Fprintf(buf, "%v\n%v\n%v\n", fence, output, fence)
+		}
+	}
+	if r.BuildURL != "" || len(r.Artifacts) != 0 {
+		fmt.Fprintf(buf, "\n## Artifacts\n\n")
+		if r.BuildURL != "" {
+			fmt.Fprintf(buf, "- [Build directory](%v)\n", r.BuildURL)
+		}
+		for _, artifact := range r.Artifacts {
+			fmt.Fprintf(buf, "- `%v`\n", artifact)
+		}
+	}
+	fmt.Fprintf(buf, "\n_Generated by rai on %v._\n", r.Generated)
+	return buf.Bytes()
+}
+
+var reportHTMLTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc": func(ii int) int { return ii + 1 },
+	"join": func(lines []string) string {
+		return strings.Join(lines, "\n")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>rai job {{.Job.ID}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; }
+footer { color: #666; margin-top: 2em; }
+</style>
+</head>
+<body>
+<h1>rai job {{.Job.ID}}</h1>
+<table>
+<tr><th>Phase</th><td>{{.Job.Phase}}</td></tr>
+{{if .Job.Queue}}<tr><th>Queue</th><td>{{.Job.Queue}}</td></tr>{{end}}
+<tr><th>Submitted</th><td>{{.Job.CreatedAt.Format "Mon, 02 Jan 2006 15:04:05 MST"}}</td></tr>
+{{if .Duration}}<tr><th>Duration</th><td>{{.Duration}}</td></tr>{{end}}
+{{if .Job.SourceDigest}}<tr><th>Content hash</th><td><code>{{.Job.SourceDigest}}</code></td></tr>{{end}}
+{{if .Job.Error}}<tr><th>Error</th><td>{{.Job.Error}}</td></tr>{{end}}
+</table>
+{{if .BuildFile}}<h2>Build File</h2>
+<pre>{{.BuildFile}}</pre>{{end}}
+{{if .Steps}}<h2>Timings</h2>
+<table>
+<tr><th>Step</th><th>Command</th><th>Duration</th></tr>
+{{range $ii, $step := .Steps}}<tr><td>{{inc $ii}}</td><td><code>{{$step.Command}}</code></td><td>{{$step.Duration}}</td></tr>
+{{end}}</table>
+<h2>Output</h2>
+{{range $ii, $step := .Steps}}<details{{if eq (inc $ii) (len $.Steps)}} open{{end}}>
+<summary>{{inc $ii}}. <code>{{$step.Command}}</code></summary>
+{{if $step.Omitted}}<p>The first {{$step.Omitted}} lines are omitted.</p>{{end}}
+<pre>{{join $step.Lines}}</pre>
+</details>
+{{end}}{{end}}
+{{if or .BuildURL .Artifacts}}<h2>Artifacts</h2>
+<ul>
+{{if .BuildURL}}<li><a href="{{.BuildURL}}">Build directory</a></li>{{end}}
+{{range .Artifacts}}<li><code>{{.}}</code></li>
+{{end}}</ul>{{end}}
+<footer>Generated by rai on {{.Generated}}.</footer>
+</body>
+</html>
+`))
+
+// renderHTML renders the report as a self-contained HTML page
+func (r *runReport) renderHTML() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := reportHTMLTemplate.Execute(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report <id> [file]",
+	Short: "Writes a report of a job.",
+	Long: `Writes a self-contained Markdown or HTML report of a job, with its build file, the timing and ` +
+		`output of every build command and links to its artifacts, by default to rai-report-<id>.md. ` +
+		`The format is taken from the extension of the file unless --format is given.`,
+	SilenceUsage: true,
+	Args:         cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+		format := reportFormat
+		out := ""
+		if len(args) == 2 {
+			out = args[1]
+		}
+		if format == "" {
+			format = reportFormatMarkdown
+			if ext := strings.ToLower(filepath.Ext(out)); ext == ".html" || ext == ".htm" {
+				format = reportFormatHTML
+			}
+		}
+		if out == "" {
+			out = "rai-report-" + job.ID + ".md"
+			if format == reportFormatHTML {
+				out = "rai-report-" + job.ID + ".html"
+			}
+		}
+		if com.IsFile(out) && !forceOutput {
+			return errors.Errorf("%v already exists. Use --force to overwrite it", out)
+		}
+		report, err := newRunReport(job)
+		if err != nil {
+			return err
+		}
+		var buf []byte
+		switch format {
+		case reportFormatMarkdown:
+			buf = report.renderMarkdown()
+		case reportFormatHTML:
+			if buf, err = report.renderHTML(); err != nil {
+				return err
+			}
+		default:
+			return errors.Errorf("unknown report format %v, expecting markdown or html", format)
+		}
+		if err := ioutil.WriteFile(out, buf, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("The report of job %v was written to %v\n", job.ID, out)
+		return nil
+	},
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportFormat, "format", "", "Format of the report, markdown or html.")
+	RootCmd.AddCommand(reportCmd)
+}
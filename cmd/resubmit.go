@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// restoreJobSettings sets the directory, build file, queue and commit to
+// the ones the job was submitted with
+func restoreJobSettings(job *jobRecord) {
+	workingDir = job.Directory
+	buildFilePath = job.BuildFile
+	jobQueueName = job.Queue
+	fromGitRef = job.GitCommit
+}
+
+var resubmitCmd = &cobra.Command{
+	Use:   "resubmit [id]",
+	Short: "Re-runs the last submitted job.",
+	Long: `Re-runs a previously submitted job using the same directory, build file, queue and submission tag. ` +
+		`The most recent job is used if no job id is given.`,
+	SilenceUsage: true,
+	Args:         cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var job *jobRecord
+		if len(args) == 1 {
+			j, err := loadJobRecord(args[0])
+			if err != nil {
+				return err
+			}
+			job = j
+		} else {
+			jobs, err := listJobRecords()
+			if err != nil {
+				return err
+			}
+			if len(jobs) == 0 {
+				return errors.New("no job has been submitted from this machine yet")
+			}
+			job = jobs[0]
+		}
+
+		restoreJobSettings(job)
+		submitionName = job.SubmissionTag
+
+		fmt.Printf("Resubmitting job %v from %v\n", job.ID, job.Directory)
+
+		return submitJob()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(resubmitCmd)
+}
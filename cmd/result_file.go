@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	log "github.com/rai-project/logger"
+	"github.com/rai-project/rai/rai"
+)
+
+// defaultResultFile is where the result of the job is written, relative to
+// the submitted directory
+const defaultResultFile = "rai-result.json"
+
+// resultFilePath is where the result of the job is written. The result is
+// not written when it is empty.
+var resultFilePath = defaultResultFile
+
+// jobStepResult is a build command of the job in the result file
+type jobStepResult struct {
+	Command  string  `json:"command"`
+	Duration float64 `json:"duration_seconds"`
+	ExitCode int     `json:"exit_code"`
+}
+
+// submissionReceipt identifies a recorded submission in the result file
+type submissionReceipt struct {
+	Milestone    string `json:"milestone"`
+	SourceDigest string `json:"source_digest"`
+	RecordedAt   string `json:"recorded_at"`
+}
+
+// jobResult is the outcome of the job as written to the result file
+type jobResult struct {
+	JobID      string             `json:"job_id"`
+	Queue      string             `json:"queue"`
+	Phase      jobPhase           `json:"phase"`
+	ExitCode   int                `json:"exit_code"`
+	Error      string             `json:"error,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	Duration   float64            `json:"duration_seconds"`
+	Steps      []jobStepResult    `json:"steps"`
+	BuildURL   string             `json:"build_url,omitempty"`
+	Artifacts  []string           `json:"artifacts,omitempty"`
+	Submission *submissionReceipt `json:"submission,omitempty"`
+
+	// ErrorDetails gives the code, the hint and the exit code of the error
+	ErrorDetails *rai.Error `json:"error_details,omitempty"`
+}
+
+// newJobResult gathers the outcome of the job. The build commands that ran
+// before the last one succeeded, and the last one exited with the status
+// reported for the build command that failed.
+func newJobResult(job *jobRecord, steps *stepRecorder, exitStatus *exitStatusRecorder, jobErr error) *jobResult {
+	queue := job.Queue
+	if queue == "" {
+		queue = defaultQueueName()
+	}
+	result := &jobResult{
+		JobID:     job.ID,
+		Queue:     queue,
+		Phase:     job.Phase,
+		ExitCode:  ExitCode(jobErr),
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		Steps:     []jobStepResult{},
+		BuildURL:  job.BuildURL,
+
+		ErrorDetails: errorDetails(jobErr),
+	}
+	end := job.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if !job.StartedAt.IsZero() {
+		started := job.StartedAt
+		result.StartedAt = &started
+		result.Duration = end.Sub(started).Seconds()
+	}
+	if !job.FinishedAt.IsZero() {
+		finished := job.FinishedAt
+		result.FinishedAt = &finished
+	}
+	recorded, durations := steps.durations(end)
+	for ii, step := range recorded {
+		stepResult := jobStepResult{Command: step.Command, Duration: durations[ii].Seconds()}
+		if ii == len(recorded)-1 {
+			stepResult.ExitCode = exitStatus.lastStatus()
+		}
+		result.Steps = append(result.Steps, stepResult)
+	}
+	result.Artifacts, _ = buildFileArtifacts()
+	if job.SubmissionTag != "" && job.Phase == jobPhaseFinished {
+		result.Submission = &submissionReceipt{
+			Milestone:    job.SubmissionTag,
+			SourceDigest: job.SourceDigest,
+			RecordedAt:   end.Format(time.RFC3339),
+		}
+	}
+	return result
+}
+
+// writeResultFile writes the outcome of the job to the result file, if it
+// is enabled. A relative path is relative to the submitted directory.
+func writeResultFile(job *jobRecord, steps *stepRecorder, exitStatus *exitStatusRecorder, jobErr error) {
+	if resultFilePath == "" {
+		return
+	}
+	path := resultFilePath
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(job.Directory, path)
+	}
+	buf, err := json.MarshalIndent(newJobResult(job, steps, exitStatus, jobErr), "", "  ")
+	if err != nil {
+		log.WithError(err).Error("unable to encode the result of the job")
+		return
+	}
+	if err := ioutil.WriteFile(path, append(buf, '\n'), 0644); err != nil {
+		log.WithError(err).Errorf("unable to write the result of the job to %v", path)
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// retryPolicy configures how the steps that talk to the storage and queue
+// servers are retried after a transient error
+type retryPolicy struct {
+	// MaxAttempts is the number of times a step is tried. A value of one
+	// or less disables retries.
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// Jitter randomizes each interval by up to this fraction of it
+	Jitter float64
+}
+
+// currentRetryPolicy returns the policy set in client.retry, with
+// client.retry.max_attempts also set by --retries
+func currentRetryPolicy() retryPolicy {
+	policy := retryPolicy{
+		MaxAttempts:     viper.GetInt("client.retry.max_attempts"),
+		InitialInterval: viper.GetDuration("client.retry.initial_interval"),
+		MaxInterval:     viper.GetDuration("client.retry.max_interval"),
+		Multiplier:      viper.GetFloat64("client.retry.multiplier"),
+		Jitter:          viper.GetFloat64("client.retry.jitter"),
+	}
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = time.Second
+	}
+	if policy.MaxInterval < policy.InitialInterval {
+		policy.MaxInterval = policy.InitialInterval
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 1
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		policy.Jitter = 0
+	}
+	return policy
+}
+
+func (p retryPolicy) backOff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = p.InitialInterval
+	b.MaxInterval = p.MaxInterval
+	b.Multiplier = p.Multiplier
+	b.RandomizationFactor = p.Jitter
+	// the number of attempts bounds the retries, not the elapsed time
+	b.MaxElapsedTime = 0
+	b.Reset()
+	retries := 0
+	if p.MaxAttempts > 1 {
+		retries = p.MaxAttempts - 1
+	}
+	return backoff.WithMaxRetries(b, uint64(retries))
+}
+
+// retryableMessages are found in the errors of the storage and queue
+// servers that are worth retrying
+var retryableMessages = []string{
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"timeout",
+	"temporarily unavailable",
+	"RequestTimeout",
+	"SlowDown",
+	"ServiceUnavailable",
+	"InternalError",
+}
+
+// isRetryable returns true if the error is likely transient
+func isRetryable(err error) bool {
+	cause := errors.Cause(err)
+	if cause == io.EOF || cause == io.ErrUnexpectedEOF {
+		return true
+	}
+	if netErr, ok := cause.(net.Error); ok && (netErr.Timeout() || netErr.Temporary()) {
+		return true
+	}
+	msg := err.Error()
+	for _, retryable := range retryableMessages {
+		if strings.Contains(msg, retryable) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRefused returns true if the server refused the connection, so that
+// the request was not sent. Steps that must not be repeated once the
+// server received them, such as publishing the job, are only retried
+// after such errors: a timeout or a dropped connection may come after the
+// server acted on the request.
+func isRefused(err error) bool {
+	return strings.Contains(err.Error(), "connection refused")
+}
+
+// withRetry runs the step until it succeeds, fails with an error that is
+// not retryable, or the attempts of the policy are exhausted
+func (p retryPolicy) withRetry(name string, step func() error) error {
+	return p.withRetryIf(name, step, isRetryable)
+}
+
+// withRetryIf runs the step until it succeeds, fails with an error that
+// the retryable function rejects, or the attempts of the policy are
+// exhausted
+func (p retryPolicy) withRetryIf(name string, step func() error, retryable func(error) bool) error {
+	attempt := 0
+	var stepErr error
+	backoff.RetryNotify(func() error {
+		attempt++
+		stepErr = step()
+		if stepErr == nil || !retryable(stepErr) {
+			return nil
+		}
+		return stepErr
+	}, p.backOff(), func(err error, wait time.Duration) {
+		fmt.Printf("%v failed: %v. Retrying in %v (attempt %v of %v)\n", name, err, wait.Round(time.Second/10), attempt+1, p.MaxAttempts)
+	})
+	return stepErr
+}
@@ -6,9 +6,11 @@ import (
 	"path/filepath"
 
 	"github.com/fatih/color"
+	"github.com/pkg/errors"
 	"github.com/rai-project/cmd"
 	"github.com/rai-project/config"
 	_ "github.com/rai-project/logger/hooks" // include all logging hooks
+	"github.com/rai-project/rai/rai"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/xlab/catcher"
@@ -46,18 +48,33 @@ var RootCmd = &cobra.Command{
 		if jobQueueName == "" && ece408ProjectMode {
 			jobQueueName = "rai_amd64_ece408"
 		}
+		if err := checkExitCodeFrom(); err != nil {
+			return err
+		}
+		if err := setOutputFormat(outputFormat); err != nil {
+			return err
+		}
+		setQuietOutput()
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// create a new rai client
-		client, err := newClient()
+		axes, err := matrixAxes()
 		if err != nil {
 			return err
 		}
-		// destroy the client before exiting the function
-		defer client.Disconnect()
-		// run the client steps
-		return runClient(client)
+		if combinations := matrixCombinations(axes); len(combinations) != 0 || len(fanoutQueues) != 0 {
+			if events != nil {
+				return errors.New("--output-format can not be used with --matrix or --queues")
+			}
+			if tuiOutput {
+				return errors.New("--tui can not be used with --matrix or --queues")
+			}
+			return submitConcurrently(cmd.Flags(), concurrentJobs(combinations))
+		}
+		if tuiOutput {
+			return runWithDashboard(submitJob)
+		}
+		return submitJob()
 	},
 }
 
@@ -73,13 +90,23 @@ func safeCall() (err error) {
 	// run the main executable
 	err = RootCmd.Execute()
 	if err != nil {
-		fmt.Println(err.Error())
+		fmt.Println(themeString(themeError, err.Error()))
+		if e := rai.AsError(err); e != nil && e.Hint != "" {
+			fmt.Println(e.Hint)
+		}
 	}
 
 	return
 }
 
+// Execute runs the rai command line. Ctrl-C stops the job that is running.
 func Execute() error {
+	installSignalHandlers()
+	return execute()
+}
+
+func execute() error {
+	defer closeRecordStreams()
 	// make sure to capture panics
 	defer catcher.Catch(
 		catcher.RecvWrite(os.Stderr, isVerbose),
@@ -118,7 +145,7 @@ func init() {
 
 	RootCmd.PersistentFlags().StringVarP(&workingDir, "path", "p", cwd,
 		"Path to the directory you wish to submit. Defaults to the current working directory.")
-	RootCmd.PersistentFlags().StringVarP(&cwd, "build", "f", "", "Path to the build file. Defaults to `cwd`/rai_build.yml file.")
+	RootCmd.PersistentFlags().StringVarP(&buildFilePath, "build", "f", "", "Path to the build file. Defaults to the `cwd`/rai_build.yml, .json or .toml file.")
 	RootCmd.PersistentFlags().StringVarP(&jobQueueName, "queue", "q", "", "Name of the job queue. Infers queue from build file by default.")
 	RootCmd.PersistentFlags().StringVarP(&appSecret, "secret", "s", "", "Pass in application secret.")
 	RootCmd.PersistentFlags().BoolVarP(&isColor, "color", "c", true, "Toggle color output.")
@@ -127,6 +154,40 @@ func init() {
 	RootCmd.PersistentFlags().StringVarP(&outputDirectory, "output", "o", "", "Set to output directory.")
 	RootCmd.PersistentFlags().BoolVar(&forceOutput, "force", false, "Toggle to force overwriting output directory.")
 	RootCmd.PersistentFlags().BoolVar(&isRatelimit, "ratelimit", true, "Toggle rate limiter.")
+	RootCmd.PersistentFlags().Bool("respect-gitignore", false, "Do not upload the files that git ignores.")
+	RootCmd.PersistentFlags().Bool("no-default-excludes", false, "Upload the build outputs (*.o, *.so, build/, __pycache__, .git/) that are excluded by default.")
+	RootCmd.PersistentFlags().Bool("skip-upload-verification", false, "Do not check the uploaded files against their checksums before building.")
+	RootCmd.PersistentFlags().Int("retries", 3, "Number of attempts of the upload, publish and connect steps after transient errors.")
+	RootCmd.PersistentFlags().Int("reconnect-attempts", 3, "Number of times the client reconnects to the job when the connection drops while waiting for it.")
+	RootCmd.PersistentFlags().StringArrayVar(&buildParams, "param", nil, "Set a parameter of the build file as name=value, substituted for {{ .Params.name }}. Can be repeated.")
+	RootCmd.PersistentFlags().StringVar(&buildProfile, "profile", "", "Name of the profile of the build file to submit (e.g. debug or bench).")
+	RootCmd.Flags().StringArrayVar(&matrixFlags, "matrix", nil, "Submit a job for every value of a build file parameter, as NAME=value,value. Can be repeated.")
+	RootCmd.Flags().IntVar(&matrixConcurrency, "matrix-concurrency", 2, "Maximum number of jobs of a matrix, or of --queues, that are submitted at the same time.")
+	RootCmd.Flags().StringSliceVar(&fanoutQueues, "queues", nil, "Submit the job to every one of these queues at the same time (e.g. rai_amd64_k80,rai_amd64_v100).")
+	RootCmd.Flags().BoolVar(&tuiOutput, "tui", false, "Show the upload, queue, resource usage and output of the job in a full-screen dashboard.")
+	RootCmd.Flags().BoolVar(&downloadArtifactsFlag, "download-artifacts", false, "Download the artifacts listed in the build file into artifacts-<id> once the job finishes.")
+	RootCmd.PersistentFlags().DurationVar(&waitTimeout, "wait-timeout", 0, "Stop waiting for the job after this duration (e.g. 30m). Defaults to the timeout of the build file. The job is not stopped on the server.")
+	RootCmd.PersistentFlags().StringVar(&fromGitRef, "from-git", "", "Submit the directory as committed in the git reference (e.g. HEAD) instead of the working tree.")
+	RootCmd.PersistentFlags().StringArrayVar(&includeFlags, "include", nil, "Upload a file or directory outside of the submitted directory as src:dest (e.g. ../common-lib:libs/common). Can be repeated.")
+	RootCmd.PersistentFlags().String("symlinks", symlinksPreserve, "How symbolic links are uploaded: preserve, follow or deny links outside the directory.")
+	RootCmd.PersistentFlags().StringVar(&outputFormat, "output-format", outputFormatText, "Report the progress of the job as text, json or ndjson events on stdout.")
+	RootCmd.PersistentFlags().BoolVar(&quietOutput, "quiet", false, "Do not show the output of the job, only its id and outcome.")
+	RootCmd.PersistentFlags().BoolVar(&summaryOutput, "summary", false, "Print the duration of every build command, the exit status and the artifacts once the job ends.")
+	RootCmd.PersistentFlags().String("log-file", "", "Also write the output of the job, without colors, to this file. <jobid> is replaced by the id of the job.")
+	RootCmd.PersistentFlags().StringVar(&exitCodeFrom, "exit-code-from", exitCodeFromRemote, "Exit with the exit status of the build command that failed (remote), or only with the codes of the failures of the client (client).")
+	RootCmd.PersistentFlags().StringVar(&resultFilePath, "result-file", defaultResultFile, "Write the outcome, steps and artifacts of the job as JSON to this file, relative to the submitted directory. Set it to an empty string to not write it.")
+	RootCmd.PersistentFlags().BoolVar(&notifyFlag, "notify", false, "Show a desktop notification with the outcome and the duration of the job once it ends.")
+	RootCmd.PersistentFlags().String("notify-url", "", "Post the outcome of the job as JSON to this URL once it ends. Slack incoming webhooks are sent a message.")
+	RootCmd.PersistentFlags().StringArrayVar(&grepPatterns, "grep", nil, "Only show the lines of the output that match this regular expression. Can be repeated.")
+	RootCmd.PersistentFlags().StringArrayVar(&grepExcludePatterns, "grep-v", nil, "Hide the lines of the output that match this regular expression. Can be repeated.")
+	RootCmd.PersistentFlags().StringArrayVar(&highlightPatterns, "highlight", nil, "Color the matches of this regular expression in the output. Can be repeated.")
+	RootCmd.PersistentFlags().StringVar(&junitPath, "junit", "", "Write a JUnit XML report of the job to this file: the test_results file of the build file, or the build commands as test cases.")
+	RootCmd.PersistentFlags().BoolVar(&ttyFlag, "tty", false, "Run the build commands in a pseudo-terminal of the size of this terminal, so that they print progress bars and colors. Their stderr is merged into stdout.")
+	RootCmd.PersistentFlags().StringVar(&timestampsFlag, "timestamps", "", "Prefix every line of the output with the wall clock time or the time elapsed since the job started (wall or elapsed) and the number of the build command.")
+	RootCmd.PersistentFlags().Lookup("timestamps").NoOptDefVal = timestampsWall
+	RootCmd.PersistentFlags().BoolVar(&splitStreams, "split-streams", false, "Also write the stdout and stderr of the job to separate files, named after --log-file or the job id.")
+	RootCmd.PersistentFlags().Bool("cancel-on-interrupt", false, "Stop uploading or waiting for the job on Ctrl-C without asking for confirmation. The job is not cancelled on the server.")
+	RootCmd.PersistentFlags().DurationVar(&statsInterval, "stats-interval", 0, "Sample resource usage at this interval for `rai top` (e.g. 2s).")
 	if ece408ProjectMode {
 		RootCmd.PersistentFlags().StringVar(&submitionName, "submit", "", "The kind of submission (m2, m3, final)")
 	}
@@ -142,6 +203,15 @@ func init() {
 	viper.BindPFlag("app.debug", RootCmd.PersistentFlags().Lookup("debug"))
 	viper.BindPFlag("app.verbose", RootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("app.color", RootCmd.PersistentFlags().Lookup("color"))
+	viper.BindPFlag("client.respect_gitignore", RootCmd.PersistentFlags().Lookup("respect-gitignore"))
+	viper.BindPFlag("client.no_default_excludes", RootCmd.PersistentFlags().Lookup("no-default-excludes"))
+	viper.BindPFlag("client.skip_upload_verification", RootCmd.PersistentFlags().Lookup("skip-upload-verification"))
+	viper.BindPFlag("client.retry.max_attempts", RootCmd.PersistentFlags().Lookup("retries"))
+	viper.BindPFlag("client.reconnect_attempts", RootCmd.PersistentFlags().Lookup("reconnect-attempts"))
+	viper.BindPFlag("client.symlinks", RootCmd.PersistentFlags().Lookup("symlinks"))
+	viper.BindPFlag("client.log_file", RootCmd.PersistentFlags().Lookup("log-file"))
+	viper.BindPFlag("client.notify_url", RootCmd.PersistentFlags().Lookup("notify-url"))
+	viper.BindPFlag("client.cancel_on_interrupt", RootCmd.PersistentFlags().Lookup("cancel-on-interrupt"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -149,7 +219,7 @@ func initConfig() {
 	opts := []config.Option{
 		config.AppName("rai"),
 		config.ColorMode(isColor),
-		config.ConfigString(configContent),
+		config.ConfigString(withUserConfig(configContent)),
 	}
 	if appSecret != "" {
 		opts = append(opts, config.AppSecret(appSecret))
@@ -157,6 +227,11 @@ func initConfig() {
 	config.Init(opts...)
 }
 
+// initColor turns colors off when NO_COLOR is set or stdout is not a
+// terminal, unless --color is given
 func initColor() {
+	if !RootCmd.PersistentFlags().Changed("color") && colorsDisabled() {
+		isColor = false
+	}
 	color.NoColor = !isColor
 }
@@ -1,17 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/Unknwon/com"
 	"github.com/rai-project/client"
 	log "github.com/rai-project/logger"
+	"github.com/rai-project/rai/rai"
 	"github.com/xlab/closer"
 )
 
+// the client of the library runs the lifecycle of the jobs
+var _ rai.Client = (*client.Client)(nil)
+
 func newClient(inputOpts ...client.Option) (*client.Client, error) {
 	if wd, err := filepath.Abs(workingDir); err == nil {
 		workingDir = sanitize(wd)
@@ -32,11 +39,10 @@ func newClient(inputOpts ...client.Option) (*client.Client, error) {
 	}
 
 	if buildFilePath != "" {
-		absPath, err := filepath.Abs(buildFilePath)
-		if err != nil {
+		if absPath, err := filepath.Abs(buildFilePath); err == nil {
 			buildFilePath = absPath
 		}
-		opts = append(opts, client.BuildFilePath(absPath))
+		opts = append(opts, client.BuildFilePath(buildFilePath))
 	}
 
 	opts = extraClientOptions(opts)
@@ -54,7 +60,150 @@ func newClient(inputOpts ...client.Option) (*client.Client, error) {
 	return clnt, err
 }
 
-func runClient(client *client.Client) error {
+// submitJob creates a client for the current options and runs it, keeping
+// track of the job in the local job store
+func submitJob() (err error) {
+	// submit the committed tree instead of the working tree
+	projectDir, gitCommit := workingDir, ""
+	if fromGitRef != "" {
+		snapshot, commit, cleanup, err := gitSnapshot(workingDir, fromGitRef)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		fmt.Printf("Submitting %v (%v) instead of the working tree\n", fromGitRef, commit)
+		workingDir, gitCommit = snapshot, commit
+		defer func() {
+			workingDir = projectDir
+		}()
+	}
+	// keep track of the job locally so that it can be queried
+	// using the `rai job` commands
+	job := newJobRecord()
+	defer job.lock()()
+	dashboard.attach(job)
+	metrics.jobSubmitted()
+	// the build commands are given the trace context of the job
+	trace := newJobTrace(job)
+	activeTraceparent = trace.traceparent()
+	defer func() {
+		activeTraceparent = ""
+		trace.finish(job, err)
+		events.finish(job, err)
+		hooks.jobCompleted(job, err)
+		metrics.jobEnded(job, err)
+	}()
+	job.Directory = projectDir
+	job.GitCommit = gitCommit
+	if len(buildParams) != 0 {
+		job.Params = append([]string{}, buildParams...)
+		sort.Strings(job.Params)
+	}
+	output, err := newJobOutput(job)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	defer func() {
+		reportOutcome(job, output.steps, err)
+		writeResultFile(job, output.steps, output.exitStatus, err)
+		writeJUnitReport(job, output.steps, output.exitStatus, err)
+		notifyCompletion(job, err)
+		notifyWebhook(job, output.steps, output.exitStatus, err)
+	}()
+	// the build file is staged when it is rewritten before submission
+	stagedBuildFile, cleanup, err := stageBuildFile()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	// keep what was submitted so that the job can be exported later
+	if buf, err := redactedBuildFile(); err == nil {
+		job.saveBuildFile(buf)
+	}
+	if manifest, err := computeUploadManifest(workingDir); err == nil {
+		job.saveManifest(manifest)
+		if previous, prevManifest := previousManifest(job); previous != nil {
+			fmt.Printf("%v since job %v\n", manifest.changesSince(prevManifest), previous.ID)
+		}
+	}
+	// the checksums let the build verify that the upload was not corrupted
+	// and identify the content of the submission
+	files, _, err := collectUploadFiles(workingDir)
+	if err != nil {
+		return err
+	}
+	checksums, err := uploadChecksums(files)
+	if err != nil {
+		return err
+	}
+	job.SourceDigest = checksumsDigest(checksums)
+	fmt.Printf("Content hash: %v\n", job.SourceDigest)
+	generated := map[string][]byte{}
+	if verifyUploads() {
+		generated[checksumFileName] = checksums
+	}
+	// the cached directories are uploaded when the cache is hit
+	cache, err := currentBuildCache()
+	if err != nil {
+		return err
+	}
+	if cache != nil {
+		fmt.Println(cache)
+		if cache.isHit() {
+			path, err := cache.archivePath()
+			if err != nil {
+				return err
+			}
+			if generated[cacheArchiveName], err = ioutil.ReadFile(path); err != nil {
+				return err
+			}
+		}
+	}
+	// the directory is staged when files are excluded from the upload
+	stagedDir, cleanupDir, err := stageUploadDirectory(workingDir, generated)
+	if err != nil {
+		return err
+	}
+	defer cleanupDir()
+	opts := output.clientOptions()
+	if stagedDir != "" {
+		opts = append(opts, client.Directory(stagedDir))
+		// the build file must not be looked up in the staged directory
+		if stagedBuildFile == "" && com.IsFile(buildFileLocation()) {
+			stagedBuildFile = buildFileLocation()
+		}
+	}
+	if stagedBuildFile != "" {
+		opts = append(opts, client.BuildFilePath(stagedBuildFile))
+	}
+	// create a new rai client
+	client, err := newClient(opts...)
+	if err != nil {
+		return err
+	}
+	// destroy the client before exiting the function
+	defer client.Disconnect()
+	// Ctrl-C cancels the job from now on
+	ctx, stop := withInterrupt(rootContext, job.ID)
+	defer stop()
+	ctx = withTrace(ctx, trace)
+	// run the client steps
+	if err := runClient(ctx, client, job); err != nil {
+		return err
+	}
+	if err := output.exitStatus.remoteError(); err != nil {
+		return job.fail(err)
+	}
+	if cache != nil && !cache.isHit() {
+		if err := cache.save(job); err != nil {
+			log.WithError(err).Error("unable to cache the build directories")
+		}
+	}
+	return nil
+}
+
+func runClient(ctx context.Context, client rai.Client, job *jobRecord) error {
 
 	if !com.IsDir(workingDir) {
 		fmt.Printf("Error:: the directory specified = %s was not found. "+
@@ -62,38 +211,102 @@ func runClient(client *client.Client) error {
 		return errors.New("Invalid directory")
 	}
 
-	// validate the rai_build.yml file and user privileges
-	if err := client.Validate(); err != nil {
+	// fail early if the server no longer accepts jobs from this client
+	if err := checkMinimumVersion(); err != nil {
+		return err
+	}
+
+	queue := job.Queue
+	if queue == "" {
+		queue = defaultQueueName()
+	}
+	files, _, err := collectUploadFiles(workingDir)
+	if err != nil {
+		return err
+	}
+	if err := checkQueue(queue); err != nil {
 		return err
 	}
+	if err := checkUploadSize(files, queue); err != nil {
+		return err
+	}
+	if err := checkResources(queue); err != nil {
+		return err
+	}
+
+	job.save()
+
+	timeout, err := jobTimeout()
+	if err != nil {
+		return err
+	}
+	artifacts, err := buildFileArtifacts()
+	if err != nil {
+		return err
+	}
+
+	// transient errors of the storage and queue servers are retried
+	retry := currentRetryPolicy()
+
+	// validate the rai_build.yml file and user privileges
+	if err := clientStep(ctx, "Validate", client.Validate)(); err != nil {
+		return job.fail(withFailure(rai.ErrValidation, err))
+	}
+	events.emit(jobEvent{Type: "validated", JobID: job.ID})
 	// authenticate the user, but connecting it to the
 	// various backend and creating session tokens
-	if err := client.Authenticate(); err != nil {
-		return err
+	if err := clientStep(ctx, "Authenticate", client.Authenticate)(); err != nil {
+		return job.fail(withFailure(rai.ErrUnauthorized, err))
 	}
 	// subscribe to the redis queue. the redis queue
 	// is used to gather stdout/stderr from the server
-	if err := client.Subscribe(); err != nil {
-		return err
+	if err := clientStep(ctx, "Subscribe", client.Subscribe)(); err != nil {
+		return job.fail(err)
 	}
 	// upload the user directory to the storage server
 	// the client first creates an archive stream and
 	// uploads that stream to the storage server
-	if err := client.Upload(); err != nil {
-		return err
+	job.setPhase(jobPhaseUploading)
+	dashboard.uploadStarted(totalUploadSize(files))
+	progress := startUploadProgress(totalUploadSize(files))
+	stopUploadHooks := hooks.startUpload(totalUploadSize(files))
+	err = retry.withRetry("Upload", clientStep(ctx, "Upload", client.Upload))
+	stopUploadHooks()
+	progress.stop(err)
+	if err != nil {
+		return job.fail(withFailure(rai.ErrUpload, err))
 	}
-	// publish the job to the queue server
-	if err := client.Publish(); err != nil {
-		return err
+	events.emit(jobEvent{Type: "uploaded", JobID: job.ID, Data: map[string]interface{}{"bytes": totalUploadSize(files)}})
+	metrics.uploaded(totalUploadSize(files))
+	// publish the job to the queue server. A publish that timed out may
+	// have queued the job, so it is only retried if it was refused.
+	if err := retry.withRetryIf("Publish", clientStep(ctx, "Publish", client.Publish), isRefused); err != nil {
+		printQuotaFooter(queue)
+		return job.fail(err)
+	}
+	job.setPhase(jobPhaseQueued)
+	events.emit(jobEvent{Type: "queued", JobID: job.ID, Data: map[string]interface{}{"queue": queue}})
+	hooks.jobQueued(job, queue)
+	if jobs, err := listJobRecords(); err == nil {
+		if estimate := computeQueueStats(queue, jobs).estimate(); estimate != "" {
+			fmt.Println(estimate)
+		}
 	}
 	//
-	if err := client.Connect(); err != nil {
-		return err
+	if err := retry.withRetry("Connect", clientStep(ctx, "Connect", client.Connect)); err != nil {
+		return job.fail(err)
 	}
-	// wait until we receive an end signal
-	if err := client.Wait(); err != nil {
-		return err
+	job.setPhase(jobPhaseRunning)
+	events.emit(jobEvent{Type: "started", JobID: job.ID})
+	// wait until we receive an end signal, or until the job timeout
+	wait := func() error {
+		return waitWithReconnect(ctx, client, job, retry)
 	}
+	if err := waitWithTimeout(wait, timeout); err != nil {
+		return job.fail(err)
+	}
+	job.BuildURL = job.findBuildURL()
+	job.setPhase(jobPhaseFinished)
 	// we record the job into the database.
 	// this is used to store information such as
 	// ranking
@@ -101,5 +314,12 @@ func runClient(client *client.Client) error {
 		log.WithError(err).Error("job not recorded. If this was a submission, it was not recorded.")
 		return err
 	}
+	if err := printArtifacts(job, artifacts); err != nil {
+		return err
+	}
+	if ece408ProjectMode && submitionName != "" {
+		fmt.Printf("Use `rai submission verify --submit %v` to confirm that the submission was recorded.\n", submitionName)
+	}
+	printQuotaFooter(queue)
 	return nil
 }
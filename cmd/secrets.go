@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Unknwon/com"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// secretMask replaces the values of the secrets in the output
+const secretMask = "********"
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretsFilePath returns the file that secrets are read from when they
+// are not set in the environment, client.secrets_file or ~/.rai_secrets
+func secretsFilePath() (string, error) {
+	path := viper.GetString("client.secrets_file")
+	if path == "" {
+		path = "~/.rai_secrets"
+	}
+	return homedir.Expand(path)
+}
+
+// readSecretsFile parses the NAME=value lines of the secrets file. Blank
+// lines and lines starting with # are ignored.
+func readSecretsFile() (map[string]string, error) {
+	secrets := map[string]string{}
+	path, err := secretsFilePath()
+	if err != nil || !com.IsFile(path) {
+		return secrets, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, errors.Errorf("%v can be read by other users, restrict it using chmod 600 %v", path, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			return nil, errors.Errorf("%v: invalid line, expecting NAME=value", path)
+		}
+		secrets[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+	}
+	return secrets, scanner.Err()
+}
+
+// resolveSecrets returns the values of the secrets named by the secrets
+// section of the document, taken from the environment or the secrets file
+func resolveSecrets(doc yaml.MapSlice) (map[string]string, error) {
+	value, ok := getMapSliceEntry(doc, "secrets")
+	if !ok {
+		return nil, nil
+	}
+	var stored map[string]string
+	secrets := map[string]string{}
+	for _, name := range cast.ToStringSlice(value) {
+		if !secretNamePattern.MatchString(name) {
+			return nil, errors.Errorf("invalid secret name %v, expecting an environment variable name", name)
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			secrets[name] = value
+			continue
+		}
+		if stored == nil {
+			var err error
+			if stored, err = readSecretsFile(); err != nil {
+				return nil, err
+			}
+		}
+		value, ok := stored[name]
+		if !ok {
+			path, _ := secretsFilePath()
+			return nil, errors.Errorf("the secret %v is not set, set it in the environment or in %v", name, path)
+		}
+		secrets[name] = value
+	}
+	return secrets, nil
+}
+
+// buildFileSecrets returns the values of the secrets of the build file
+func buildFileSecrets() (map[string]string, error) {
+	path := buildFileLocation()
+	if !com.IsFile(path) {
+		return nil, nil
+	}
+	_, doc, _, err := readBuildDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	return resolveSecrets(doc)
+}
+
+// maskSecrets replaces the values of the secrets within the content
+func maskSecrets(buf []byte, secrets map[string]string) []byte {
+	for _, value := range secrets {
+		if value != "" {
+			buf = bytes.Replace(buf, []byte(value), []byte(secretMask), -1)
+		}
+	}
+	return buf
+}
+
+// redactSecrets, when set, exports the mask instead of the values of the
+// secrets so that the build file can be shown or kept
+var redactSecrets bool
+
+// redactedBuildFile returns the content of the build file as it is
+// submitted with the job, with the values of the secrets masked
+func redactedBuildFile() ([]byte, error) {
+	redactSecrets = true
+	resolvingForRecord = true
+	defer func() {
+		redactSecrets = false
+		resolvingForRecord = false
+	}()
+	return resolvedBuildFile()
+}
+
+// secretsFilter masks the values of the secrets in the output written to
+// it. Partial lines are held until they are complete so that a value split
+// across writes is masked.
+type secretsFilter struct {
+	sync.Mutex
+	w       io.Writer
+	secrets map[string]string
+	pending []byte
+}
+
+func (f *secretsFilter) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.pending = append(f.pending, p...)
+	idx := bytes.LastIndexAny(f.pending, "\r\n")
+	if idx < 0 {
+		return len(p), nil
+	}
+	if _, err := f.w.Write(maskSecrets(f.pending[:idx+1], f.secrets)); err != nil {
+		return 0, err
+	}
+	f.pending = append([]byte{}, f.pending[idx+1:]...)
+	return len(p), nil
+}
+
+// Flush writes the partial line that is held
+func (f *secretsFilter) Flush() error {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.pending) == 0 {
+		return nil
+	}
+	_, err := f.w.Write(maskSecrets(f.pending, f.secrets))
+	f.pending = nil
+	return err
+}
+
+// withoutSecrets masks the values of the secrets in the output written to
+// w, if there are any
+func withoutSecrets(w io.Writer, secrets map[string]string) io.Writer {
+	if len(secrets) == 0 {
+		return w
+	}
+	return &secretsFilter{w: w, secrets: secrets}
+}
+
+func init() {
+	// the secrets are exported in the shell of every build command. They
+	// are only part of the build file that is sent with the job request.
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		secrets, err := resolveSecrets(doc)
+		if err != nil {
+			return nil, false, err
+		}
+		if _, ok := getMapSliceEntry(doc, "secrets"); !ok {
+			return doc, false, nil
+		}
+		doc = deleteMapSliceEntry(doc, "secrets")
+		build := buildCommands(doc)
+		if len(secrets) == 0 || len(build) == 0 {
+			return doc, true, nil
+		}
+		names := []string{}
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		exports := []string{}
+		for _, name := range names {
+			value := shellQuote(secrets[name])
+			if redactSecrets {
+				value = secretMask
+			}
+			exports = append(exports, fmt.Sprintf("export %v=%v", name, value))
+		}
+		prefix := strings.Join(exports, "; ") + "; "
+		for ii, command := range build {
+			build[ii] = prefix + command
+		}
+		return setBuildCommands(doc, build), true, nil
+	})
+}
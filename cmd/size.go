@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/rai"
+	"github.com/spf13/cobra"
+)
+
+var sizeTop int
+
+// sizeEntry is a file or directory and the bytes it contributes to the
+// upload
+type sizeEntry struct {
+	Path string
+	Size int64
+}
+
+// largestUploadEntries returns the n largest files and directories
+func largestUploadEntries(files []uploadFile, n int) ([]sizeEntry, []sizeEntry) {
+	largestFiles := make([]sizeEntry, 0, len(files))
+	dirSizes := map[string]int64{}
+	for _, file := range files {
+		largestFiles = append(largestFiles, sizeEntry{file.Path, file.Size})
+		for dir := path.Dir(file.Path); dir != "."; dir = path.Dir(dir) {
+			dirSizes[dir] += file.Size
+		}
+	}
+	largestDirs := make([]sizeEntry, 0, len(dirSizes))
+	for dir, size := range dirSizes {
+		largestDirs = append(largestDirs, sizeEntry{dir, size})
+	}
+
+	top := func(entries []sizeEntry) []sizeEntry {
+		sort.Slice(entries, func(ii, jj int) bool {
+			if entries[ii].Size == entries[jj].Size {
+				return entries[ii].Path < entries[jj].Path
+			}
+			return entries[ii].Size > entries[jj].Size
+		})
+		if len(entries) > n {
+			entries = entries[:n]
+		}
+		return entries
+	}
+	return top(largestFiles), top(largestDirs)
+}
+
+// suggestRaiIgnore proposes .raiignore entries for the files and
+// directories that make up at least a tenth of the upload
+func suggestRaiIgnore(files, dirs []sizeEntry, total int64) []string {
+	suggestions := []string{}
+	suggestedDirs := []string{}
+	covered := func(p string) bool {
+		for _, dir := range suggestedDirs {
+			if p == dir || strings.HasPrefix(p, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	for _, dir := range dirs {
+		if dir.Size*10 >= total && !covered(dir.Path) {
+			suggestedDirs = append(suggestedDirs, dir.Path)
+			suggestions = append(suggestions, "/"+dir.Path+"/")
+		}
+	}
+	for _, file := range files {
+		if file.Size*10 >= total && !covered(file.Path) {
+			suggestions = append(suggestions, "/"+file.Path)
+		}
+	}
+	return suggestions
+}
+
+// printSizeAudit prints the largest files and directories of the upload and
+// the .raiignore entries that would shrink it the most
+func printSizeAudit(files []uploadFile, n int) {
+	total := totalUploadSize(files)
+	largestFiles, largestDirs := largestUploadEntries(files, n)
+
+	render := func(title string, entries []sizeEntry) {
+		if len(entries) == 0 {
+			return
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{title, "Size", "Share"})
+		for _, entry := range entries {
+			share := 0.0
+			if total > 0 {
+				share = 100 * float64(entry.Size) / float64(total)
+			}
+			table.Append([]string{entry.Path, humanize.Bytes(uint64(entry.Size)), fmt.Sprintf("%.0f%%", share)})
+		}
+		table.Render()
+	}
+
+	fmt.Printf("The directory contains %v files totaling %v.\n\n", len(files), humanize.Bytes(uint64(total)))
+	render("Directory", largestDirs)
+	render("File", largestFiles)
+
+	// suggestions are based on everything, not only the top n
+	allFiles, allDirs := largestUploadEntries(files, len(files))
+	if suggestions := suggestRaiIgnore(allFiles, allDirs, total); len(suggestions) > 0 {
+		fmt.Println()
+		fmt.Println("Consider adding these entries to .raiignore:")
+		for _, s := range suggestions {
+			fmt.Println("  " + s)
+		}
+	}
+}
+
+// uploadSizeLimit returns the maximum upload size of the queue, or zero if
+// the queue has no limit
+func uploadSizeLimit(queueName string) (uint64, error) {
+	queue, err := findQueue(queueName)
+	if err != nil || queue == nil || queue.MaxUploadSize == "" {
+		return 0, err
+	}
+	limit, err := humanize.ParseBytes(queue.MaxUploadSize)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid max_upload_size for the %v queue", queueName)
+	}
+	return limit, nil
+}
+
+// checkUploadSize fails with a size audit when the files exceed the upload
+// size limit of the queue
+func checkUploadSize(files []uploadFile, queueName string) error {
+	limit, err := uploadSizeLimit(queueName)
+	if err != nil || limit == 0 {
+		return err
+	}
+	total := uint64(totalUploadSize(files))
+	if total <= limit {
+		return nil
+	}
+	printSizeAudit(files, 10)
+	fmt.Println()
+	return &rai.ErrTarballTooLarge{Queue: queueName, Limit: limit, Actual: total}
+}
+
+var sizeCmd = &cobra.Command{
+	Use:   "size",
+	Short: "Shows the largest files and directories that would be uploaded.",
+	Long: `Shows the largest files and directories that would be uploaded, after applying the ` +
+		`.raiignore files, and suggests .raiignore entries for the ones that dominate the upload.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, exclusions, err := collectUploadFiles(workingDir)
+		if err != nil {
+			return err
+		}
+		printSizeAudit(files, sizeTop)
+		if exclusions.Files > 0 {
+			fmt.Println()
+			fmt.Println(exclusions)
+		}
+		limit, err := uploadSizeLimit(defaultQueueName())
+		if err != nil {
+			return err
+		}
+		if limit > 0 {
+			fmt.Printf("\nThe upload limit of the %v queue is %v.\n", defaultQueueName(), humanize.Bytes(limit))
+		}
+		return nil
+	},
+}
+
+func init() {
+	sizeCmd.Flags().IntVarP(&sizeTop, "top", "n", 10, "Number of files and directories to show.")
+	RootCmd.AddCommand(sizeCmd)
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// statsInterval is how often resource usage is sampled while the build
+// commands run. Sampling is disabled when it is zero.
+var statsInterval time.Duration
+
+// statsMarker prefixes the resource usage samples in the job output
+const statsMarker = "RAI_STATS"
+
+// statsSampler prints a sample of the cgroup cpu and memory usage and of the
+// utilization of the first GPU
+const statsSampler = `while true; do ` +
+	`echo "` + statsMarker + ` $(date +%%s) ` +
+	`$(cat /sys/fs/cgroup/cpuacct/cpuacct.usage 2>/dev/null || echo 0) ` +
+	`$(cat /sys/fs/cgroup/memory/memory.usage_in_bytes 2>/dev/null || echo 0) ` +
+	`$(nvidia-smi --query-gpu=utilization.gpu,memory.used --format=csv,noheader,nounits 2>/dev/null | head -n 1 | tr -d ' ')"; ` +
+	`sleep %d; done`
+
+// withStatsSampler runs the sampler in the background for the duration of
+// the command, preserving the exit status of the command
+func withStatsSampler(command string, interval time.Duration) string {
+	seconds := int(interval / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return fmt.Sprintf(statsSampler, seconds) + " &\n" +
+		"RAI_STATS_PID=$!\n" +
+		command + "\n" +
+		"RAI_STATUS=$?\n" +
+		"kill $RAI_STATS_PID 2>/dev/null\n" +
+		"(exit $RAI_STATUS)"
+}
+
+func init() {
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		if statsInterval <= 0 {
+			return doc, false, nil
+		}
+		build := buildCommands(doc)
+		for ii, command := range build {
+			build[ii] = withStatsSampler(command, statsInterval)
+		}
+		return setBuildCommands(doc, build), true, nil
+	})
+}
+
+// statsSample is a resource usage sample taken while a job is running
+type statsSample struct {
+	Time time.Time
+	// CPUTime is the total cpu time consumed by the job
+	CPUTime time.Duration
+	// Memory is the memory used by the job in bytes
+	Memory uint64
+	// GPUUtilization is the utilization of the GPU in percent, or -1 if
+	// the job has no GPU
+	GPUUtilization int
+	// GPUMemory is the memory used on the GPU in bytes
+	GPUMemory uint64
+}
+
+// parseStatsSample parses a sample line printed by the sampler
+func parseStatsSample(line string) (statsSample, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != statsMarker {
+		return statsSample{}, false
+	}
+	sample := statsSample{
+		Time:           time.Unix(cast.ToInt64(fields[1]), 0),
+		CPUTime:        time.Duration(cast.ToInt64(fields[2])),
+		Memory:         cast.ToUint64(fields[3]),
+		GPUUtilization: -1,
+	}
+	if len(fields) > 4 {
+		gpu := strings.Split(fields[4], ",")
+		if len(gpu) == 2 {
+			sample.GPUUtilization = cast.ToInt(gpu[0])
+			sample.GPUMemory = cast.ToUint64(gpu[1]) << 20
+		}
+	}
+	return sample, true
+}
+
+// readStatsSamples returns the samples found in the job output
+func readStatsSamples(r io.Reader) ([]statsSample, error) {
+	var samples []statsSample
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if sample, ok := parseStatsSample(scanner.Text()); ok {
+			samples = append(samples, sample)
+		}
+	}
+	return samples, scanner.Err()
+}
+
+// hiddenMarkers prefix the lines of the job output that are read by the
+// client rather than shown: the resource usage samples. The exit status of
+// the build command that failed is dropped by exitStatusRecorder.
+var hiddenMarkers = []string{statsMarker + " "}
+
+// isHiddenLine returns true if the line starts with one of the markers,
+// or if it is partial and may still do so
+func isHiddenLine(line []byte, partial bool) bool {
+	for _, marker := range hiddenMarkers {
+		if bytes.HasPrefix(line, []byte(marker)) || (partial && bytes.HasPrefix([]byte(marker), line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// statsFilter drops the sample lines from the output
+// written to the terminal. Partial lines are held until they are complete.
+type statsFilter struct {
+	w       io.Writer
+	pending []byte
+}
+
+func (f *statsFilter) Write(p []byte) (int, error) {
+	f.pending = append(f.pending, p...)
+	for {
+		idx := bytes.IndexByte(f.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		line := f.pending[:idx+1]
+		if !isHiddenLine(line, false) {
+			if _, err := f.w.Write(line); err != nil {
+				return 0, err
+			}
+		}
+		f.pending = f.pending[idx+1:]
+	}
+	// flush partial lines that can not be hidden
+	if len(f.pending) > 0 && !isHiddenLine(f.pending, true) {
+		if _, err := f.w.Write(f.pending); err != nil {
+			return 0, err
+		}
+		f.pending = f.pending[:0]
+	}
+	return len(p), nil
+}
+
+// withoutStats drops the sample lines from the output written to w when
+// resource usage is sampled
+func withoutStats(w io.Writer) io.Writer {
+	if statsInterval <= 0 {
+		return w
+	}
+	return &statsFilter{w: w}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var statsSince time.Duration
+
+// usageSummary aggregates the jobs of a group
+type usageSummary struct {
+	Name       string
+	Jobs       int
+	Finished   int
+	Failed     int
+	GPUTime    time.Duration
+	totalWait  time.Duration
+	waitCount  int
+	isGPUQueue func(string) bool
+}
+
+func (s *usageSummary) add(job *jobRecord) {
+	s.Jobs++
+	switch job.Phase {
+	case jobPhaseFinished:
+		s.Finished++
+	case jobPhaseFailed:
+		s.Failed++
+	}
+	if !job.QueuedAt.IsZero() && !job.StartedAt.IsZero() {
+		s.totalWait += job.StartedAt.Sub(job.QueuedAt)
+		s.waitCount++
+	}
+	if !job.StartedAt.IsZero() && !job.FinishedAt.IsZero() && s.isGPUQueue(job.Queue) {
+		s.GPUTime += job.FinishedAt.Sub(job.StartedAt)
+	}
+}
+
+// successRate is the percentage of completed jobs that finished
+func (s *usageSummary) successRate() string {
+	if s.Finished+s.Failed == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(s.Finished)/float64(s.Finished+s.Failed))
+}
+
+func (s *usageSummary) averageWait() string {
+	if s.waitCount == 0 {
+		return "-"
+	}
+	return (s.totalWait / time.Duration(s.waitCount)).Round(time.Second).String()
+}
+
+func (s *usageSummary) row() []string {
+	return []string{
+		s.Name,
+		fmt.Sprint(s.Jobs),
+		s.successRate(),
+		fmt.Sprintf("%.1f", s.GPUTime.Minutes()),
+		s.averageWait(),
+	}
+}
+
+// renderUsageSummaries prints a table with a row per group
+func renderUsageSummaries(title string, groups map[string]*usageSummary) {
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{title, "Jobs", "Success", "GPU Minutes", "Average Wait"})
+	for _, name := range names {
+		table.Append(groups[name].row())
+	}
+	table.Render()
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarizes the jobs you submitted over a time window.",
+	Long: `Summarizes the jobs submitted from this machine within --since: the number of jobs, the ` +
+		`share that finished successfully, the GPU minutes used and the average queue wait, broken ` +
+		`down by queue and submission tag.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobs, err := listJobRecords()
+		if err != nil {
+			return err
+		}
+		queues, err := configuredQueues()
+		if err != nil {
+			return err
+		}
+		isGPUQueue := func(name string) bool {
+			if name == "" {
+				name = defaultQueueName()
+			}
+			for _, queue := range queues {
+				if queue.Name == name {
+					return queue.GPU != ""
+				}
+			}
+			return false
+		}
+		newSummary := func(name string) *usageSummary {
+			return &usageSummary{Name: name, isGPUQueue: isGPUQueue}
+		}
+
+		total := newSummary("Total")
+		byQueue := map[string]*usageSummary{}
+		byTag := map[string]*usageSummary{}
+		for _, job := range jobs {
+			if statsSince > 0 && time.Since(job.CreatedAt) > statsSince {
+				break
+			}
+			total.add(job)
+
+			queue := job.Queue
+			if queue == "" {
+				queue = defaultQueueName()
+			}
+			if byQueue[queue] == nil {
+				byQueue[queue] = newSummary(queue)
+			}
+			byQueue[queue].add(job)
+
+			tag := job.SubmissionTag
+			if tag == "" {
+				tag = "(none)"
+			}
+			if byTag[tag] == nil {
+				byTag[tag] = newSummary(tag)
+			}
+			byTag[tag].add(job)
+		}
+
+		if total.Jobs == 0 {
+			fmt.Println("No jobs were found.")
+			return nil
+		}
+
+		fmt.Printf("%-14s %v\n", "Jobs:", total.Jobs)
+		fmt.Printf("%-14s %v\n", "Success rate:", total.successRate())
+		fmt.Printf("%-14s %.1f\n", "GPU minutes:", total.GPUTime.Minutes())
+		fmt.Printf("%-14s %v\n", "Average wait:", total.averageWait())
+		renderUsageSummaries("Queue", byQueue)
+		if len(byTag) > 1 || byTag["(none)"] == nil {
+			renderUsageSummaries("Submission", byTag)
+		}
+		return nil
+	},
+}
+
+func init() {
+	statsCmd.Flags().DurationVar(&statsSince, "since", 7*24*time.Hour, "Only include jobs created within this duration (0 includes all jobs).")
+	RootCmd.AddCommand(statsCmd)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// splitStreams writes the stdout and stderr of the job to separate files
+var splitStreams bool
+
+// stderrPrefix marks the lines of stderr when colors are disabled
+const stderrPrefix = "[stderr] "
+
+// stderrLabel tells the lines of stderr apart from those of stdout on the
+// terminal, by printing them in the stderr color of the theme or, without
+// colors, behind a prefix
+type stderrLabel struct {
+	sync.Mutex
+	w io.Writer
+	// midLine is set when the last write did not end a line
+	midLine bool
+}
+
+func (l *stderrLabel) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	buf := new(bytes.Buffer)
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		text := bytes.TrimSuffix(line, []byte("\n"))
+		switch {
+		case color.NoColor && !l.midLine:
+			buf.WriteString(stderrPrefix)
+			buf.Write(text)
+		case color.NoColor:
+			buf.Write(text)
+		default:
+			buf.WriteString(themeString(themeStderr, string(text)))
+		}
+		l.midLine = len(text) == len(line)
+		if !l.midLine {
+			buf.WriteByte('\n')
+		}
+	}
+	if _, err := l.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// withStderrLabel labels the stderr of the job written to the terminal
+func withStderrLabel(w io.Writer) io.Writer {
+	return &stderrLabel{w: w}
+}
+
+// splitStreamPaths returns the files that stdout and stderr are written to
+// with --split-streams. They are named after the log file when there is one
+// and after the job otherwise.
+func splitStreamPaths(job *jobRecord) (string, string, error) {
+	path, err := logFilePath(job)
+	if err != nil {
+		return "", "", err
+	}
+	if path == "" {
+		path = filepath.Join(workingDir, job.ID+".log")
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	if ext == "" {
+		ext = ".log"
+	}
+	return base + ".stdout" + ext, base + ".stderr" + ext, nil
+}
+
+// createSplitStreams opens the files of stdout and stderr if the streams
+// are split
+func createSplitStreams(job *jobRecord) (*plainLogFile, *plainLogFile, error) {
+	if !splitStreams {
+		return nil, nil, nil
+	}
+	stdoutPath, stderrPath, err := splitStreamPaths(job)
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := openPlainLogFile(stdoutPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	stderr, err := openPlainLogFile(stderrPath)
+	if err != nil {
+		stdout.Close()
+		return nil, nil, err
+	}
+	return stdout, stderr, nil
+}
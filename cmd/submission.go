@@ -0,0 +1,115 @@
+// +build ece408ProjectMode
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/rai-project/client"
+	"github.com/rai-project/config"
+	"github.com/rai-project/database/mongodb"
+	"github.com/spf13/cobra"
+	upper "upper.io/db.v3"
+)
+
+// submissionCmd groups the commands that inspect the recorded submissions
+var submissionCmd = &cobra.Command{}
+
+var submissionListCmd = &cobra.Command{}
+
+// findTeamSubmissions returns the submissions recorded for the team of the
+// current user, ordered from the oldest to the most recent
+func findTeamSubmissions() (string, client.Ece408JobResponseBodys, error) {
+	prof, err := loadProfile()
+	if err != nil {
+		return "", nil, err
+	}
+
+	tname, err := client.FindTeamName(prof.Info().Username)
+	if err != nil {
+		return "", nil, err
+	}
+
+	db, err := mongodb.NewDatabase(config.App.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer db.Close()
+
+	col, err := client.NewEce408JobResponseBodyCollection(db)
+	if err != nil {
+		return "", nil, err
+	}
+	defer col.Close()
+
+	cond := upper.And(
+		upper.Cond{"inferences.0 $exists": "true"},
+		upper.Cond{
+			"is_submission": true,
+			"teamname":      tname,
+		},
+	)
+
+	var jobs client.Ece408JobResponseBodys
+	if err := col.Find(cond, 0, 0, &jobs); err != nil {
+		return "", nil, err
+	}
+	sort.Slice(jobs, func(ii, jj int) bool {
+		return jobs[ii].CreatedAt.Before(jobs[jj].CreatedAt)
+	})
+	return tname, jobs, nil
+}
+
+func init() {
+	if !ece408ProjectMode {
+		return
+	}
+	submissionCmd = &cobra.Command{
+		Use:          "submission",
+		Short:        "Inspect the submissions recorded for your team.",
+		SilenceUsage: true,
+	}
+	submissionListCmd = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "Lists the submissions recorded for each milestone.",
+		Long: `Lists the submissions recorded for your team for each milestone. ` +
+			`The most recent submission of a milestone, marked with a *, is the one that is graded.`,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tname, jobs, err := findTeamSubmissions()
+			if err != nil {
+				return err
+			}
+			if len(jobs) == 0 {
+				fmt.Println("No submissions were recorded for team " + tname + ".")
+				return nil
+			}
+
+			// the last submission of every milestone is the one that counts
+			latest := map[string]int{}
+			for ii, job := range jobs {
+				latest[job.SubmissionTag] = ii
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Latest", "Milestone", "Submitted At", "Submitted By", "Project"})
+			for ii, job := range jobs {
+				mark := ""
+				if latest[job.SubmissionTag] == ii {
+					mark = "*"
+				}
+				table.Append([]string{mark, job.SubmissionTag, job.CreatedAt.String(), job.Username, job.ProjectURL})
+			}
+			fmt.Println("Submissions recorded for team: " + tname)
+			table.Render()
+			return nil
+		},
+	}
+	submissionCmd.AddCommand(submissionListCmd)
+	RootCmd.AddCommand(submissionCmd)
+}
@@ -0,0 +1,79 @@
+// +build ece408ProjectMode
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var submissionVerifyCmd = &cobra.Command{}
+
+// projectDigest computes the sha256 digest of the uploaded project
+func projectDigest(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("bad http status from %s: %v", url, resp.Status)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func init() {
+	if !ece408ProjectMode {
+		return
+	}
+	submissionVerifyCmd = &cobra.Command{
+		Use:          "verify",
+		Short:        "Verifies that a submission was recorded.",
+		Long:         `Verifies that a submission was recorded for the milestone given by --submit and prints a receipt for it.`,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if submitionName == "" {
+				return errors.New("the milestone to verify must be specified using --submit")
+			}
+			tname, jobs, err := findTeamSubmissions()
+			if err != nil {
+				return err
+			}
+			found := -1
+			for ii, job := range jobs {
+				if job.SubmissionTag == submitionName {
+					found = ii
+				}
+			}
+			if found == -1 {
+				return errors.Errorf("no %v submission was recorded for team %v", submitionName, tname)
+			}
+			job := jobs[found]
+
+			digest, err := projectDigest(job.ProjectURL)
+			if err != nil {
+				digest = "unavailable (" + err.Error() + ")"
+			}
+
+			fmt.Printf("A %v submission was recorded for team %v.\n\n", submitionName, tname)
+			fmt.Printf("%-14s %v\n", "Milestone:", job.SubmissionTag)
+			fmt.Printf("%-14s %v\n", "Submitted at:", job.CreatedAt.String())
+			fmt.Printf("%-14s %v\n", "Submitted by:", job.Username)
+			fmt.Printf("%-14s %v\n", "Project:", job.ProjectURL)
+			fmt.Printf("%-14s %v\n", "SHA256:", digest)
+			return nil
+		},
+	}
+	submissionCmd.AddCommand(submissionVerifyCmd)
+}
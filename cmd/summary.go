@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acarl005/stripansi"
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	// quietOutput hides the output of the job and the progress of the
+	// submission, printing only the job id and its outcome
+	quietOutput bool
+	// summaryOutput prints a table of the steps of the job once it ends
+	summaryOutput bool
+)
+
+// resultOutput is where the outcome of the job is printed. It stays stdout
+// when the rest of the text output is hidden by --quiet.
+var resultOutput io.Writer = os.Stdout
+
+// setQuietOutput discards the text output if --quiet is set, keeping
+// stdout, or stderr when events are written to stdout, for the outcome
+func setQuietOutput() {
+	resultOutput = os.Stdout
+	if !quietOutput {
+		return
+	}
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout = devNull
+	}
+}
+
+// jobStep is a build command and when the server started running it
+type jobStep struct {
+	Command string
+	Start   time.Time
+}
+
+// stepRecorder notes when the server starts every build command from the
+// lines of the job output
+type stepRecorder struct {
+	sync.Mutex
+	steps   []jobStep
+	pending []byte
+}
+
+func (r *stepRecorder) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.pending = append(r.pending, p...)
+	for {
+		idx := bytes.IndexByte(r.pending, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimSpace(stripansi.Strip(string(r.pending[:idx])))
+		r.pending = r.pending[idx+1:]
+		if strings.HasPrefix(line, stepStartPrefix) {
+			r.steps = append(r.steps, jobStep{Command: strings.TrimPrefix(line, stepStartPrefix), Start: time.Now()})
+		}
+	}
+}
+
+// durations returns the steps that were recorded along with how long each
+// of them ran, given when the last one ended
+func (r *stepRecorder) durations(end time.Time) ([]jobStep, []time.Duration) {
+	r.Lock()
+	steps := append([]jobStep{}, r.steps...)
+	r.Unlock()
+	durations := make([]time.Duration, len(steps))
+	for ii, step := range steps {
+		stepEnd := end
+		if ii+1 < len(steps) {
+			stepEnd = steps[ii+1].Start
+		}
+		durations[ii] = stepEnd.Sub(step.Start)
+	}
+	return steps, durations
+}
+
+// withStepRecorder adds the recording of the steps to the output, if a
+// summary is printed
+func withStepRecorder(w io.Writer, recorder *stepRecorder) io.Writer {
+	if recorder == nil {
+		return w
+	}
+	return io.MultiWriter(w, recorder)
+}
+
+// printJobSummary prints the duration of every step, the exit status and
+// the artifacts of the job
+func printJobSummary(w io.Writer, job *jobRecord, recorder *stepRecorder, jobErr error) {
+	end := job.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Step", "Command", "Duration"})
+	table.SetAutoWrapText(false)
+	steps, durations := recorder.durations(end)
+	for ii, step := range steps {
+		command := step.Command
+		if len(command) > 60 {
+			command = command[:57] + "..."
+		}
+		table.Append([]string{fmt.Sprint(ii + 1), command, durations[ii].Round(100 * time.Millisecond).String()})
+	}
+	table.Render()
+
+	fmt.Fprintf(w, "Job %v %v with exit status %v", job.ID, job.Phase, ExitCode(jobErr))
+	if !job.StartedAt.IsZero() {
+		fmt.Fprintf(w, " after %v", end.Sub(job.StartedAt).Round(time.Second))
+	}
+	fmt.Fprintln(w)
+	artifacts, _ := buildFileArtifacts()
+	if len(artifacts) != 0 {
+		fmt.Fprintf(w, "Artifacts: %v\n", strings.Join(artifacts, ", "))
+	}
+	if job.BuildURL != "" {
+		fmt.Fprintf(w, "Build directory: %v\n", job.BuildURL)
+	}
+}
+
+// reportOutcome prints the summary of the job if --summary is set and the
+// job id and its outcome if --quiet is set
+func reportOutcome(job *jobRecord, recorder *stepRecorder, jobErr error) {
+	if summaryOutput && recorder != nil {
+		printJobSummary(resultOutput, job, recorder, jobErr)
+		return
+	}
+	if !quietOutput {
+		return
+	}
+	if jobErr != nil {
+		fmt.Fprintf(resultOutput, "%v %v: %v\n", job.ID, job.Phase, jobErr)
+		return
+	}
+	fmt.Fprintf(resultOutput, "%v %v\n", job.ID, job.Phase)
+}
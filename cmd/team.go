@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// teamCmd groups the commands that manage the team of the user. The team
+// is stored in the profile and is sent along with every submission.
+var teamCmd = &cobra.Command{
+	Use:          "team",
+	Short:        "Manage the team that your submissions are recorded for.",
+	SilenceUsage: true,
+}
+
+// teamJoinCode encodes the team so that it can be shared with the other
+// members of the team
+func teamJoinCode(id, name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id + "/" + name))
+}
+
+func parseTeamJoinCode(code string) (string, string, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(code))
+	if err != nil {
+		return "", "", errors.Errorf("the team code %v is not valid", code)
+	}
+	parts := strings.SplitN(string(buf), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.Errorf("the team code %v is not valid", code)
+	}
+	return parts[0], parts[1], nil
+}
+
+// setProfileTeam records the team within the profile
+func setProfileTeam(id, name string) error {
+	entries, err := readProfileEntries()
+	if err != nil {
+		return err
+	}
+	team := yaml.MapSlice{
+		{Key: "id", Value: id},
+		{Key: "name", Value: name},
+	}
+	return writeProfileEntries(setMapSliceEntry(entries, "team", team))
+}
+
+// profileTeam returns the id and name of the team recorded in the profile
+func profileTeam() (string, string, error) {
+	entries, err := readProfileEntries()
+	if err != nil {
+		return "", "", err
+	}
+	for _, entry := range entries {
+		if entry.Key != "team" {
+			continue
+		}
+		team, ok := entry.Value.(yaml.MapSlice)
+		if !ok {
+			return "", cast.ToString(entry.Value), nil
+		}
+		var id, name string
+		for _, item := range team {
+			switch item.Key {
+			case "id":
+				id = cast.ToString(item.Value)
+			case "name":
+				name = cast.ToString(item.Value)
+			}
+		}
+		return id, name, nil
+	}
+	return "", "", nil
+}
+
+var teamCreateCmd = &cobra.Command{
+	Use:          "create <name>",
+	Short:        "Creates a team and makes you a member of it.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.TrimSpace(args[0])
+		if name == "" || strings.Contains(name, "/") {
+			return errors.Errorf("%v is not a valid team name", args[0])
+		}
+		id := newRandomID()
+		if err := setProfileTeam(id, name); err != nil {
+			return err
+		}
+		fmt.Printf("Created team %v. Share the following code with your teammates so that they can join using `rai team join <code>`:\n\n", name)
+		fmt.Println("    " + teamJoinCode(id, name))
+		return nil
+	},
+}
+
+var teamJoinCmd = &cobra.Command{
+	Use:          "join <code>",
+	Short:        "Joins the team given by the code that a teammate shared.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, name, err := parseTeamJoinCode(args[0])
+		if err != nil {
+			return err
+		}
+		if err := setProfileTeam(id, name); err != nil {
+			return err
+		}
+		fmt.Printf("Joined team %v.\n", name)
+		return nil
+	},
+}
+
+var teamListCmd = &cobra.Command{
+	Use:          "list",
+	Aliases:      []string{"ls"},
+	Short:        "Prints your team and its members.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, name, err := profileTeam()
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			fmt.Println("You are not a member of a team. Use `rai team create` or `rai team join` to join one.")
+			return nil
+		}
+		fmt.Printf("%-8s %v\n", "Team:", name)
+		if id != "" {
+			fmt.Printf("%-8s %v\n", "Code:", teamJoinCode(id, name))
+		}
+		members, err := teamMembers(name)
+		if err != nil {
+			return err
+		}
+		if len(members) != 0 {
+			fmt.Printf("%-8s %v\n", "Members:", strings.Join(members, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	teamCmd.AddCommand(teamCreateCmd)
+	teamCmd.AddCommand(teamJoinCmd)
+	teamCmd.AddCommand(teamListCmd)
+	RootCmd.AddCommand(teamCmd)
+}
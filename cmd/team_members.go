@@ -0,0 +1,44 @@
+// +build ece408ProjectMode
+
+package cmd
+
+import (
+	"sort"
+
+	"github.com/rai-project/client"
+	"github.com/rai-project/config"
+	"github.com/rai-project/database/mongodb"
+	upper "upper.io/db.v3"
+)
+
+// teamMembers returns the users that have recorded jobs for the team
+func teamMembers(name string) ([]string, error) {
+	db, err := mongodb.NewDatabase(config.App.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	col, err := client.NewEce408JobResponseBodyCollection(db)
+	if err != nil {
+		return nil, err
+	}
+	defer col.Close()
+
+	var jobs client.Ece408JobResponseBodys
+	if err := col.Find(upper.Cond{"teamname": name}, 0, 0, &jobs); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	members := []string{}
+	for _, job := range jobs {
+		if job.Username == "" || seen[job.Username] {
+			continue
+		}
+		seen[job.Username] = true
+		members = append(members, job.Username)
+	}
+	sort.Strings(members)
+	return members, nil
+}
@@ -0,0 +1,8 @@
+// +build !ece408ProjectMode
+
+package cmd
+
+// the team members are only known to the project mode database
+func teamMembers(name string) ([]string, error) {
+	return nil, nil
+}
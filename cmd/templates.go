@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	log "github.com/rai-project/logger"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// buildTemplate is a starting point for a rai_build.yml file. Besides the
+// builtin templates, courses can list templates in the client.templates
+// section of the configuration or host a catalog at client.templates_url.
+type buildTemplate struct {
+	Name        string   `mapstructure:"name" yaml:"name"`
+	Description string   `mapstructure:"description" yaml:"description"`
+	Queue       string   `mapstructure:"queue" yaml:"queue,omitempty"`
+	Image       string   `mapstructure:"image" yaml:"image"`
+	GPU         bool     `mapstructure:"gpu" yaml:"gpu,omitempty"`
+	Commands    []string `mapstructure:"commands" yaml:"commands"`
+	// Source is where the template was found
+	Source string `mapstructure:"-" yaml:"-"`
+}
+
+var buildTemplates = []buildTemplate{
+	{
+		Name:        "cuda",
+		Description: "Compile and run a CUDA program with nvcc",
+		Image:       "nvidia/cuda:9.2-devel",
+		GPU:         true,
+		Commands: []string{
+			"nvidia-smi",
+			"nvcc -O3 -o /build/main /src/main.cu",
+			"/build/main",
+		},
+	},
+	{
+		Name:        "cmake",
+		Description: "Configure the project with CMake and build it with make",
+		Image:       "nvidia/cuda:9.2-devel",
+		GPU:         true,
+		Commands: []string{
+			"cmake /src",
+			"make",
+		},
+	},
+	{
+		Name:        "make",
+		Description: "Build the project using the Makefile in the project directory",
+		Image:       "ubuntu:18.04",
+		Commands: []string{
+			"cp -r /src/. /build",
+			"make",
+		},
+	},
+	{
+		Name:        "empty",
+		Description: "A build file with a single placeholder command",
+		Image:       "ubuntu:18.04",
+		Commands: []string{
+			`echo "Building project"`,
+		},
+	},
+}
+
+const buildFileTemplate = `rai:
+  version: 0.2
+  image: {{ .Image }}
+resources:
+  cpu:
+    architecture: {{ .Architecture }}
+{{- if .GPU }}
+  gpu:
+    architecture: {{ .GPUArchitecture }}
+    count: 1
+{{- end }}
+  network: false
+commands:
+  build:
+{{- range .Commands }}
+    - {{ quote . }}
+{{- end }}
+`
+
+// buildFileParams are the values that a template is rendered with
+type buildFileParams struct {
+	buildTemplate
+	Architecture    string
+	GPUArchitecture string
+}
+
+// remoteBuildTemplates fetches the template catalog hosted at
+// client.templates_url
+func remoteBuildTemplates() ([]buildTemplate, error) {
+	url := viper.GetString("client.templates_url")
+	if url == "" {
+		return nil, nil
+	}
+	resp, err := releaseInfoClient.Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to download the template catalog %v", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unable to download the template catalog %v: %v", url, resp.Status)
+	}
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var templates []buildTemplate
+	if err := yaml.Unmarshal(buf, &templates); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the template catalog %v", url)
+	}
+	return templates, nil
+}
+
+// availableBuildTemplates returns the builtin templates followed by the
+// ones from the configuration and the hosted catalog. A template replaces
+// an earlier one with the same name.
+func availableBuildTemplates() ([]buildTemplate, error) {
+	var configured []buildTemplate
+	if err := viper.UnmarshalKey("client.templates", &configured); err != nil {
+		return nil, err
+	}
+	remote, err := remoteBuildTemplates()
+	if err != nil {
+		log.WithError(err).Debug("unable to fetch the hosted build file templates")
+	}
+
+	var templates []buildTemplate
+	add := func(source string, tmpls []buildTemplate) {
+		for _, tmpl := range tmpls {
+			tmpl.Source = source
+			replaced := false
+			for ii := range templates {
+				if templates[ii].Name == tmpl.Name {
+					templates[ii] = tmpl
+					replaced = true
+				}
+			}
+			if !replaced {
+				templates = append(templates, tmpl)
+			}
+		}
+	}
+	add("builtin", buildTemplates)
+	add("config", configured)
+	add("catalog", remote)
+	return templates, nil
+}
+
+func findBuildTemplate(name string) (buildTemplate, error) {
+	templates, err := availableBuildTemplates()
+	if err != nil {
+		return buildTemplate{}, err
+	}
+	for _, tmpl := range templates {
+		if tmpl.Name == name {
+			return tmpl, nil
+		}
+	}
+	return buildTemplate{}, errors.Errorf("there is no build file template named %v. Use `rai templates list` to see the available templates", name)
+}
+
+// newBuildFileParams renders the template for the queue, falling back to
+// amd64 when the queue is not in the configuration
+func newBuildFileParams(tmpl buildTemplate, queueName string) (buildFileParams, error) {
+	queue, err := findQueue(queueName)
+	if err != nil {
+		return buildFileParams{}, err
+	}
+	if queue == nil {
+		queue = &queueInfo{Name: queueName, Architecture: "amd64"}
+	}
+	return buildFileParams{
+		buildTemplate:   tmpl,
+		Architecture:    queue.Architecture,
+		GPUArchitecture: queue.GPU,
+	}, nil
+}
+
+// quoteYAML renders the string as a yaml scalar, quoting it only when needed
+func quoteYAML(s string) string {
+	buf, err := yaml.Marshal(s)
+	if err != nil {
+		return s
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// render produces the content of the rai_build.yml file
+func (p buildFileParams) render() ([]byte, error) {
+	tmpl, err := template.New("rai_build.yml").
+		Funcs(template.FuncMap{"quote": quoteYAML}).
+		Parse(buildFileTemplate)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
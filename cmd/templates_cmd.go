@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// templatesCmd groups the commands that describe the build file templates
+var templatesCmd = &cobra.Command{
+	Use:          "templates",
+	Short:        "Describe the build file templates that rai init can create.",
+	SilenceUsage: true,
+}
+
+var templatesListCmd = &cobra.Command{
+	Use:          "list",
+	Aliases:      []string{"ls"},
+	Short:        "Lists the available build file templates.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templates, err := availableBuildTemplates()
+		if err != nil {
+			return err
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Name", "Queue", "Image", "Source", "Description"})
+		for _, tmpl := range templates {
+			table.Append([]string{tmpl.Name, tmpl.Queue, tmpl.Image, tmpl.Source, tmpl.Description})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+var templatesShowCmd = &cobra.Command{
+	Use:          "show <name>",
+	Short:        "Prints the build file that a template creates.",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tmpl, err := findBuildTemplate(args[0])
+		if err != nil {
+			return err
+		}
+		queueName := jobQueueName
+		if queueName == "" {
+			queueName = tmpl.Queue
+		}
+		if queueName == "" {
+			queueName = defaultQueueName()
+		}
+		params, err := newBuildFileParams(tmpl, queueName)
+		if err != nil {
+			return err
+		}
+		buf, err := params.render()
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(buf))
+		return nil
+	},
+}
+
+func init() {
+	templatesCmd.AddCommand(templatesListCmd)
+	templatesCmd.AddCommand(templatesShowCmd)
+	RootCmd.AddCommand(templatesCmd)
+}
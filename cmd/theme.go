@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/acarl005/stripansi"
+	"github.com/fatih/color"
+	log "github.com/rai-project/logger"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// the kinds of output that the theme gives a color to
+const (
+	themeError         = "error"
+	themeWarning       = "warning"
+	themeSuccess       = "success"
+	themeStderr        = "stderr"
+	themeStepHeader    = "step_header"
+	themeServerMessage = "server_message"
+	themeHighlight     = "highlight"
+)
+
+// defaultTheme is the palette used for the kinds of output that the theme
+// of the configuration does not set. The lines of the server keep the
+// colors they were sent with unless the theme sets them.
+var defaultTheme = map[string]string{
+	themeError:         "red",
+	themeWarning:       "yellow",
+	themeSuccess:       "green",
+	themeStderr:        "red",
+	themeStepHeader:    "",
+	themeServerMessage: "",
+	themeHighlight:     "bold yellow",
+}
+
+// serverMessagePrefix starts the lines that the server prints about the
+// job, such as stepStartPrefix
+const serverMessagePrefix = "✱ "
+
+var themeColorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// parseThemeColor parses a color of the theme, which is made of space
+// separated words: a color name such as red, optionally prefixed with hi-
+// for the bright variant or bg- for the background, and the bold, faint,
+// italic and underline styles. It returns false for unknown words.
+func parseThemeColor(spec string) ([]color.Attribute, bool) {
+	attrs := []color.Attribute{}
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		switch word {
+		case "none", "default":
+			continue
+		case "bold":
+			attrs = append(attrs, color.Bold)
+			continue
+		case "faint":
+			attrs = append(attrs, color.Faint)
+			continue
+		case "italic":
+			attrs = append(attrs, color.Italic)
+			continue
+		case "underline":
+			attrs = append(attrs, color.Underline)
+			continue
+		}
+		base := 30
+		switch {
+		case strings.HasPrefix(word, "bg-hi-"):
+			base, word = 100, strings.TrimPrefix(word, "bg-hi-")
+		case strings.HasPrefix(word, "bg-"):
+			base, word = 40, strings.TrimPrefix(word, "bg-")
+		case strings.HasPrefix(word, "hi-"):
+			base, word = 90, strings.TrimPrefix(word, "hi-")
+		}
+		found := false
+		for ii, name := range themeColorNames {
+			if word == name {
+				attrs, found = append(attrs, color.Attribute(base+ii)), true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return attrs, true
+}
+
+var (
+	themeColorsMu sync.Mutex
+	themeColors   = map[string][]color.Attribute{}
+)
+
+// themeAttributes returns the attributes of the kind of output, taken from
+// the theme block of the configuration or the default theme
+func themeAttributes(kind string) []color.Attribute {
+	themeColorsMu.Lock()
+	defer themeColorsMu.Unlock()
+	if attrs, ok := themeColors[kind]; ok {
+		return attrs
+	}
+	spec := defaultTheme[kind]
+	if value := viper.Get("theme." + kind); value != nil {
+		spec = cast.ToString(value)
+	}
+	attrs, ok := parseThemeColor(spec)
+	if !ok {
+		log.Errorf("unknown color %q for theme.%v, using %q", spec, kind, defaultTheme[kind])
+		attrs, _ = parseThemeColor(defaultTheme[kind])
+	}
+	themeColors[kind] = attrs
+	return attrs
+}
+
+// themeColor returns the color of the kind of output
+func themeColor(kind string) *color.Color {
+	return color.New(themeAttributes(kind)...)
+}
+
+// themeString colors s as the kind of output. It is left as is when colors
+// are disabled or the theme gives the kind no color.
+func themeString(kind, s string) string {
+	if color.NoColor || len(themeAttributes(kind)) == 0 {
+		return s
+	}
+	return themeColor(kind).Sprint(s)
+}
+
+// colorsDisabled returns true if colors should be turned off although
+// --color was not given: when NO_COLOR is set or stdout is not a terminal
+func colorsDisabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return true
+	}
+	return !terminal.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// themedLines recolors the lines of the server according to the theme,
+// replacing the colors they were sent with. Lines are only held until it
+// is known whether they come from the server.
+type themedLines struct {
+	sync.Mutex
+	w       io.Writer
+	pending []byte
+}
+
+func (t *themedLines) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.pending = append(t.pending, p...)
+	buf := new(bytes.Buffer)
+	for {
+		idx := bytes.IndexByte(t.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		buf.WriteString(themeServerLine(string(t.pending[:idx])) + "\n")
+		t.pending = t.pending[idx+1:]
+	}
+	if len(t.pending) > 0 {
+		start := stripansi.Strip(string(t.pending))
+		if !strings.HasPrefix(start, serverMessagePrefix) && !strings.HasPrefix(serverMessagePrefix, start) {
+			buf.Write(t.pending)
+			t.pending = t.pending[:0]
+		}
+	}
+	if _, err := t.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// themeServerLine colors the line if it comes from the server and the theme
+// gives it a color
+func themeServerLine(line string) string {
+	plain := stripansi.Strip(line)
+	kind := themeServerMessage
+	switch {
+	case strings.HasPrefix(plain, stepStartPrefix):
+		kind = themeStepHeader
+	case !strings.HasPrefix(plain, serverMessagePrefix):
+		return line
+	}
+	if color.NoColor || len(themeAttributes(kind)) == 0 {
+		return line
+	}
+	return themeColor(kind).Sprint(plain)
+}
+
+// withTheme recolors the lines of the server if the theme sets a color for
+// them
+func withTheme(w io.Writer) io.Writer {
+	if color.NoColor || (len(themeAttributes(themeStepHeader)) == 0 && len(themeAttributes(themeServerMessage)) == 0) {
+		return w
+	}
+	return &themedLines{w: w}
+}
+
+// colorFilter removes the ANSI escape codes from the output written to
+// the terminal when colors are disabled. An escape code that is split
+// across writes is held until it is complete.
+type colorFilter struct {
+	sync.Mutex
+	w       io.Writer
+	pending []byte
+}
+
+func (f *colorFilter) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.pending = append(f.pending, p...)
+	out := f.pending
+	f.pending = nil
+	if idx := bytes.LastIndexByte(out, '\033'); idx >= 0 && !bytes.ContainsAny(out[idx:], "ABCDEFGHJKSTfmnsulh") {
+		out, f.pending = out[:idx], append([]byte{}, out[idx:]...)
+	}
+	if _, err := io.WriteString(f.w, stripansi.Strip(string(out))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// withoutColors removes the colors of the server from the output written
+// to w when colors are disabled
+func withoutColors(w io.Writer) io.Writer {
+	if !color.NoColor {
+		return w
+	}
+	return &colorFilter{w: w}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acarl005/stripansi"
+	"github.com/pkg/errors"
+)
+
+// the kinds of time that the lines of the job output can be prefixed with
+const (
+	timestampsWall    = "wall"
+	timestampsElapsed = "elapsed"
+)
+
+// timestampsFlag prefixes every line of the job output shown on the
+// terminal with the time and the build command it belongs to
+var timestampsFlag string
+
+// lineStamper prefixes the lines of the streams of a job with the wall
+// clock or the time elapsed since the job started, along with the number of
+// the build command that is running. The streams share the step, which is
+// advanced by the lines the server prints before running a command.
+type lineStamper struct {
+	sync.Mutex
+	kind  string
+	job   *jobRecord
+	start time.Time
+	step  int
+}
+
+// newLineStamper returns nil if the lines are not prefixed
+func newLineStamper(kind string, job *jobRecord) (*lineStamper, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case timestampsWall, timestampsElapsed:
+	default:
+		return nil, errors.Errorf("unknown timestamps %v, expecting wall or elapsed", kind)
+	}
+	return &lineStamper{kind: kind, job: job, start: time.Now()}, nil
+}
+
+// prefix returns the prefix of the line, advancing the step if the line
+// starts a build command
+func (s *lineStamper) prefix(line []byte) string {
+	s.Lock()
+	defer s.Unlock()
+	if strings.HasPrefix(stripansi.Strip(string(line)), stepStartPrefix) {
+		s.step++
+	}
+	now := time.Now()
+	stamp := now.Format("15:04:05.000")
+	if s.kind == timestampsElapsed {
+		start := s.start
+		if !s.job.StartedAt.IsZero() {
+			start = s.job.StartedAt
+		}
+		elapsed := now.Sub(start)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		stamp = fmt.Sprintf("+%02d:%02d:%02d.%d", int(elapsed.Hours()), int(elapsed.Minutes())%60,
+			int(elapsed.Seconds())%60, (elapsed%time.Second)/(100*time.Millisecond))
+	}
+	if s.step == 0 {
+		return stamp + " "
+	}
+	return fmt.Sprintf("%v [step %v] ", stamp, s.step)
+}
+
+// stampedLines writes the lines of a stream behind the prefix of the stamper
+type stampedLines struct {
+	sync.Mutex
+	w       io.Writer
+	stamper *lineStamper
+	// midLine is set when the last write did not end a line
+	midLine bool
+}
+
+func (l *stampedLines) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	buf := new(bytes.Buffer)
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if !l.midLine {
+			buf.WriteString(l.stamper.prefix(line))
+		}
+		buf.Write(line)
+		l.midLine = !bytes.HasSuffix(line, []byte("\n"))
+	}
+	if _, err := l.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// withTimestamps prefixes the lines written to w if --timestamps is set
+func withTimestamps(w io.Writer, stamper *lineStamper) io.Writer {
+	if stamper == nil {
+		return w
+	}
+	return &stampedLines{w: w, stamper: stamper}
+}
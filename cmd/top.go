@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// clearScreen moves the cursor to the top left and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// renderStats prints the latest and peak resource usage of the samples
+func renderStats(job *jobRecord, samples []statsSample) {
+	fmt.Printf("Job %v (%v)\n\n", job.ID, job.Phase)
+	if len(samples) == 0 {
+		fmt.Println("No resource usage was sampled yet. Submit the job with --stats-interval to sample it.")
+		return
+	}
+
+	cpu := func(ii int) float64 {
+		if ii == 0 {
+			return 0
+		}
+		elapsed := samples[ii].Time.Sub(samples[ii-1].Time)
+		if elapsed <= 0 {
+			return 0
+		}
+		return 100 * float64(samples[ii].CPUTime-samples[ii-1].CPUTime) / float64(elapsed)
+	}
+	percent := func(v int) string {
+		if v < 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%d%%", v)
+	}
+
+	last := len(samples) - 1
+	peak := statsSample{GPUUtilization: -1}
+	peakCPU := 0.0
+	for ii, sample := range samples {
+		if c := cpu(ii); c > peakCPU {
+			peakCPU = c
+		}
+		if sample.Memory > peak.Memory {
+			peak.Memory = sample.Memory
+		}
+		if sample.GPUUtilization > peak.GPUUtilization {
+			peak.GPUUtilization = sample.GPUUtilization
+		}
+		if sample.GPUMemory > peak.GPUMemory {
+			peak.GPUMemory = sample.GPUMemory
+		}
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"", "CPU", "Memory", "GPU", "GPU Memory"})
+	table.Append([]string{
+		"Current",
+		fmt.Sprintf("%.0f%%", cpu(last)),
+		humanize.IBytes(samples[last].Memory),
+		percent(samples[last].GPUUtilization),
+		humanize.IBytes(samples[last].GPUMemory),
+	})
+	table.Append([]string{
+		"Peak",
+		fmt.Sprintf("%.0f%%", peakCPU),
+		humanize.IBytes(peak.Memory),
+		percent(peak.GPUUtilization),
+		humanize.IBytes(peak.GPUMemory),
+	})
+	table.Render()
+	fmt.Printf("\nSampled at %v\n", samples[last].Time.Format("15:04:05"))
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top <id>",
+	Short: "Shows the resource usage of a job.",
+	Long: `Shows the CPU, memory and GPU usage of a job, refreshing while the job is running. ` +
+		`Resource usage is only sampled for jobs submitted with --stats-interval.`,
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job, err := loadJobRecord(args[0])
+		if err != nil {
+			return err
+		}
+		path, err := job.logPath()
+		if err != nil {
+			return err
+		}
+		for {
+			f, err := os.Open(path)
+			if err != nil {
+				return errors.Wrapf(err, "no output was captured for job %v", job.ID)
+			}
+			samples, err := readStatsSamples(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			attached := job.isAttached()
+			if attached {
+				fmt.Print(clearScreen)
+			}
+			renderStats(job, samples)
+			if !attached {
+				return nil
+			}
+			time.Sleep(time.Second)
+			if job, err = loadJobRecord(job.ID); err != nil {
+				return err
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(topCmd)
+}
@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rai-project/config"
+	log "github.com/rai-project/logger"
+	"gopkg.in/yaml.v2"
+)
+
+// the exporters of the traces, chosen by OTEL_TRACES_EXPORTER
+const (
+	traceExporterOTLP    = "otlp"
+	traceExporterConsole = "console"
+	traceExporterNone    = "none"
+)
+
+// defaultTraceEndpoint is where the traces are sent when no OTLP endpoint
+// is set, as for the OpenTelemetry SDKs
+const defaultTraceEndpoint = "http://localhost:4318/v1/traces"
+
+// traceExporter returns the exporter selected by the standard OTEL_
+// environment variables, or an empty string if tracing is disabled. The
+// traces are exported when OTEL_TRACES_EXPORTER or an OTLP endpoint is set.
+func traceExporter() string {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return ""
+	}
+	switch exporter := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); exporter {
+	case traceExporterOTLP, traceExporterConsole:
+		return exporter
+	case "":
+		if os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+			return traceExporterOTLP
+		}
+		return ""
+	case traceExporterNone:
+		return ""
+	default:
+		log.Errorf("unknown OTEL_TRACES_EXPORTER %v, expecting otlp, console or none", exporter)
+		return ""
+	}
+}
+
+// traceEndpoint returns the URL that the OTLP traces are posted to
+func traceEndpoint() string {
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	return defaultTraceEndpoint
+}
+
+// parseOTelPairs parses the comma separated key=value pairs of the
+// OTEL_RESOURCE_ATTRIBUTES and OTEL_EXPORTER_OTLP_HEADERS variables
+func parseOTelPairs(s string) map[string]string {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		idx := strings.Index(pair, "=")
+		if idx <= 0 {
+			continue
+		}
+		pairs[strings.TrimSpace(pair[:idx])] = strings.TrimSpace(pair[idx+1:])
+	}
+	return pairs
+}
+
+func newTraceID(size int) string {
+	buf := make([]byte, size)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// traceSpan is a step of the submission of the job
+type traceSpan struct {
+	ID         string
+	ParentID   string
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Error      string
+	Attributes map[string]interface{}
+}
+
+// jobTrace follows the submission of a job as a trace: a span for the job
+// and a span for every step of the client. It is nil when tracing is
+// disabled.
+type jobTrace struct {
+	sync.Mutex
+	exporter string
+	traceID  string
+	root     traceSpan
+	spans    []traceSpan
+}
+
+// newJobTrace starts the trace of the job if an exporter is selected
+func newJobTrace(job *jobRecord) *jobTrace {
+	exporter := traceExporter()
+	if exporter == "" {
+		return nil
+	}
+	t := &jobTrace{
+		exporter: exporter,
+		traceID:  newTraceID(16),
+		root:     traceSpan{ID: newTraceID(8), Name: "rai submit", Start: time.Now()},
+	}
+	job.TraceID = t.traceID
+	return t
+}
+
+// traceparent returns the W3C trace context of the span of the job
+func (t *jobTrace) traceparent() string {
+	if t == nil {
+		return ""
+	}
+	return "00-" + t.traceID + "-" + t.root.ID + "-01"
+}
+
+// span starts a span for the step of the client and returns the function
+// that ends it with the error of the step
+func (t *jobTrace) span(name string) func(err error) {
+	if t == nil {
+		return func(error) {}
+	}
+	span := traceSpan{ID: newTraceID(8), ParentID: t.root.ID, Name: name, Start: time.Now()}
+	return func(err error) {
+		span.End = time.Now()
+		if err != nil {
+			span.Error = err.Error()
+		}
+		t.Lock()
+		defer t.Unlock()
+		t.spans = append(t.spans, span)
+	}
+}
+
+// finish ends the span of the job and exports the trace
+func (t *jobTrace) finish(job *jobRecord, jobErr error) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.root.End = time.Now()
+	if jobErr != nil {
+		t.root.Error = jobErr.Error()
+	}
+	queue := job.Queue
+	if queue == "" {
+		queue = defaultQueueName()
+	}
+	t.root.Attributes = map[string]interface{}{
+		"rai.job.id":    job.ID,
+		"rai.job.phase": string(job.Phase),
+		"rai.queue":     queue,
+		"rai.exit_code": ExitCode(jobErr),
+	}
+	if job.SourceDigest != "" {
+		t.root.Attributes["rai.source_digest"] = job.SourceDigest
+	}
+	buf, err := json.Marshal(t.otlp())
+	if err != nil {
+		log.WithError(err).Error("unable to encode the trace of the job")
+		return
+	}
+	if t.exporter == traceExporterConsole {
+		fmt.Fprintln(os.Stderr, string(buf))
+		return
+	}
+	if err := postTrace(buf); err != nil {
+		log.WithError(err).Errorf("unable to export the trace of the job to %v", traceEndpoint())
+	}
+}
+
+// postTrace sends the trace to the OTLP/HTTP endpoint in the JSON encoding
+func postTrace(buf []byte) error {
+	req, err := http.NewRequest("POST", traceEndpoint(), bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	headers := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")
+	if headers == "" {
+		headers = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	for key, value := range parseOTelPairs(headers) {
+		req.Header.Set(key, value)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("the collector responded with %v", resp.Status)
+	}
+	return nil
+}
+
+// otlpAttributes encodes the attributes as OTLP key values
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	keys := []string{}
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := []map[string]interface{}{}
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attrs[key].(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]interface{}{"key": key, "value": value})
+	}
+	return out
+}
+
+// otlpSpan encodes the span in the OTLP JSON encoding
+func (t *jobTrace) otlpSpan(span traceSpan) map[string]interface{} {
+	out := map[string]interface{}{
+		"traceId":           t.traceID,
+		"spanId":            span.ID,
+		"name":              span.Name,
+		"kind":              3, // client
+		"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+		"attributes":        otlpAttributes(span.Attributes),
+		"status":            map[string]interface{}{"code": 1},
+	}
+	if span.ParentID != "" {
+		out["parentSpanId"] = span.ParentID
+	}
+	if span.Error != "" {
+		out["status"] = map[string]interface{}{"code": 2, "message": span.Error}
+	}
+	return out
+}
+
+// otlp encodes the trace as an OTLP export request
+func (t *jobTrace) otlp() map[string]interface{} {
+	resource := map[string]interface{}{}
+	for key, value := range parseOTelPairs(os.Getenv("OTEL_RESOURCE_ATTRIBUTES")) {
+		resource[key] = value
+	}
+	resource["service.name"] = "rai"
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		resource["service.name"] = name
+	}
+	resource["service.version"] = config.App.Version.Version
+	spans := []map[string]interface{}{t.otlpSpan(t.root)}
+	for _, span := range t.spans {
+		spans = append(spans, t.otlpSpan(span))
+	}
+	return map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource": map[string]interface{}{"attributes": otlpAttributes(resource)},
+			"scopeSpans": []map[string]interface{}{{
+				"scope": map[string]interface{}{"name": "github.com/rai-project/rai"},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+type traceContextKey struct{}
+
+// withTrace returns a context that carries the trace of the job
+func withTrace(ctx context.Context, t *jobTrace) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, t)
+}
+
+// traceFromContext returns the trace of the job, or nil
+func traceFromContext(ctx context.Context) *jobTrace {
+	t, _ := ctx.Value(traceContextKey{}).(*jobTrace)
+	return t
+}
+
+// activeTraceparent is the trace context of the job that is submitted. It
+// is passed to the build commands as TRACEPARENT so that the traces of
+// the worker can be correlated with those of the client. It is left out of
+// the build file that is shown or kept, so that the digest of the build
+// commands does not change with every submission.
+var activeTraceparent string
+
+func init() {
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		build := buildCommands(doc)
+		if activeTraceparent == "" || resolvingForRecord || len(build) == 0 {
+			return doc, false, nil
+		}
+		for ii, command := range build {
+			build[ii] = "export TRACEPARENT=" + activeTraceparent + "; " + command
+		}
+		return setBuildCommands(doc, build), true, nil
+	})
+}
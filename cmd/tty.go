@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
+)
+
+// ttyFlag runs the build commands in a pseudo-terminal on the worker
+var ttyFlag bool
+
+// terminalSize returns the size of the local terminal, or 80x24 when the
+// output is not a terminal
+func terminalSize() (int, int) {
+	width, height, err := terminal.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// withTTY runs the command under script, which gives it a pseudo-terminal
+// of the size of the local terminal. The output of the command, including
+// what it writes to stderr, is written to stdout.
+func withTTY(command string, width, height int) string {
+	inner := fmt.Sprintf("export TERM=xterm-256color; stty cols %v rows %v 2>/dev/null; %v", width, height, command)
+	return fmt.Sprintf("script -qec %v /dev/null", shellQuote(inner))
+}
+
+func init() {
+	buildFileTransforms = append(buildFileTransforms, func(doc yaml.MapSlice) (yaml.MapSlice, bool, error) {
+		build := buildCommands(doc)
+		if !ttyFlag || len(build) == 0 {
+			return doc, false, nil
+		}
+		width, height := terminalSize()
+		commands := make([]string, len(build))
+		for ii, command := range build {
+			commands[ii] = withTTY(command, width, height)
+		}
+		return setBuildCommands(doc, commands), true, nil
+	})
+}
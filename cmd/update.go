@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pkg/errors"
+	"github.com/rai-project/config"
+	log "github.com/rai-project/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	updateCheckOnly  bool
+	updateSkipVerify bool
+)
+
+// releaseInfo describes the latest release of the client for a platform.
+// It is published at <update_url>/<os>-<arch>.json
+type releaseInfo struct {
+	Version string
+	// Sha256 is the digest of the uncompressed executable
+	Sha256 []byte
+	// MinimumVersion is the oldest version of the client that the server
+	// accepts jobs from
+	MinimumVersion string `json:",omitempty"`
+}
+
+// releaseInfoClient is used to fetch the release information, which is
+// also done before each submission and so must not hang
+var releaseInfoClient = &http.Client{Timeout: 10 * time.Second}
+
+// parseVersion parses a version with an optional v prefix
+func parseVersion(s string) (*semver.Version, error) {
+	return semver.NewVersion(strings.TrimPrefix(strings.TrimSpace(s), "v"))
+}
+
+// isOutdated returns true if the current version is older than the version
+func isOutdated(version string) (bool, error) {
+	current, err := parseVersion(config.App.Version.Version)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to parse the client version %v", config.App.Version.Version)
+	}
+	other, err := parseVersion(version)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to parse the version %v", version)
+	}
+	return current.LessThan(*other), nil
+}
+
+// releaseURL returns the location of a release file on the update server
+func releaseURL(parts ...string) string {
+	base := strings.TrimSuffix(viper.GetString("client.update_url"), "/")
+	return base + "/" + strings.Join(parts, "/")
+}
+
+func releasePlatform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// fetchReleaseFile downloads a file from the update server
+func fetchReleaseFile(httpClient *http.Client, parts ...string) ([]byte, error) {
+	url := releaseURL(parts...)
+	if isDebug || isVerbose {
+		fmt.Println("GET " + url)
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to download %v", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.Errorf("no release of the client is available for %v", releasePlatform())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unable to download %v: %v", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// latestRelease returns the latest release for the current platform
+func latestRelease() (*releaseInfo, error) {
+	buf, err := fetchReleaseFile(releaseInfoClient, releasePlatform()+".json")
+	if err != nil {
+		return nil, err
+	}
+	info := &releaseInfo{}
+	if err := json.Unmarshal(buf, info); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the release information")
+	}
+	return info, nil
+}
+
+// verifyRelease checks the detached signature of the release archive
+// against the update signing key in the configuration
+func verifyRelease(archive, signature []byte) error {
+	key := viper.GetString("client.update_public_key")
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	if err != nil {
+		return errors.Wrap(err, "unable to read the update signing key")
+	}
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(archive), bytes.NewReader(signature)); err != nil {
+		return errors.Wrap(err, "the signature of the release is invalid")
+	}
+	return nil
+}
+
+// checkUpdateSource fails if the release could not be authenticated: the
+// update server must be reached over https, and the release must be
+// verified unless --skip-verify is given
+func checkUpdateSource() error {
+	if !strings.HasPrefix(strings.ToLower(viper.GetString("client.update_url")), "https://") {
+		return errors.Errorf("the update server %v is not reached over https, so the release can not be trusted", viper.GetString("client.update_url"))
+	}
+	if !updateSkipVerify && viper.GetString("client.update_public_key") == "" {
+		return errors.New("no update signing key is configured in client.update_public_key. Use --skip-verify to update without checking the signature")
+	}
+	return nil
+}
+
+// downloadRelease downloads, verifies and decompresses the executable of
+// the release
+func downloadRelease(info *releaseInfo) ([]byte, error) {
+	if err := checkUpdateSource(); err != nil {
+		return nil, err
+	}
+	name := releasePlatform() + ".gz"
+	archive, err := fetchReleaseFile(http.DefaultClient, info.Version, name)
+	if err != nil {
+		return nil, err
+	}
+	if !updateSkipVerify {
+		signature, err := fetchReleaseFile(http.DefaultClient, info.Version, name+".asc")
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyRelease(archive, signature); err != nil {
+			return nil, err
+		}
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the release archive")
+	}
+	defer gz.Close()
+	binary, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the release archive")
+	}
+	digest := sha256.Sum256(binary)
+	if !bytes.Equal(digest[:], info.Sha256) {
+		return nil, errors.New("the checksum of the downloaded executable does not match the release")
+	}
+	return binary, nil
+}
+
+// replaceExecutable replaces the running executable. The new executable is
+// written next to the current one and renamed over it, so the executable
+// is never left partially written or missing. On windows the running
+// executable is moved aside first, since it can not be replaced.
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	dir := filepath.Dir(exe)
+	tmp, err := ioutil.TempFile(dir, ".rai-update-")
+	if err != nil {
+		return errors.Wrapf(err, "unable to write to %v", dir)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, bytes.NewReader(binary)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" {
+		return os.Rename(tmp.Name(), exe)
+	}
+	// the running executable can not be overwritten on windows, but it
+	// can be moved out of the way
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		os.Rename(old, exe)
+		return err
+	}
+	os.Remove(old)
+	return nil
+}
+
+// selfUpdate replaces the client with the latest release if it is newer
+func selfUpdate() error {
+	info, err := latestRelease()
+	if err != nil {
+		return err
+	}
+	outdated, err := isOutdated(info.Version)
+	if err != nil {
+		return err
+	}
+	if !outdated {
+		fmt.Printf("rai %v is the latest version.\n", config.App.Version.Version)
+		return nil
+	}
+	if updateCheckOnly {
+		fmt.Printf("rai %v is available (currently %v). Run `rai update` to install it.\n", info.Version, config.App.Version.Version)
+		return nil
+	}
+	fmt.Printf("Updating rai %v to %v\n", config.App.Version.Version, info.Version)
+	binary, err := downloadRelease(info)
+	if err != nil {
+		return err
+	}
+	if err := replaceExecutable(binary); err != nil {
+		return errors.Wrap(err, "unable to replace the executable")
+	}
+	fmt.Printf("rai was updated to %v\n", info.Version)
+	return nil
+}
+
+// releaseCheckInterval is how long the release information fetched before
+// a submission is reused
+const releaseCheckInterval = time.Hour
+
+// cachedRelease returns the latest release for the current platform,
+// fetching it at most once per releaseCheckInterval, so that each job
+// submitted by rai watch or by the children of --matrix does not wait on
+// the release server. When the server can not be reached, the check is
+// skipped until the interval passes.
+func cachedRelease() (*releaseInfo, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return latestRelease()
+	}
+	path := filepath.Join(dir, "release-"+releasePlatform()+".json")
+	if stat, err := os.Stat(path); err == nil && time.Since(stat.ModTime()) < releaseCheckInterval {
+		if buf, err := ioutil.ReadFile(path); err == nil {
+			info := &releaseInfo{}
+			if err := json.Unmarshal(buf, info); err == nil {
+				return info, nil
+			}
+		}
+	}
+	info, err := latestRelease()
+	if err != nil {
+		ioutil.WriteFile(path, []byte("{}"), 0600)
+		return nil, err
+	}
+	if buf, err := json.Marshal(info); err == nil {
+		ioutil.WriteFile(path, buf, 0600)
+	}
+	return info, nil
+}
+
+// checkMinimumVersion fails if the server no longer accepts jobs from this
+// version of the client. When running in a terminal, and the update can be
+// verified, the user is offered to update. The check is skipped if the
+// release server can not be reached.
+func checkMinimumVersion() error {
+	info, err := cachedRelease()
+	if err != nil {
+		log.WithError(err).Debug("unable to check the minimum client version")
+		return nil
+	}
+	if info.MinimumVersion == "" {
+		return nil
+	}
+	outdated, err := isOutdated(info.MinimumVersion)
+	if err != nil || !outdated {
+		return nil
+	}
+
+	msg := fmt.Sprintf("rai %v is no longer supported, version %v or later is required", config.App.Version.Version, info.MinimumVersion)
+	// the update is not offered when it would be refused
+	if err := checkUpdateSource(); err != nil {
+		return errors.Errorf("%v. Download the latest release of rai to update the client", msg)
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.Errorf("%v. Run `rai update` to update the client", msg)
+	}
+	answer, err := prompt(bufio.NewReader(os.Stdin), msg+". Update now? [y/N]", false)
+	if err != nil || !strings.HasPrefix(strings.ToLower(answer), "y") {
+		return errors.Errorf("%v. Run `rai update` to update the client", msg)
+	}
+	if err := selfUpdate(); err != nil {
+		return err
+	}
+	return errors.New("run the command again to submit the job with the updated client")
+}
+
+var updateCmd = &cobra.Command{
+	Use:     "update",
+	Aliases: []string{"self-update", "selfupdate"},
+	Short:   "Updates rai if a new version exists.",
+	Long: `Checks the release server for a newer version of rai for the current operating system ` +
+		`and architecture. The release is verified against the update signing key in the ` +
+		`configuration and replaces the running executable.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return selfUpdate()
+	},
+}
+
+func init() {
+	updateCmd.Flags().BoolVar(&updateCheckOnly, "check", false, "Only check whether a new version exists.")
+	updateCmd.Flags().BoolVar(&updateSkipVerify, "skip-verify", false, "Do not verify the signature of the release. The update server must still be reached over https.")
+	RootCmd.AddCommand(updateCmd)
+}
@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// uploadFile is a file within the directory that is uploaded with the job
+type uploadFile struct {
+	// Path is the slash separated path relative to the uploaded directory
+	Path string
+	Size int64
+	Mode os.FileMode
+	// Source is the location the file is read from
+	Source string
+}
+
+// uploadIgnoreFiles returns the names of the ignore files that exclude
+// files from the upload. They apply to the directory they are in and below.
+// The .gitignore files are only used when client.respect_gitignore is set.
+func uploadIgnoreFiles() []string {
+	if viper.GetBool("client.respect_gitignore") {
+		return []string{".raiignore", ".gitignore"}
+	}
+	return []string{".raiignore"}
+}
+
+// defaultExcludes are the build outputs that are not uploaded unless
+// client.no_default_excludes is set. They can be re-included using
+// negated patterns in a .raiignore file.
+var defaultExcludes = []string{
+	"*.o",
+	"*.so",
+	"build/",
+	"__pycache__/",
+	".git/",
+	defaultResultFile,
+}
+
+// newUploadIgnoreMatcher returns the matcher for the rules that apply to
+// the whole directory: the default excludes and, when respecting
+// .gitignore files, the .git directory and the repository's exclude file.
+func newUploadIgnoreMatcher(dir string) *ignoreMatcher {
+	matcher := &ignoreMatcher{}
+	if !viper.GetBool("client.no_default_excludes") {
+		matcher.addIgnoreFile([]byte(strings.Join(defaultExcludes, "\n")), "")
+	}
+	if !viper.GetBool("client.respect_gitignore") {
+		return matcher
+	}
+	matcher.addIgnoreFile([]byte(".git/"), "")
+	if buf, err := ioutil.ReadFile(filepath.Join(dir, ".git", "info", "exclude")); err == nil {
+		matcher.addIgnoreFile(buf, "")
+	}
+	return matcher
+}
+
+// uploadExclusions summarizes the files that are excluded from the upload
+type uploadExclusions struct {
+	Files int
+	Bytes int64
+}
+
+func (e uploadExclusions) String() string {
+	sources := uploadIgnoreFiles()
+	if !viper.GetBool("client.no_default_excludes") {
+		sources = append([]string{"the default excludes"}, sources...)
+	}
+	return fmt.Sprintf("%v files (%v) were excluded by %v", e.Files, humanize.Bytes(uint64(e.Bytes)), strings.Join(sources, ", "))
+}
+
+// The ways symbolic links are handled when uploading the directory
+const (
+	// symlinksPreserve uploads the links themselves
+	symlinksPreserve = "preserve"
+	// symlinksFollow uploads the files and directories that links point to
+	symlinksFollow = "follow"
+	// symlinksDeny fails if a link points outside of the directory
+	symlinksDeny = "deny"
+)
+
+// symlinkPolicy returns how symbolic links are handled, as set by
+// --symlinks or client.symlinks
+func symlinkPolicy() (string, error) {
+	policy := viper.GetString("client.symlinks")
+	switch policy {
+	case "":
+		return symlinksPreserve, nil
+	case symlinksPreserve, symlinksFollow, symlinksDeny:
+		return policy, nil
+	}
+	return "", errors.Errorf("invalid symlink handling %v, expecting one of preserve, follow or deny", policy)
+}
+
+// uploadWalker collects the files to upload, applying the ignore files and
+// the symlink policy
+type uploadWalker struct {
+	root        string
+	matcher     *ignoreMatcher
+	ignoreFiles []string
+	policy      string
+	files       []uploadFile
+	exclusions  uploadExclusions
+	// visited holds the followed directories to avoid cycles
+	visited map[string]bool
+}
+
+// isWithinRoot returns true if the resolved path is inside the directory
+func (w *uploadWalker) isWithinRoot(target string) bool {
+	rel, err := filepath.Rel(w.root, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// walk collects the files of the directory src, which is uploaded as the
+// slash separated rel
+func (w *uploadWalker) walk(src, rel string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		r, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		entry := filepath.ToSlash(filepath.Join(filepath.FromSlash(rel), r))
+		if entry != "." && w.matcher.excluded(entry, info.IsDir()) {
+			if !info.IsDir() {
+				w.exclusions.Files++
+				w.exclusions.Bytes += info.Size()
+				return nil
+			}
+			filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					w.exclusions.Files++
+					w.exclusions.Bytes += info.Size()
+				}
+				return nil
+			})
+			return filepath.SkipDir
+		}
+		if info.IsDir() {
+			base := entry
+			if base == "." {
+				base = ""
+			}
+			for _, name := range w.ignoreFiles {
+				if buf, err := ioutil.ReadFile(filepath.Join(path, name)); err == nil {
+					w.matcher.addIgnoreFile(buf, base)
+				}
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return w.addSymlink(path, entry, info)
+		}
+		w.files = append(w.files, uploadFile{
+			Path:   entry,
+			Size:   info.Size(),
+			Mode:   info.Mode(),
+			Source: path,
+		})
+		return nil
+	})
+}
+
+func (w *uploadWalker) addSymlink(path, entry string, info os.FileInfo) error {
+	link := uploadFile{
+		Path:   entry,
+		Size:   info.Size(),
+		Mode:   info.Mode(),
+		Source: path,
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if w.policy == symlinksDeny {
+			return errors.Errorf("the symbolic link %v is broken", entry)
+		}
+		if w.policy == symlinksFollow {
+			fmt.Printf("Skipping the broken symbolic link %v\n", entry)
+			return nil
+		}
+		w.files = append(w.files, link)
+		return nil
+	}
+	switch w.policy {
+	case symlinksDeny:
+		if !w.isWithinRoot(target) {
+			return errors.Errorf("the symbolic link %v points outside of the directory to %v", entry, target)
+		}
+	case symlinksFollow:
+		targetInfo, err := os.Stat(target)
+		if err != nil {
+			return err
+		}
+		if !targetInfo.IsDir() {
+			w.files = append(w.files, uploadFile{
+				Path:   entry,
+				Size:   targetInfo.Size(),
+				Mode:   targetInfo.Mode(),
+				Source: target,
+			})
+			return nil
+		}
+		if w.visited[target] {
+			return nil
+		}
+		w.visited[target] = true
+		return w.walk(target, entry)
+	}
+	w.files = append(w.files, link)
+	return nil
+}
+
+// collectUploadFiles returns the files within the directory and the
+// included files and directories that are uploaded with the job, sorted by
+// path, along with a summary of the files excluded by the ignore files
+func collectUploadFiles(dir string) ([]uploadFile, uploadExclusions, error) {
+	policy, err := symlinkPolicy()
+	if err != nil {
+		return nil, uploadExclusions{}, err
+	}
+	includes, err := uploadIncludes()
+	if err != nil {
+		return nil, uploadExclusions{}, err
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, uploadExclusions{}, err
+	}
+	w := &uploadWalker{
+		root:        root,
+		matcher:     newUploadIgnoreMatcher(dir),
+		ignoreFiles: uploadIgnoreFiles(),
+		policy:      policy,
+		files:       []uploadFile{},
+		visited:     map[string]bool{root: true},
+	}
+	if err := w.walk(dir, "."); err != nil {
+		return nil, w.exclusions, err
+	}
+	for _, include := range includes {
+		if err := w.walk(include.Source, include.Dest); err != nil {
+			return nil, w.exclusions, err
+		}
+	}
+	sort.Slice(w.files, func(ii, jj int) bool {
+		return w.files[ii].Path < w.files[jj].Path
+	})
+	for ii := 1; ii < len(w.files); ii++ {
+		if w.files[ii].Path == w.files[ii-1].Path {
+			return nil, w.exclusions, errors.Errorf("%v is included more than once", w.files[ii].Path)
+		}
+	}
+	return w.files, w.exclusions, nil
+}
+
+// isResolved returns true if the file is uploaded from a location other
+// than its path within the directory, because a symbolic link was followed
+// or the file was included from outside of the directory
+func (f uploadFile) isResolved(dir string) bool {
+	return f.Source != filepath.Join(dir, filepath.FromSlash(f.Path))
+}
+
+// stageUploadDirectory prepares the directory that is uploaded with the
+// job. When files are excluded or generated, the remaining files are
+// linked, or copied if linking is not possible, into a temporary directory
+// along with the generated files. The temporary directory is created next
+// to the directory when possible, since the files can only be linked on
+// the same filesystem; otherwise they are copied to the system temporary
+// directory. It returns an empty path if the directory can be uploaded as
+// is.
+func stageUploadDirectory(dir string, generated map[string][]byte) (string, func(), error) {
+	noop := func() {}
+	files, exclusions, err := collectUploadFiles(dir)
+	if err != nil {
+		return "", noop, err
+	}
+	resolved := false
+	for _, file := range files {
+		if file.isResolved(dir) {
+			resolved = true
+			break
+		}
+	}
+	if exclusions.Files == 0 && !resolved && len(generated) == 0 {
+		return "", noop, nil
+	}
+	if exclusions.Files > 0 {
+		fmt.Println(exclusions)
+	}
+
+	staged, err := stagingDirectory(dir)
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() {
+		os.RemoveAll(staged)
+	}
+	for _, file := range files {
+		src := file.Source
+		dst := filepath.Join(staged, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			cleanup()
+			return "", noop, err
+		}
+		if file.Mode&os.ModeSymlink != 0 {
+			target, err := os.Readlink(src)
+			if err == nil {
+				err = os.Symlink(target, dst)
+			}
+			if err != nil {
+				cleanup()
+				return "", noop, err
+			}
+			continue
+		}
+		if err := os.Link(src, dst); err == nil {
+			continue
+		}
+		if err := copyFile(src, dst, file.Mode); err != nil {
+			cleanup()
+			return "", noop, err
+		}
+	}
+	for name, content := range generated {
+		// a file of the directory with the same name is linked to the
+		// original, which must not be overwritten
+		dst := filepath.Join(staged, name)
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			cleanup()
+			return "", noop, err
+		}
+		if err := ioutil.WriteFile(dst, content, 0644); err != nil {
+			cleanup()
+			return "", noop, err
+		}
+	}
+	return staged, cleanup, nil
+}
+
+// stagingDirectory creates the temporary directory that the upload is
+// staged in, next to the directory if its parent is writable
+func stagingDirectory(dir string) (string, error) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		if staged, err := ioutil.TempDir(filepath.Dir(abs), ".rai-upload-"); err == nil {
+			return staged, nil
+		}
+	}
+	return ioutil.TempDir("", "rai-upload")
+}
+
+// copyFile copies the content of the file into a new file with the mode
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// totalUploadSize returns the sum of the file sizes
+func totalUploadSize(files []uploadFile) int64 {
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	return total
+}
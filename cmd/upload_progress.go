@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// uploadProgressFrames animate the upload line on a terminal
+var uploadProgressFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// uploadProgress reports the upload phase while the client library uploads
+// the directory. The library does not report the bytes that were sent, so
+// the elapsed time is shown alongside the size of the directory.
+type uploadProgress struct {
+	size  int64
+	start time.Time
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// startUploadProgress starts reporting the upload of size bytes. On a
+// terminal the line is refreshed in place, otherwise a line is printed
+// every 10 seconds.
+func startUploadProgress(size int64) *uploadProgress {
+	p := &uploadProgress{
+		size:  size,
+		start: time.Now(),
+		done:  make(chan struct{}),
+	}
+	interactive := terminal.IsTerminal(int(os.Stdout.Fd()))
+	interval := 10 * time.Second
+	if interactive {
+		interval = 100 * time.Millisecond
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			select {
+			case <-p.done:
+				if interactive {
+					fmt.Print("\r" + strings.Repeat(" ", 60) + "\r")
+				}
+				return
+			case <-ticker.C:
+			}
+			elapsed := time.Since(p.start).Round(time.Second)
+			if interactive {
+				fmt.Printf("\r%v Uploading %v (%v)", uploadProgressFrames[frame%len(uploadProgressFrames)],
+					humanize.Bytes(uint64(p.size)), elapsed)
+			} else {
+				fmt.Printf("Still uploading %v (%v elapsed)\n", humanize.Bytes(uint64(p.size)), elapsed)
+			}
+		}
+	}()
+	return p
+}
+
+// stop ends the report and prints the throughput if the upload succeeded
+func (p *uploadProgress) stop(err error) {
+	close(p.done)
+	p.wg.Wait()
+	if err != nil {
+		return
+	}
+	elapsed := time.Since(p.start)
+	rate := ""
+	if elapsed > 0 {
+		rate = fmt.Sprintf(", %v/s", humanize.Bytes(uint64(float64(p.size)/elapsed.Seconds())))
+	}
+	fmt.Printf("Uploaded %v in %v%v\n", humanize.Bytes(uint64(p.size)), elapsed.Round(100*time.Millisecond), rate)
+}
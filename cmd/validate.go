@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rai-project/client"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:          "validate",
+	Short:        "Validates the build file without submitting the job.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := buildFileLocation()
+		_, warnings, err := readBuildSpecification(path)
+		for _, warning := range warnings {
+			themeColor(themeWarning).Print("warning: ")
+			fmt.Println(warning)
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkResources(defaultQueueName()); err != nil {
+			return err
+		}
+
+		// run the same validation that is performed when submitting
+		clnt, err := newClient(client.Stdout(nil), client.Stderr(nil))
+		if err != nil {
+			return err
+		}
+		defer clnt.Disconnect()
+		if err := clnt.Validate(); err != nil {
+			return err
+		}
+
+		themeColor(themeSuccess).Print("✔ ")
+		fmt.Printf("%v is valid\n", path)
+		return nil
+	},
+}
+
+func init() {
+	validateCmd.Flags().BoolVar(&strictBuildFile, "strict", false, "Treat unknown keys in the build file as errors.")
+	RootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fsnotify/fsnotify"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var watchDebounce time.Duration
+
+// watchDirectories adds the directory and all its subdirectories to the
+// watcher. Hidden directories such as .git are skipped.
+func watchDirectories(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// watchOutputPatterns returns the patterns of the files that the
+// submissions write, such as the result file and the log files, so that
+// writing them does not trigger a new submission
+func watchOutputPatterns() []string {
+	patterns := []string{}
+	if resultFilePath != "" {
+		path := resultFilePath
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		patterns = append(patterns, path)
+	}
+	// the log files are named after the job
+	anyJob := &jobRecord{ID: "*"}
+	if path := viper.GetString("client.log_file"); path != "" {
+		if path, err := homedir.Expand(strings.Replace(path, logFileJobID, anyJob.ID, -1)); err == nil {
+			patterns = append(patterns, path)
+		}
+	}
+	if splitStreams {
+		if stdoutPath, stderrPath, err := splitStreamPaths(anyJob); err == nil {
+			patterns = append(patterns, stdoutPath, stderrPath)
+		}
+	}
+	for ii, pattern := range patterns {
+		if abs, err := filepath.Abs(pattern); err == nil {
+			patterns[ii] = abs
+		}
+	}
+	return patterns
+}
+
+// isWatchOutput returns true if the file matches one of the patterns
+func isWatchOutput(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// watchChanges collects the files that changed since the last submission
+type watchChanges struct {
+	sync.Mutex
+	paths map[string]bool
+}
+
+func (c *watchChanges) add(path string) {
+	c.Lock()
+	defer c.Unlock()
+	c.paths[path] = true
+}
+
+func (c *watchChanges) take() []string {
+	c.Lock()
+	defer c.Unlock()
+	paths := []string{}
+	for path := range c.paths {
+		paths = append(paths, path)
+	}
+	c.paths = map[string]bool{}
+	return paths
+}
+
+// watchedUploadFiles returns the slash separated paths of the files of the
+// directory that are uploaded, leaving out those excluded by the ignore
+// files and the default excludes
+func watchedUploadFiles(dir string) (map[string]bool, error) {
+	files, _, err := collectUploadFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	uploaded := map[string]bool{}
+	for _, f := range files {
+		uploaded[f.Path] = true
+	}
+	return uploaded, nil
+}
+
+// changesUploadedFiles returns true if one of the changed files was
+// uploaded with the last job or is uploaded with the next one
+func changesUploadedFiles(dir string, changed []string, previous, current map[string]bool) bool {
+	for _, path := range changed {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return true
+		}
+		rel = filepath.ToSlash(rel)
+		if previous[rel] || current[rel] {
+			return true
+		}
+	}
+	return false
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Resubmits the job whenever a file in the directory changes.",
+	Long: `Submits the job and then watches the directory, resubmitting the job whenever a file changes. ` +
+		`Changes made while a job is running are submitted once it completes. Changes to files that are not uploaded, ` +
+		`such as those excluded by .raiignore and the result and log files written by rai, are ignored.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
+
+		dir, err := filepath.Abs(workingDir)
+		if err != nil {
+			return err
+		}
+		if err := watchDirectories(watcher, dir); err != nil {
+			return err
+		}
+		if err := serveMetrics(); err != nil {
+			return err
+		}
+
+		// the downloaded build directory must not trigger a new submission
+		ignoredDir := ""
+		if outputDirectory != "" {
+			ignoredDir, _ = filepath.Abs(outputDirectory)
+		}
+		outputs := watchOutputPatterns()
+		changes := &watchChanges{paths: map[string]bool{}}
+
+		// at most one submission is pending while a job is running
+		pending := make(chan struct{}, 1)
+		pending <- struct{}{}
+
+		go func() {
+			for {
+				select {
+				case event, ok := <-watcher.Events:
+					if !ok {
+						return
+					}
+					if event.Op&fsnotify.Create != 0 {
+						if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+							watchDirectories(watcher, event.Name)
+						}
+					}
+					if event.Op == fsnotify.Chmod {
+						continue
+					}
+					if ignoredDir != "" && strings.HasPrefix(event.Name, ignoredDir) {
+						continue
+					}
+					if isWatchOutput(event.Name, outputs) {
+						continue
+					}
+					changes.add(event.Name)
+					select {
+					case pending <- struct{}{}:
+					default:
+					}
+				case err, ok := <-watcher.Errors:
+					if !ok {
+						return
+					}
+					color.New(color.FgRed).Println("watch error: " + err.Error())
+				}
+			}
+		}()
+
+		// the files uploaded with the last job, so that changes to the
+		// files that are not uploaded do not trigger a new submission
+		var uploaded map[string]bool
+		for range pending {
+			// wait for the burst of changes (e.g. an editor saving several
+			// files) to settle before submitting
+			time.Sleep(watchDebounce)
+			select {
+			case <-pending:
+			default:
+			}
+			changed := changes.take()
+			current, err := watchedUploadFiles(dir)
+			if err == nil && uploaded != nil && !changesUploadedFiles(dir, changed, uploaded, current) {
+				uploaded = current
+				continue
+			}
+			uploaded = current
+
+			color.New(color.FgCyan).Printf("⟳ Submitting %v at %v\n", workingDir, time.Now().Format("15:04:05"))
+			if err := submitJob(); err != nil {
+				color.New(color.FgRed).Println(err.Error())
+			}
+			fmt.Println("Waiting for changes...")
+		}
+		return nil
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", time.Second, "Time to wait for changes to settle before submitting.")
+	watchCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Serve the metrics of the submitted jobs for Prometheus on /metrics at this address (e.g. :9100).")
+	RootCmd.AddCommand(watchCmd)
+}
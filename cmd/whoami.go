@@ -1,14 +1,43 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
-	"github.com/pkg/errors"
-	"github.com/rai-project/auth/provider"
+	"github.com/rai-project/client"
+	"github.com/spf13/cast"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
+	"github.com/spf13/viper"
 )
 
+// fingerprint returns a short digest that identifies a key without
+// revealing it
+func fingerprint(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// environmentOverrides returns the names of the RAI_ environment variables
+// that are set
+func environmentOverrides() []string {
+	names := []string{}
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, "RAI_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 var WhoamiCmd = &cobra.Command{
 	Use:          "whoami",
 	Short:        "Prints the user information.",
@@ -16,23 +45,43 @@ var WhoamiCmd = &cobra.Command{
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		prof, err := provider.New()
+		prof, err := loadProfile()
 		if err != nil {
 			return err
 		}
 
-		ok, err := prof.Verify()
+		info, err := profileInfo(prof)
 		if err != nil {
 			return err
 		}
-		if !ok {
-			return errors.Errorf("cannot authenticate using the credentials in %v", prof.Options().ProfilePath)
+
+		team := cast.ToString(cast.ToStringMap(info["team"])["name"])
+		if ece408ProjectMode {
+			if tname, err := client.FindTeamName(prof.Info().Username); err == nil && tname != "" {
+				team = tname
+			}
+		}
+
+		configFile := viper.ConfigFileUsed()
+		if configFile == "" {
+			configFile = "embedded"
 		}
-		buf, err := yaml.Marshal(prof.Info())
-		if err != nil {
-			return err
+
+		printField := func(name string, value string) {
+			if value == "" {
+				value = "-"
+			}
+			fmt.Printf("%-18s %v\n", name+":", value)
 		}
-		fmt.Print(string(buf))
+
+		printField("Username", prof.Info().Username)
+		printField("Email", cast.ToString(info["email"]))
+		printField("Team", team)
+		printField("Role", cast.ToString(info["role"]))
+		printField("Access key", fingerprint(cast.ToString(info["access_key"])))
+		printField("Profile", prof.Options().ProfilePath)
+		printField("Configuration", configFile)
+		printField("Env overrides", strings.Join(environmentOverrides(), ", "))
 		return nil
 	},
 }
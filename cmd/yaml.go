@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
+)
+
+// getMapSliceEntry returns the value of the key within the ordered map
+func getMapSliceEntry(entries yaml.MapSlice, key string) (interface{}, bool) {
+	for _, entry := range entries {
+		if cast.ToString(entry.Key) == key {
+			return entry.Value, true
+		}
+	}
+	return nil, false
+}
+
+// setMapSliceEntry replaces the value of the key within the ordered map,
+// or appends it if it is not present
+func setMapSliceEntry(entries yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for ii, entry := range entries {
+		if cast.ToString(entry.Key) == key {
+			entries[ii].Value = value
+			return entries
+		}
+	}
+	return append(entries, yaml.MapItem{Key: key, Value: value})
+}
+
+// deleteMapSliceEntry removes the key from the ordered map
+func deleteMapSliceEntry(entries yaml.MapSlice, key string) yaml.MapSlice {
+	for ii, entry := range entries {
+		if cast.ToString(entry.Key) == key {
+			return append(entries[:ii], entries[ii+1:]...)
+		}
+	}
+	return entries
+}
@@ -10,5 +10,12 @@ func cleanup() {
 
 func main() {
 	closer.Bind(cleanup)
-	closer.Checked(cmd.Execute, isDebug)
+	closer.Checked(func() error {
+		err := cmd.Execute()
+		// the exit code tells scripts why the job failed
+		if code := cmd.ExitCode(err); code > 1 {
+			closer.Exit(code)
+		}
+		return err
+	}, isDebug)
 }
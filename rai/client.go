@@ -0,0 +1,84 @@
+// Package rai defines the lifecycle of a job as an interface, so that tools
+// that submit jobs can be given the client of the rai-project/client
+// library or the fake of the raitest package.
+package rai
+
+import (
+	"context"
+)
+
+// Client submits a job and follows it to its end. The steps are called in
+// the order in which they are declared, and Disconnect is called once the
+// client is no longer used. *client.Client of the rai-project/client
+// library implements it.
+type Client interface {
+	// Validate checks the build file and the privileges of the user
+	Validate() error
+	// Authenticate connects to the servers and creates the session tokens
+	Authenticate() error
+	// Subscribe starts receiving the output of the job
+	Subscribe() error
+	// Upload archives the directory and uploads it to the storage server
+	Upload() error
+	// Publish sends the job to the queue
+	Publish() error
+	// Connect waits for a worker to pick the job up
+	Connect() error
+	// Wait returns once the job ends, with the error that it failed with
+	Wait() error
+	// RecordJob records the job, for rankings and submissions
+	RecordJob() error
+	// Disconnect releases the connections of the client
+	Disconnect() error
+}
+
+// Step is a step of the lifecycle of a job
+type Step struct {
+	Name string
+	Run  func() error
+}
+
+// Steps returns the steps of the lifecycle of a job, in order, without
+// Disconnect
+func Steps(c Client) []Step {
+	return []Step{
+		{Name: "Validate", Run: c.Validate},
+		{Name: "Authenticate", Run: c.Authenticate},
+		{Name: "Subscribe", Run: c.Subscribe},
+		{Name: "Upload", Run: c.Upload},
+		{Name: "Publish", Run: c.Publish},
+		{Name: "Connect", Run: c.Connect},
+		{Name: "Wait", Run: c.Wait},
+		{Name: "RecordJob", Run: c.RecordJob},
+	}
+}
+
+// Run runs the steps of the job in order, stopping at the first that fails
+// or once the context is done, and disconnects the client
+func Run(ctx context.Context, c Client) error {
+	defer c.Disconnect()
+	for _, step := range Steps(c) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := step.Run(); err != nil {
+			return &StepError{Step: step.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// StepError is the error of a step of the lifecycle that failed
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return e.Step + " failed: " + e.Err.Error()
+}
+
+// Cause returns the error of the step
+func (e *StepError) Cause() error {
+	return e.Err
+}
@@ -0,0 +1,144 @@
+package rai
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Error is a failure of the lifecycle of a job that tools can tell apart
+// by its code. It carries a hint on how to fix it and the exit code of
+// the rai command line for it. Errors with the same code match with
+// errors.Is.
+type Error struct {
+	// Code identifies the kind of failure, such as unauthorized
+	Code string `json:"code"`
+	// Message describes the failure
+	Message string `json:"message"`
+	// Hint tells how to fix the failure
+	Hint string `json:"hint,omitempty"`
+	// ExitCode is the exit code of the rai command line
+	ExitCode int `json:"exit_code"`
+	// Err is the error that caused the failure
+	Err error `json:"-"`
+}
+
+// the failures of the lifecycle of a job
+var (
+	// ErrValidation is returned when the build file or the user is rejected
+	ErrValidation = &Error{
+		Code:     "invalid_build_file",
+		Message:  "the build file was rejected",
+		Hint:     "Check the build file with `rai validate`.",
+		ExitCode: 65,
+	}
+	// ErrUnauthorized is returned when the credentials of the profile are
+	// rejected
+	ErrUnauthorized = &Error{
+		Code:     "unauthorized",
+		Message:  "the credentials were rejected",
+		Hint:     "Check the profile in ~/.rai_profile, or create it again with `rai login`.",
+		ExitCode: 77,
+	}
+	// ErrQueueNotFound is returned when the job is submitted to a queue
+	// that is not configured
+	ErrQueueNotFound = &Error{
+		Code:     "queue_not_found",
+		Message:  "the queue does not exist",
+		Hint:     "Use `rai queue list` to see the queues that jobs can be submitted to.",
+		ExitCode: 69,
+	}
+	// ErrUpload is returned when the directory could not be uploaded
+	ErrUpload = &Error{
+		Code:     "upload_failed",
+		Message:  "the upload failed",
+		Hint:     "Check your network connection with `rai doctor`.",
+		ExitCode: 66,
+	}
+)
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+// Cause returns the error that caused the failure
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the error that caused the failure
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the target is an Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// RaiError returns the error itself
+func (e *Error) RaiError() *Error {
+	return e
+}
+
+// Wrap returns a copy of the error that was caused by err, taking its
+// message from it
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	if err != nil {
+		wrapped.Message = err.Error()
+	}
+	return &wrapped
+}
+
+// ErrTarballTooLarge is returned when the directory exceeds the upload
+// limit of the queue
+type ErrTarballTooLarge struct {
+	Queue string
+	// Limit and Actual are the upload limit of the queue and the size of
+	// the directory in bytes
+	Limit  uint64
+	Actual uint64
+}
+
+func (e *ErrTarballTooLarge) Error() string {
+	return fmt.Sprintf("the directory is %v, which exceeds the %v upload limit of the %v queue",
+		humanize.Bytes(e.Actual), humanize.Bytes(e.Limit), e.Queue)
+}
+
+// RaiError returns the Error with the code of the failure
+func (e *ErrTarballTooLarge) RaiError() *Error {
+	return &Error{
+		Code:     "tarball_too_large",
+		Message:  e.Error(),
+		Hint:     "Use `rai size` to find the largest files and exclude them in .raiignore.",
+		ExitCode: 66,
+		Err:      e,
+	}
+}
+
+// AsError returns the Error of the first failure of the lifecycle found
+// in the chain of causes of err, or nil if there is none
+func AsError(err error) *Error {
+	for err != nil {
+		if e, ok := err.(interface {
+			RaiError() *Error
+		}); ok {
+			return e.RaiError()
+		}
+		switch cause := err.(type) {
+		case interface{ Cause() error }:
+			err = cause.Cause()
+		case interface{ Unwrap() error }:
+			err = cause.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
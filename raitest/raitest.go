@@ -0,0 +1,224 @@
+// Package raitest provides an in-memory implementation of rai.Client, so
+// that tools that submit jobs can be tested without the servers. The
+// uploads and the jobs are kept by a Broker, and the jobs are run by a
+// handler that writes their output.
+package raitest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/rai"
+)
+
+// Job is a job published to the broker
+type Job struct {
+	ID    string
+	Queue string
+	// Files are the uploaded files, by their slash separated path relative
+	// to the directory
+	Files map[string][]byte
+}
+
+// Handler runs a job, writing its output. The job fails with the error
+// that it returns.
+type Handler func(job *Job, stdout, stderr io.Writer) error
+
+// Broker is an in-memory storage and queue server
+type Broker struct {
+	mu      sync.Mutex
+	handler Handler
+	jobs    []*Job
+}
+
+// NewBroker returns a broker that runs the jobs with the handler. A nil
+// handler succeeds without output.
+func NewBroker(handler Handler) *Broker {
+	if handler == nil {
+		handler = func(*Job, io.Writer, io.Writer) error {
+			return nil
+		}
+	}
+	return &Broker{handler: handler}
+}
+
+// Jobs returns the jobs published to the broker, in order
+func (b *Broker) Jobs() []*Job {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*Job{}, b.jobs...)
+}
+
+func (b *Broker) publish(job *Job) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	job.ID = fmt.Sprintf("job-%d", len(b.jobs)+1)
+	b.jobs = append(b.jobs, job)
+}
+
+// Config configures a fake client
+type Config struct {
+	// Directory is uploaded with the job
+	Directory string
+	// Queue is the queue that the job is published to
+	Queue string
+	// Stdout and Stderr receive the output of the job. It is discarded
+	// when they are nil.
+	Stdout io.Writer
+	Stderr io.Writer
+	// Errors makes the steps of the lifecycle fail, by the name of the
+	// step, such as Upload
+	Errors map[string]error
+}
+
+// Client is a fake rai.Client that uploads to and publishes on a Broker
+type Client struct {
+	broker *Broker
+	config Config
+
+	mu    sync.Mutex
+	calls []string
+	job   *Job
+}
+
+var _ rai.Client = (*Client)(nil)
+
+// NewClient returns a client that submits its job to the broker
+func (b *Broker) NewClient(config Config) *Client {
+	if config.Stdout == nil {
+		config.Stdout = ioutil.Discard
+	}
+	if config.Stderr == nil {
+		config.Stderr = ioutil.Discard
+	}
+	return &Client{broker: b, config: config}
+}
+
+// Calls returns the names of the steps that were called, in order
+func (c *Client) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.calls...)
+}
+
+// Job returns the job of the client once it is uploaded
+func (c *Client) Job() *Job {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.job
+}
+
+// call notes the step and returns the error that it is configured to fail
+// with
+func (c *Client) call(step string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, step)
+	return c.config.Errors[step]
+}
+
+// Validate checks that the directory exists
+func (c *Client) Validate() error {
+	if err := c.call("Validate"); err != nil {
+		return err
+	}
+	info, err := os.Stat(c.config.Directory)
+	if err != nil {
+		return errors.Wrapf(err, "the directory %v was not found", c.config.Directory)
+	}
+	if !info.IsDir() {
+		return errors.Errorf("%v is not a directory", c.config.Directory)
+	}
+	return nil
+}
+
+// Authenticate does nothing
+func (c *Client) Authenticate() error {
+	return c.call("Authenticate")
+}
+
+// Subscribe does nothing
+func (c *Client) Subscribe() error {
+	return c.call("Subscribe")
+}
+
+// Upload reads the files of the directory into memory
+func (c *Client) Upload() error {
+	if err := c.call("Upload"); err != nil {
+		return err
+	}
+	files := map[string][]byte{}
+	err := filepath.Walk(c.config.Directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(c.config.Directory, path)
+		if err != nil {
+			return err
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = buf
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to upload the directory")
+	}
+	c.mu.Lock()
+	c.job = &Job{Queue: c.config.Queue, Files: files}
+	c.mu.Unlock()
+	return nil
+}
+
+// Publish adds the uploaded job to the broker
+func (c *Client) Publish() error {
+	if err := c.call("Publish"); err != nil {
+		return err
+	}
+	job := c.Job()
+	if job == nil {
+		return errors.New("the directory was not uploaded")
+	}
+	c.broker.publish(job)
+	return nil
+}
+
+// Connect checks that the job was published
+func (c *Client) Connect() error {
+	if err := c.call("Connect"); err != nil {
+		return err
+	}
+	if job := c.Job(); job == nil || job.ID == "" {
+		return errors.New("the job was not published")
+	}
+	return nil
+}
+
+// Wait runs the job with the handler of the broker
+func (c *Client) Wait() error {
+	if err := c.call("Wait"); err != nil {
+		return err
+	}
+	job := c.Job()
+	if job == nil || job.ID == "" {
+		return errors.New("the job was not published")
+	}
+	return c.broker.handler(job, c.config.Stdout, c.config.Stderr)
+}
+
+// RecordJob does nothing
+func (c *Client) RecordJob() error {
+	return c.call("RecordJob")
+}
+
+// Disconnect does nothing
+func (c *Client) Disconnect() error {
+	return c.call("Disconnect")
+}
@@ -0,0 +1,108 @@
+package raitest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rai-project/rai/rai"
+)
+
+func newTestDirectory(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "raitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "rai_build.yml"), []byte("rai:\n  version: 0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "src", "main.cu"), []byte("int main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	broker := NewBroker(func(job *Job, stdout, stderr io.Writer) error {
+		_, err := stdout.Write(job.Files["src/main.cu"])
+		return err
+	})
+	stdout := &bytes.Buffer{}
+	client := broker.NewClient(Config{Directory: dir, Queue: "rai_amd64", Stdout: stdout})
+
+	if err := rai.Run(context.Background(), client); err != nil {
+		t.Fatalf("the job failed: %v", err)
+	}
+
+	expectedCalls := []string{"Validate", "Authenticate", "Subscribe", "Upload", "Publish", "Connect", "Wait", "RecordJob", "Disconnect"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf("the steps called were %v, expecting %v", calls, expectedCalls)
+	}
+	jobs := broker.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("%v jobs were published, expecting 1", len(jobs))
+	}
+	if jobs[0].Queue != "rai_amd64" {
+		t.Errorf("the job was published to %v, expecting rai_amd64", jobs[0].Queue)
+	}
+	if _, ok := jobs[0].Files["rai_build.yml"]; !ok {
+		t.Errorf("rai_build.yml was not uploaded")
+	}
+	if stdout.String() != "int main() {}\n" {
+		t.Errorf("the output of the job was %q", stdout.String())
+	}
+}
+
+func TestRunStepError(t *testing.T) {
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	uploadErr := errors.New("connection reset")
+	broker := NewBroker(nil)
+	client := broker.NewClient(Config{Directory: dir, Errors: map[string]error{"Upload": uploadErr}})
+
+	err := rai.Run(context.Background(), client)
+	stepErr, ok := err.(*rai.StepError)
+	if !ok {
+		t.Fatalf("the error was %v, expecting a step error", err)
+	}
+	if stepErr.Step != "Upload" || stepErr.Err != uploadErr {
+		t.Errorf("the error was %v, expecting the upload to fail", err)
+	}
+	if len(broker.Jobs()) != 0 {
+		t.Errorf("a job was published after the upload failed")
+	}
+	expectedCalls := []string{"Validate", "Authenticate", "Subscribe", "Upload", "Disconnect"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf("the steps called were %v, expecting %v", calls, expectedCalls)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	broker := NewBroker(nil)
+	client := broker.NewClient(Config{Directory: dir})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rai.Run(ctx, client); err != context.Canceled {
+		t.Errorf("the error was %v, expecting the job to be cancelled", err)
+	}
+	if len(broker.Jobs()) != 0 {
+		t.Errorf("a job was published after the context was cancelled")
+	}
+}